@@ -15,25 +15,75 @@ package handler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"math"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/genproto/googleapis/type/interval"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
-	"outernetcouncil.org/nmts/v1/proto/ek/physical"
 	"outernetcouncil.org/nmts/v1/proto/types/geophys"
 
 	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"github.com/outernetcouncil/federation/pkg/go/availability"
+	"github.com/outernetcouncil/federation/pkg/go/compatibility"
+	"github.com/outernetcouncil/federation/pkg/go/etag"
+	"github.com/outernetcouncil/federation/pkg/go/fieldmask"
+	"github.com/outernetcouncil/federation/pkg/go/frequencyplan"
+	"github.com/outernetcouncil/federation/pkg/go/handler"
+	"github.com/outernetcouncil/federation/pkg/go/linkbudget"
+	"github.com/outernetcouncil/federation/pkg/go/motion"
+	"github.com/outernetcouncil/federation/pkg/go/operations"
+	"github.com/outernetcouncil/federation/pkg/go/orbit"
+	"github.com/outernetcouncil/federation/pkg/go/pagination"
+	"github.com/outernetcouncil/federation/pkg/go/reaper"
+	"github.com/outernetcouncil/federation/pkg/go/resourcename"
+	"github.com/outernetcouncil/federation/pkg/go/watch"
 )
 
-const TARGET_NAME = "target/mysat"
+const TARGET_NAME = "targets/mysat"
+
+// provisioningDelay simulates the time it takes to physically provision a
+// bearer or attachment circuit with the Provision methods.
+const provisioningDelay = 5 * time.Second
+
+// defaultReservationTTL is the time to live of a reservation without an
+// explicit expiration.
+const defaultReservationTTL = 5 * time.Minute
+
+// defaultDeleteGracePeriod is the time for which soft-deleted resources can be
+// restored before they are purged.
+const defaultDeleteGracePeriod = time.Hour
+
+// defaultFrequencyPlan is the frequency plan of the target unless
+// WithFrequencyPlan replaces it: bearers of 20 to 40 MHz with center
+// frequencies from 12 to 18 GHz in both directions.
+var defaultFrequencyPlan = frequencyplan.Plan{
+	Rx: []frequencyplan.Channel{{LowHz: 11980000000, HighHz: 18020000000, MinBandwidthHz: 20000000, MaxBandwidthHz: 40000000}},
+	Tx: []frequencyplan.Channel{{LowHz: 11980000000, HighHz: 18020000000, MinBandwidthHz: 20000000, MaxBandwidthHz: 40000000}},
+}
+
+// compatibleTransceiverTypes are advertised by ListCompatibleTransceiverTypes and
+// enforced on every transceiver that is created or updated.
+var compatibleTransceiverTypes = []*pb.CompatibleTransceiverType{
+	{
+		TransceiverFilter: "transmit_signal_chain.antenna.type = OPTICAL AND receive_signal_chain.antenna.type = OPTICAL",
+	},
+}
 
 type PrototypeHandler struct {
 	pb.UnimplementedInterconnectServiceServer
@@ -42,12 +92,117 @@ type PrototypeHandler struct {
 	bearers            map[string]*pb.Bearer
 	targets            map[string]*pb.Target
 	attachmentCircuits map[string]*pb.AttachmentCircuit
-	// Contact windows are mostly going to be computed on the fly. To simplify the example, we just "compute" them whenever a transceiver is created.
+	// reservations hold the capacity of bearers which are not yet created.
+	reservations map[string]*pb.Reservation
+	// Contact windows are mostly going to be computed on the fly. To simplify the example, we just "compute" them whenever a transceiver is created or its platform changes.
 	contactWindows []*pb.ContactWindow
+	paginator      *pagination.Paginator
+	// Changes are published to the hubs while holding mu, so that Watch streams
+	// can take a consistent snapshot.
+	contactWindowHub     *watch.Hub[*pb.ContactWindow]
+	bearerHub            *watch.Hub[*pb.Bearer]
+	attachmentCircuitHub *watch.Hub[*pb.AttachmentCircuit]
+	operations           *operations.Manager
+	provisioningDelay    time.Duration
+	// provisioning holds the names of the bearers and attachment circuits whose
+	// provisioning operation is running.
+	provisioning map[string]bool
+	// now returns the current time, which drives the lifecycle of bearers and
+	// attachment circuits.
+	now func() time.Time
+	// stateTimer refreshes the states when the next bearer interval starts or
+	// ends.
+	stateTimer *time.Timer
+	// reservationTimer releases the next reservation when it expires.
+	reservationTimer *time.Timer
+	// deleteGracePeriod is the time for which soft-deleted transceivers, bearers
+	// and attachment circuits can be restored before they are purged.
+	deleteGracePeriod time.Duration
+	// deletedContactWindows hold the contact windows of the soft-deleted
+	// transceivers, which are restored together with the transceiver.
+	deletedContactWindows map[string][]*pb.ContactWindow
+	// cascades hold the names of the resources that were soft-deleted together
+	// with a transceiver or bearer, which are restored together with it.
+	cascades map[string][]string
+	// purgeTimer purges the next soft-deleted resource when its grace period
+	// ends.
+	purgeTimer *time.Timer
+	// contactWindowHorizon is how far ahead contact windows are computed from
+	// the motion of the transceivers and targets. If zero, every transceiver
+	// has a fixed 24-hour window with every target instead.
+	contactWindowHorizon time.Duration
+	// minElevationDeg is the elevation mask of ground transceivers and
+	// targets.
+	minElevationDeg float64
+	// targetTerminal is the terminal with which the targets close links with
+	// the transceivers. If set, contact windows are limited to the intervals
+	// in which the link margin is at least minLinkMarginDB.
+	targetTerminal  *linkbudget.Terminal
+	minLinkMarginDB float64
+	// frequencyPlans are the frequency plans of the targets by name. Targets
+	// without a frequency plan have no contact windows.
+	frequencyPlans map[string]frequencyplan.Plan
+}
+
+// Option configures a PrototypeHandler.
+type Option func(*PrototypeHandler)
+
+// WithClock replaces the function that returns the current time.
+func WithClock(now func() time.Time) Option {
+	return func(p *PrototypeHandler) {
+		p.now = now
+	}
+}
+
+// WithDeleteGracePeriod sets the time for which soft-deleted resources can be
+// restored before they are purged.
+func WithDeleteGracePeriod(gracePeriod time.Duration) Option {
+	return func(p *PrototypeHandler) {
+		p.deleteGracePeriod = gracePeriod
+	}
+}
+
+// WithOrbitalContactWindows computes the contact windows within the horizon
+// from the motion of the transceivers and targets: a window is open while they
+// have a line of sight which is not occluded by the Earth and ground endpoints
+// see each other at least minElevationDeg above the horizon.
+func WithOrbitalContactWindows(horizon time.Duration, minElevationDeg float64) Option {
+	return func(p *PrototypeHandler) {
+		p.contactWindowHorizon = horizon
+		p.minElevationDeg = minElevationDeg
+	}
+}
+
+// WithLinkBudget limits the contact windows computed with
+// WithOrbitalContactWindows to the intervals in which both the uplink from the
+// transceiver to the target and the downlink from the target, which transmits
+// and receives with the terminal, have a margin of at least minMarginDB. The
+// contact windows carry their predicted link margin.
+func WithLinkBudget(target linkbudget.Terminal, minMarginDB float64) Option {
+	return func(p *PrototypeHandler) {
+		p.targetTerminal = &target
+		p.minLinkMarginDB = minMarginDB
+	}
+}
+
+// WithTargetMotion sets the motion of the target.
+func WithTargetMotion(motion *geophys.Motion) Option {
+	return func(p *PrototypeHandler) {
+		p.targets[TARGET_NAME].Motion = motion
+	}
+}
+
+// WithFrequencyPlan replaces the frequency plan of the target. The frequency
+// and bandwidth limits of contact windows are those of the widest band that a
+// channel of the plan has in common with a signal of the transceiver.
+func WithFrequencyPlan(plan frequencyplan.Plan) Option {
+	return func(p *PrototypeHandler) {
+		p.frequencyPlans[TARGET_NAME] = plan
+	}
 }
 
 // We pretend to be a very simple provider with one target only.
-func NewPrototypeHandler() *PrototypeHandler {
+func NewPrototypeHandler(opts ...Option) *PrototypeHandler {
 	providerTarget := pb.Target{
 		Name:   TARGET_NAME,
 		Motion: &geophys.Motion{},
@@ -55,23 +210,190 @@ func NewPrototypeHandler() *PrototypeHandler {
 	targets := make(map[string]*pb.Target)
 	targets[providerTarget.Name] = &providerTarget
 
-	return &PrototypeHandler{
+	p := &PrototypeHandler{
 		transceivers:       make(map[string]*pb.Transceiver),
 		bearers:            make(map[string]*pb.Bearer),
 		targets:            targets,
 		attachmentCircuits: make(map[string]*pb.AttachmentCircuit),
+		reservations:       make(map[string]*pb.Reservation),
 		contactWindows:     make([]*pb.ContactWindow, 0, 1),
+		paginator:          pagination.NewPaginator(nil),
+
+		contactWindowHub:     watch.NewHub[*pb.ContactWindow](watch.DefaultCapacity),
+		bearerHub:            watch.NewHub[*pb.Bearer](watch.DefaultCapacity),
+		attachmentCircuitHub: watch.NewHub[*pb.AttachmentCircuit](watch.DefaultCapacity),
+		operations:           operations.NewManager(),
+		provisioningDelay:    provisioningDelay,
+		provisioning:         make(map[string]bool),
+		now:                  time.Now,
+
+		deleteGracePeriod:     defaultDeleteGracePeriod,
+		deletedContactWindows: make(map[string][]*pb.ContactWindow),
+		cascades:              make(map[string][]string),
+		frequencyPlans:        map[string]frequencyplan.Plan{TARGET_NAME: defaultFrequencyPlan},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Operations returns the long-running operations started by ProvisionBearer and
+// ProvisionAttachmentCircuit.
+func (p *PrototypeHandler) Operations() longrunningpb.OperationsServer {
+	return p.operations
+}
+
+// provision waits for the simulated provisioning delay, or until the operation
+// is cancelled.
+func (p *PrototypeHandler) provision(ctx context.Context) error {
+	select {
+	case <-time.After(p.provisioningDelay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// softDeletable is a resource which can be soft-deleted.
+type softDeletable interface {
+	proto.Message
+	GetDeleteTime() *timestamppb.Timestamp
+}
+
+// finishProvisioningLocked moves a bearer or attachment circuit out of the
+// PROVISIONING state when its provisioning operation finishes with err, and
+// returns the response of the operation.
+func finishProvisioningLocked[T softDeletable](ctx context.Context, p *PrototypeHandler, name string, err error, resources map[string]T, setState func(name string, state pb.LifecycleState, reason string)) (proto.Message, error) {
+	delete(p.provisioning, name)
+	if resource, ok := resources[name]; !ok || resource.GetDeleteTime() != nil {
+		return nil, status.Errorf(codes.Aborted, "%s was deleted while it was provisioned", name)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			setState(name, pb.LifecycleState_LIFECYCLE_STATE_CANCELLED, "provisioning was cancelled")
+		} else {
+			setState(name, pb.LifecycleState_LIFECYCLE_STATE_FAILED, fmt.Sprintf("provisioning failed: %v", err))
+		}
+	}
+	p.refreshStatesLocked()
+	if err != nil {
+		return nil, err
+	}
+	return resources[name], nil
+}
+
+// isReleased reports whether a bearer or attachment circuit in the given state
+// no longer holds its resources.
+func isReleased(state pb.LifecycleState) bool {
+	return state == pb.LifecycleState_LIFECYCLE_STATE_FAILED || state == pb.LifecycleState_LIFECYCLE_STATE_CANCELLED
+}
+
+// bearerState returns the state of a bearer at the given time. Once
+// provisioned, a bearer is PENDING until its interval starts, ACTIVE during
+// its interval and SUCCEEDED afterwards. A soft-deleted bearer is DELETED,
+// unless it was already released.
+func (p *PrototypeHandler) bearerState(bearer *pb.Bearer, now time.Time) (pb.LifecycleState, string) {
+	switch {
+	case isReleased(bearer.State):
+		return bearer.State, bearer.StateReason
+	case bearer.DeleteTime != nil:
+		return pb.LifecycleState_LIFECYCLE_STATE_DELETED, "the bearer was deleted"
+	case p.provisioning[bearer.Name]:
+		return pb.LifecycleState_LIFECYCLE_STATE_PROVISIONING, "the bearer is being provisioned"
+	case now.Before(bearer.Interval.StartTime.AsTime()):
+		return pb.LifecycleState_LIFECYCLE_STATE_PENDING, "the interval has not started"
+	case now.Before(bearer.Interval.EndTime.AsTime()):
+		return pb.LifecycleState_LIFECYCLE_STATE_ACTIVE, ""
+	default:
+		return pb.LifecycleState_LIFECYCLE_STATE_SUCCEEDED, "the interval has ended"
+	}
+}
+
+// attachmentCircuitState returns the state of an attachment circuit. Once
+// provisioned, an attachment circuit is in the same state as its bearer. A
+// soft-deleted attachment circuit is DELETED, unless it was already released.
+func (p *PrototypeHandler) attachmentCircuitState(circuit *pb.AttachmentCircuit) (pb.LifecycleState, string) {
+	switch {
+	case isReleased(circuit.State):
+		return circuit.State, circuit.StateReason
+	case circuit.DeleteTime != nil:
+		return pb.LifecycleState_LIFECYCLE_STATE_DELETED, "the attachment circuit was deleted"
+	case p.provisioning[circuit.Name]:
+		return pb.LifecycleState_LIFECYCLE_STATE_PROVISIONING, "the attachment circuit is being provisioned"
+	}
+	bearer := p.bearers[circuit.GetL2Connection().GetBearer()]
+	return bearer.GetState(), bearer.GetStateReason()
+}
+
+// setBearerStateLocked replaces the bearer by a copy in the given state and
+// publishes the change.
+func (p *PrototypeHandler) setBearerStateLocked(name string, state pb.LifecycleState, reason string) {
+	bearer := p.bearers[name]
+	if bearer.State == state && bearer.StateReason == reason {
+		return
+	}
+	updated := proto.Clone(bearer).(*pb.Bearer)
+	updated.State, updated.StateReason = state, reason
+	etag.Set(updated)
+	p.bearers[name] = updated
+	p.bearerHub.Modified(bearer, updated)
+}
+
+// setAttachmentCircuitStateLocked replaces the attachment circuit by a copy in
+// the given state and publishes the change.
+func (p *PrototypeHandler) setAttachmentCircuitStateLocked(name string, state pb.LifecycleState, reason string) {
+	circuit := p.attachmentCircuits[name]
+	if circuit.State == state && circuit.StateReason == reason {
+		return
+	}
+	updated := proto.Clone(circuit).(*pb.AttachmentCircuit)
+	updated.State, updated.StateReason = state, reason
+	etag.Set(updated)
+	p.attachmentCircuits[name] = updated
+	p.attachmentCircuitHub.Modified(circuit, updated)
+}
+
+// refreshStatesLocked moves all bearers and attachment circuits to their
+// current state, and schedules the next refresh for when the next bearer
+// interval starts or ends.
+func (p *PrototypeHandler) refreshStatesLocked() {
+	now := p.now()
+	var next time.Time
+	for name, bearer := range p.bearers {
+		state, reason := p.bearerState(bearer, now)
+		p.setBearerStateLocked(name, state, reason)
+		for _, t := range []time.Time{bearer.Interval.StartTime.AsTime(), bearer.Interval.EndTime.AsTime()} {
+			if t.After(now) && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+	}
+	// Attachment circuits follow the refreshed bearers.
+	for name, circuit := range p.attachmentCircuits {
+		state, reason := p.attachmentCircuitState(circuit)
+		p.setAttachmentCircuitStateLocked(name, state, reason)
+	}
+
+	if p.stateTimer != nil {
+		p.stateTimer.Stop()
+	}
+	if !next.IsZero() {
+		p.stateTimer = time.AfterFunc(next.Sub(now), p.refreshStates)
 	}
 }
 
+func (p *PrototypeHandler) refreshStates() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.refreshStatesLocked()
+}
+
 func (p *PrototypeHandler) ListCompatibleTransceiverTypes(context.Context, *pb.ListCompatibleTransceiverTypesRequest) (*pb.ListCompatibleTransceiverTypesResponse, error) {
 	// TODO: Is this enough as an example? Constraining the antenna types?
 	return &pb.ListCompatibleTransceiverTypesResponse{
-		CompatibleTransceiverTypes: []*pb.CompatibleTransceiverType{
-			{
-				TransceiverFilter: "transmit_signal_chain.antenna.type = OPTICAL AND receive_signal_chain.antenna.type = OPTICAL",
-			},
-		},
+		CompatibleTransceiverTypes: compatibleTransceiverTypes,
 	}, nil
 }
 
@@ -79,6 +401,10 @@ func (p *PrototypeHandler) GetTransceiver(ctx context.Context, trans *pb.GetTran
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if _, err := resourcename.Transceiver.Parse("name", trans.Name); err != nil {
+		return nil, err
+	}
+	p.purgeDeletedLocked()
 	if p.transceivers[trans.Name] == nil {
 		return nil, status.Errorf(codes.NotFound, "transceiver with requested ID was not found")
 	}
@@ -90,17 +416,29 @@ func (p *PrototypeHandler) ListTransceivers(ctx context.Context, request *pb.Lis
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if request.Filter != "" {
-		return nil, status.Errorf(codes.Unimplemented, "filters are not yet implemented")
-	}
-
+	p.purgeDeletedLocked()
 	transceivers := make([]*pb.Transceiver, 0, len(p.transceivers))
 	for _, transceiver := range p.transceivers {
-		transceivers = append(transceivers, transceiver)
+		if request.ShowDeleted || transceiver.DeleteTime == nil {
+			transceivers = append(transceivers, transceiver)
+		}
+	}
+	transceivers, err := handler.ApplyFilter(request.Filter, transceivers)
+	if err != nil {
+		return nil, err
+	}
+	transceivers, nextPageToken, err := pagination.Paginate(p.paginator, pagination.Request{
+		PageSize:  request.PageSize,
+		PageToken: request.PageToken,
+		Query:     []string{request.Filter, strconv.FormatBool(request.ShowDeleted)},
+	}, transceivers, (*pb.Transceiver).GetName)
+	if err != nil {
+		return nil, err
 	}
 
 	return &pb.ListTransceiversResponse{
-		Transceivers: transceivers,
+		Transceivers:  transceivers,
+		NextPageToken: nextPageToken,
 	}, nil
 }
 
@@ -108,7 +446,10 @@ func (p *PrototypeHandler) CreateTransceiver(_ context.Context, trans *pb.Create
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	transceiverName := fmt.Sprintf("transceivers/%s", trans.TransceiverId)
+	if err := resourcename.ValidateID("transceiver_id", trans.TransceiverId); err != nil {
+		return nil, err
+	}
+	transceiverName := resourcename.Transceiver.Format(trans.TransceiverId)
 	if p.transceivers[transceiverName] != nil {
 		return nil, status.Errorf(codes.AlreadyExists, "transceiver with requested ID was already created")
 	}
@@ -118,250 +459,1385 @@ func (p *PrototypeHandler) CreateTransceiver(_ context.Context, trans *pb.Create
 	// Override the name of the attachment circuit to ensure that it has the correct resource name.
 	// It is up to the API to either validate the correctness of the name or just override it on creation.
 	trans.Transceiver.Name = transceiverName
+	windows, err := p.computeContactWindows(trans.Transceiver)
+	if err != nil {
+		return nil, err
+	}
+	etag.Set(trans.Transceiver)
+	if trans.ValidateOnly {
+		return trans.Transceiver, nil
+	}
 	p.transceivers[transceiverName] = trans.Transceiver
 
-	for _, target := range p.targets {
-		targetID := strings.Split(target.Name, "/")[1]
-		p.contactWindows = append(p.contactWindows, &pb.ContactWindow{
-			Name: fmt.Sprintf("contactWindow/%s%s", trans.TransceiverId, targetID),
-			Interval: &interval.Interval{
-				StartTime: &timestamppb.Timestamp{
-					Seconds: int64(time.Now().Unix()),
-				},
-				EndTime: &timestamppb.Timestamp{
-					Seconds: int64(time.Now().Unix()) + 60*60*24, // let's just have a one day window everywhere
-				},
-			},
-			Transceiver:            fmt.Sprintf("transceivers/%s", trans.TransceiverId),
-			Target:                 target.Name,
-			MinRxCenterFrequencyHz: 12000000000,
-			MaxRxCenterFrequencyHz: 18000000000,
-			MinRxBandwidthHz:       20000000,
-			MaxRxBandwidthHz:       40000000,
-			MinTxCenterFrequencyHz: 12000000000,
-			MaxTxCenterFrequencyHz: 18000000000,
-			MinTxBandwidthHz:       20000000,
-			MaxTxBandwidthHz:       40000000,
-		})
-	}
+	p.replaceContactWindows(transceiverName, windows)
 
 	return trans.Transceiver, nil
 }
 
-func checkForAdmissibleTransceiver(trans *pb.Transceiver) error {
-	if trans.ReceiveSignalChain == nil ||
-		trans.TransmitSignalChain == nil ||
-		trans.ReceiveSignalChain.Antenna == nil ||
-		trans.TransmitSignalChain.Antenna == nil ||
-		trans.TransmitSignalChain.Antenna.Type != physical.Antenna_OPTICAL ||
-		trans.ReceiveSignalChain.Antenna.Type != physical.Antenna_OPTICAL {
-		return status.Errorf(codes.FailedPrecondition, "transceiver is not compatible, see ListCompatibleTransceiverTypes for details")
+// computeContactWindows computes the contact windows of a transceiver with all
+// targets with which it has spectrum in common. Without a horizon, an existing
+// window keeps its interval, so that it only changes with its frequencies.
+func (p *PrototypeHandler) computeContactWindows(transceiver *pb.Transceiver) ([]*pb.ContactWindow, error) {
+	if p.contactWindowHorizon > 0 {
+		return p.computeOrbitalContactWindows(transceiver)
+	}
+	existing := make(map[string]*pb.ContactWindow)
+	for _, window := range p.contactWindows {
+		if window.Transceiver == transceiver.Name {
+			existing[window.Name] = window
+		}
+	}
+	transceiverID := resourcename.Transceiver.ID(transceiver.Name)
+	now := p.now().Truncate(time.Second)
+	windows := make([]*pb.ContactWindow, 0, len(p.targets))
+	// Iterate over the targets in a stable order, so that the windows are too.
+	for _, targetName := range slices.Sorted(maps.Keys(p.targets)) {
+		rx, tx, ok := p.frequencyPlans[targetName].Limits(transceiver)
+		if !ok {
+			log.Printf("No contact windows for %s with %s: no spectrum in common", transceiver.Name, targetName)
+			continue
+		}
+		name := contactWindowName(transceiverID, resourcename.Target.ID(targetName))
+		start, end := now, now.Add(24*time.Hour) // let's just have a one day window everywhere
+		// Keep the interval of a window until it ends.
+		if window := existing[name]; window != nil && window.Interval.EndTime.AsTime().After(now) {
+			start, end = window.Interval.StartTime.AsTime(), window.Interval.EndTime.AsTime()
+		}
+		windows = append(windows, newContactWindow(name, transceiver.Name, targetName, start, end, rx, tx))
+	}
+	return windows, nil
+}
+
+// computeOrbitalContactWindows computes the contact windows of a transceiver
+// with all targets within the horizon by propagating their motion. Targets
+// whose motion cannot be propagated have no contact windows.
+func (p *PrototypeHandler) computeOrbitalContactWindows(transceiver *pb.Transceiver) ([]*pb.ContactWindow, error) {
+	transceiverMotion, err := motion.New(transceiver.GetPlatform().GetMotion())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "transceiver.platform.motion: %v", err)
+	}
+	var uplinks, downlinks []linkbudget.Link
+	if p.targetTerminal != nil {
+		uplinks, downlinks, err = linkbudget.Links(transceiver, *p.targetTerminal)
+		if errors.Is(err, linkbudget.ErrNoCommonModulation) {
+			log.Printf("No contact windows for %s: %v", transceiver.Name, err)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "transceiver: %v", err)
+		}
+	}
+	// The windows with the targets, to keep the start of passes in progress.
+	existing := make(map[string][]*pb.ContactWindow)
+	for _, window := range p.contactWindows {
+		if window.Transceiver == transceiver.Name {
+			existing[window.Target] = append(existing[window.Target], window)
+		}
+	}
+	transceiverID := resourcename.Transceiver.ID(transceiver.Name)
+	now := p.now()
+
+	var windows []*pb.ContactWindow
+	// Iterate over the targets in a stable order, so that the windows are too.
+	for _, targetName := range slices.Sorted(maps.Keys(p.targets)) {
+		rx, tx, ok := p.frequencyPlans[targetName].Limits(transceiver)
+		if !ok {
+			log.Printf("No contact windows for %s with %s: no spectrum in common", transceiver.Name, targetName)
+			continue
+		}
+		targetMotion, err := motion.New(p.targets[targetName].Motion)
+		if err != nil {
+			log.Printf("Skipping contact windows with %s: %v", targetName, err)
+			continue
+		}
+		// The motion is sampled on a grid aligned to the step, so that
+		// recomputing the windows yields the same boundaries. State vector
+		// tables can only be evaluated within the table.
+		start, end := now.Truncate(orbit.DefaultStep), now.Add(p.contactWindowHorizon)
+		for _, m := range []*motion.Evaluator{transceiverMotion, targetMotion} {
+			if spanStart, spanEnd, ok := m.Span(); ok {
+				start, end = maxTime(start, spanStart), minTime(end, spanEnd)
+			}
+		}
+		if !start.Before(end) {
+			continue
+		}
+		visibility := &orbit.Visibility{A: p.endpoint(transceiverMotion), B: p.endpoint(targetMotion)}
+		margin := func(t time.Time) (float64, error) {
+			return linkMarginDB(t, uplinks, downlinks, transceiverMotion, targetMotion)
+		}
+		feasible := visibility.Visible
+		if p.targetTerminal != nil {
+			feasible = func(t time.Time) (bool, error) {
+				if visible, err := visibility.Visible(t); err != nil || !visible {
+					return false, err
+				}
+				m, err := margin(t)
+				return m >= p.minLinkMarginDB, err
+			}
+		}
+		intervals, err := orbit.Intervals(start, end, orbit.DefaultStep, orbit.DefaultTolerance, feasible)
+		if err != nil {
+			log.Printf("Skipping contact windows with %s: %v", targetName, err)
+			continue
+		}
+		targetID := resourcename.Target.ID(targetName)
+		for _, interval := range intervals {
+			if !interval.End.After(now) {
+				continue
+			}
+			if interval.Start.Equal(start) {
+				// The pass is in progress and keeps the start of its window.
+				for _, window := range existing[targetName] {
+					if ws := window.Interval.StartTime.AsTime(); ws.Before(start) && window.Interval.EndTime.AsTime().After(start) {
+						interval.Start = ws
+					}
+				}
+			}
+			// A window is identified by the start of its pass.
+			window := newContactWindow(
+				contactWindowName(transceiverID, targetID, strconv.FormatInt(interval.Start.Truncate(orbit.DefaultStep).Unix(), 10)),
+				transceiver.Name, targetName,
+				interval.Start, interval.End,
+				rx, tx,
+			)
+			if p.targetTerminal != nil {
+				predicted, err := minOver(interval, margin)
+				if err != nil {
+					return nil, status.Errorf(codes.Internal, "failed to predict the link margin: %v", err)
+				}
+				window.PredictedLinkMarginDb = proto.Float64(predicted)
+			}
+			windows = append(windows, window)
+		}
+	}
+	return windows, nil
+}
+
+// linkMarginDB returns the margin of the weaker of the uplink and the downlink
+// at t. Each direction uses the signal with the best margin.
+func linkMarginDB(t time.Time, uplinks, downlinks []linkbudget.Link, transceiver, target orbit.Propagator) (float64, error) {
+	best := func(links []linkbudget.Link, from, to orbit.Propagator) (float64, error) {
+		margin := math.Inf(-1)
+		for _, link := range links {
+			budget, err := link.Between(t, from, to)
+			if err != nil {
+				return 0, err
+			}
+			margin = math.Max(margin, budget.MarginDB)
+		}
+		return margin, nil
+	}
+	up, err := best(uplinks, transceiver, target)
+	if err != nil {
+		return 0, err
+	}
+	down, err := best(downlinks, target, transceiver)
+	if err != nil {
+		return 0, err
+	}
+	return math.Min(up, down), nil
+}
+
+// minOver returns the minimum of f within the interval, sampled every
+// orbit.DefaultStep and at the end.
+func minOver(interval orbit.Interval, f func(time.Time) (float64, error)) (float64, error) {
+	minimum := math.Inf(1)
+	for t := interval.Start; ; t = t.Add(orbit.DefaultStep) {
+		t = minTime(t, interval.End)
+		v, err := f(t)
+		if err != nil {
+			return 0, err
+		}
+		minimum = math.Min(minimum, v)
+		if !t.Before(interval.End) {
+			return minimum, nil
+		}
+	}
+}
+
+// endpoint returns a visibility endpoint that moves according to the motion.
+// Ground endpoints are subject to the elevation mask.
+func (p *PrototypeHandler) endpoint(m *motion.Evaluator) orbit.Endpoint {
+	return orbit.Endpoint{
+		Propagator:      m,
+		ElevationMask:   m.Fixed(),
+		MinElevationDeg: p.minElevationDeg,
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// maxIDLength is the maximum length of a resource ID, see AIP-122.
+const maxIDLength = 63
+
+// contactWindowName returns the name of a contact window whose ID joins the
+// given IDs with hyphens. IDs that would be too long are cut and end with a
+// hash of the full ID instead, so that they stay unique.
+func contactWindowName(ids ...string) string {
+	id := strings.Join(ids, "-")
+	if len(id) > maxIDLength {
+		sum := sha256.Sum256([]byte(id))
+		hash := hex.EncodeToString(sum[:4])
+		id = strings.TrimRight(id[:maxIDLength-len(hash)-1], "-") + "-" + hash
+	}
+	return resourcename.ContactWindow.Format(id)
+}
+
+// newContactWindow returns a contact window with the receive and transmit
+// limits.
+func newContactWindow(name, transceiverName, targetName string, start, end time.Time, rx, tx frequencyplan.Limits) *pb.ContactWindow {
+	window := &pb.ContactWindow{
+		Name: name,
+		Interval: &interval.Interval{
+			StartTime: timestamppb.New(start),
+			EndTime:   timestamppb.New(end),
+		},
+		Transceiver: transceiverName,
+		Target:      targetName,
+	}
+	frequencyplan.Apply(window, rx, tx)
+	return window
+}
+
+// replaceContactWindows replaces the contact windows of a transceiver and
+// publishes the changes to Watch streams. Windows that did not change are not
+// published.
+func (p *PrototypeHandler) replaceContactWindows(transceiverName string, windows []*pb.ContactWindow) {
+	replacements := make(map[string]*pb.ContactWindow, len(windows))
+	for _, window := range windows {
+		replacements[window.Name] = window
+	}
+
+	newWindows := make([]*pb.ContactWindow, 0, len(p.contactWindows)+len(windows))
+	for _, window := range p.contactWindows {
+		if window.Transceiver != transceiverName {
+			newWindows = append(newWindows, window)
+		} else if replacement, ok := replacements[window.Name]; ok {
+			if proto.Equal(window, replacement) {
+				newWindows = append(newWindows, window)
+			} else {
+				newWindows = append(newWindows, replacement)
+				p.contactWindowHub.Modified(window, replacement)
+			}
+			delete(replacements, window.Name)
+		} else {
+			p.contactWindowHub.Deleted(window)
+		}
+	}
+	for _, window := range windows {
+		if replacements[window.Name] != nil {
+			newWindows = append(newWindows, window)
+			p.contactWindowHub.Added(window)
+		}
+	}
+	p.contactWindows = newWindows
+}
+
+// checkForAdmissibleTransceiver accepts a transceiver if it matches any of the
+// advertised compatible transceiver types. Otherwise the error explains which
+// clauses failed.
+func checkForAdmissibleTransceiver(trans *pb.Transceiver) error {
+	report, err := compatibility.Diagnose(&pb.ListCompatibleTransceiverTypesResponse{
+		CompatibleTransceiverTypes: compatibleTransceiverTypes,
+	}, trans)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to evaluate compatible transceiver types: %v", err)
+	}
+
+	return report.Err()
+}
+
+// UpdateTransceiver replaces the transceiver, or only the fields selected by the
+// update mask. The contact windows of the transceiver are recomputed, and its
+// bearers that no longer fit into a contact window are marked as at risk.
+func (p *PrototypeHandler) UpdateTransceiver(_ context.Context, trans *pb.UpdateTransceiverRequest) (*pb.Transceiver, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The etag is computed by the server and cannot be updated.
+	if err := fieldmask.Validate(trans.UpdateMask, (&pb.Transceiver{}).ProtoReflect().Descriptor(), "etag"); err != nil {
+		return nil, err
+	}
+	if _, err := resourcename.Transceiver.Parse("transceiver.name", trans.Transceiver.GetName()); err != nil {
+		return nil, err
+	}
+	current := p.transceivers[trans.Transceiver.Name]
+	if current == nil {
+		return nil, status.Errorf(codes.NotFound, "transceiver with requested ID was not found")
+	}
+	if err := etag.Check(current, trans.Transceiver.Etag); err != nil {
+		return nil, err
+	}
+	if current.DeleteTime != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "transceiver is deleted and cannot be updated")
+	}
+	updated := proto.Clone(current).(*pb.Transceiver)
+	fieldmask.Apply(updated, trans.Transceiver, trans.UpdateMask)
+	updated.Name = current.Name
+	if fieldmask.IsFull(trans.UpdateMask) {
+		if err := fieldmask.CheckImmutable(current, updated); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkForAdmissibleTransceiver(updated); err != nil {
+		return nil, err
+	}
+
+	// Both the platform and the signal chains determine the contact windows.
+	windows, err := p.computeContactWindows(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	etag.Set(updated)
+	if trans.ValidateOnly {
+		return updated, nil
+	}
+	p.transceivers[updated.Name] = updated
+	p.replaceContactWindows(updated.Name, windows)
+	p.markBearersAtRiskLocked(updated.Name)
+
+	return updated, nil
+}
+
+// markBearersAtRiskLocked marks the bearers of the transceiver whose remaining
+// interval no longer fits into one of its contact windows as at risk, and
+// clears the mark of those that fit again. Soft-deleted bearers are checked
+// when they are restored, and released or ended bearers are never at risk.
+func (p *PrototypeHandler) markBearersAtRiskLocked(transceiverName string) {
+	now := p.now()
+	for name, bearer := range p.bearers {
+		if bearer.Transceiver != transceiverName || bearer.DeleteTime != nil || isReleased(bearer.State) ||
+			!now.Before(bearer.Interval.EndTime.AsTime()) {
+			continue
+		}
+		atRisk := true
+		for _, window := range p.contactWindows {
+			if windowCoversBearer(window, bearer, now) {
+				atRisk = false
+				break
+			}
+		}
+		if bearer.AtRisk == atRisk {
+			continue
+		}
+		if atRisk {
+			log.Printf("Bearer %s no longer fits into a contact window of %s", name, transceiverName)
+		}
+		updated := proto.Clone(bearer).(*pb.Bearer)
+		updated.AtRisk = atRisk
+		etag.Set(updated)
+		p.bearers[name] = updated
+		p.bearerHub.Modified(bearer, updated)
+	}
+}
+
+// DeleteTransceiver soft-deletes the transceiver and removes its contact
+// windows. With force, the bearers of the transceiver and their attachment
+// circuits are soft-deleted as well.
+func (p *PrototypeHandler) DeleteTransceiver(_ context.Context, trans *pb.DeleteTransceiverRequest) (*pb.DeleteTransceiverResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := resourcename.Transceiver.Parse("name", trans.Name); err != nil {
+		return nil, err
+	}
+	if p.transceivers[trans.Name] == nil || p.transceivers[trans.Name].DeleteTime != nil {
+		return nil, status.Errorf(codes.NotFound, "transceiver with requested ID was not found")
+	}
+	if err := etag.Check(p.transceivers[trans.Name], trans.Etag); err != nil {
+		return nil, err
+	}
+
+	c := p.newCascade()
+	if err := c.deleteTransceiver(trans.Name, trans.Force); err != nil {
+		c.rollback()
+		return nil, err
+	}
+
+	return &pb.DeleteTransceiverResponse{DeletedResources: c.commit()}, nil
+}
+
+func (p *PrototypeHandler) ListContactWindows(_ context.Context, request *pb.ListContactWindowsRequest) (*pb.ListContactWindowsResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	contactWindows, err := handler.ApplyFilter(request.Filter, p.contactWindows)
+	if err != nil {
+		return nil, err
+	}
+	contactWindows, nextPageToken, err := pagination.Paginate(p.paginator, pagination.Request{
+		PageSize:  request.PageSize,
+		PageToken: request.PageToken,
+		Query:     []string{request.Filter},
+	}, contactWindows, (*pb.ContactWindow).GetName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ListContactWindowsResponse{
+		ContactWindows: contactWindows,
+		NextPageToken:  nextPageToken,
+	}, nil
+}
+
+func (p *PrototypeHandler) WatchContactWindows(request *pb.WatchContactWindowsRequest, stream pb.InterconnectService_WatchContactWindowsServer) error {
+	p.mu.Lock()
+	sub, resumed := p.contactWindowHub.Subscribe(request.ResumeToken)
+	var snapshot []*pb.ContactWindow
+	if !resumed {
+		snapshot = append(make([]*pb.ContactWindow, 0, len(p.contactWindows)), p.contactWindows...)
+	}
+	p.mu.Unlock()
+
+	return watch.Serve(stream.Context(), sub, snapshot, request.Filter, func(typ pb.WatchEventType, window *pb.ContactWindow, resumeToken string) error {
+		return stream.Send(&pb.WatchContactWindowsResponse{Type: typ, ContactWindow: window, ResumeToken: resumeToken})
+	})
+}
+
+// QueryAvailability returns the regions of the contact windows of the
+// transceiver within the requested interval that are not allocated to bearers
+// or reservations. Released bearers do not allocate any region.
+func (p *PrototypeHandler) QueryAvailability(_ context.Context, request *pb.QueryAvailabilityRequest) (*pb.QueryAvailabilityResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := resourcename.Transceiver.Parse("transceiver", request.Transceiver); err != nil {
+		return nil, err
+	}
+	if p.transceivers[request.Transceiver] == nil || p.transceivers[request.Transceiver].DeleteTime != nil {
+		return nil, status.Errorf(codes.NotFound, "transceiver with requested ID was not found")
+	}
+	if request.Target != "" && p.targets[request.Target] == nil {
+		return nil, status.Errorf(codes.NotFound, "could not find target")
+	}
+	if request.Interval.GetStartTime() == nil || request.Interval.GetEndTime() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "interval is required")
+	}
+	start, end := request.Interval.StartTime.AsTime(), request.Interval.EndTime.AsTime()
+	if start.After(end) {
+		return nil, status.Errorf(codes.InvalidArgument, "negative time interval argument")
+	}
+
+	bearers := p.allocatedBearersLocked("")
+	var windows []*pb.ContactWindow
+	for _, window := range p.contactWindows {
+		if window.Transceiver == request.Transceiver && (request.Target == "" || window.Target == request.Target) {
+			windows = append(windows, window)
+		}
+	}
+	slices.SortFunc(windows, func(a, b *pb.ContactWindow) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	var regions []*pb.AvailabilityRegion
+	for _, window := range windows {
+		regions = append(regions, availability.Regions(window, bearers, start, end)...)
+	}
+	return &pb.QueryAvailabilityResponse{AvailabilityRegions: regions}, nil
+}
+
+func (p *PrototypeHandler) ListBearers(_ context.Context, request *pb.ListBearersRequest) (*pb.ListBearersResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.purgeDeletedLocked()
+	bearers := make([]*pb.Bearer, 0, len(p.bearers))
+	for _, bearer := range p.bearers {
+		if request.ShowDeleted || bearer.DeleteTime == nil {
+			bearers = append(bearers, bearer)
+		}
+	}
+	bearers, err := handler.ApplyFilter(request.Filter, bearers)
+	if err != nil {
+		return nil, err
+	}
+	bearers, nextPageToken, err := pagination.Paginate(p.paginator, pagination.Request{
+		PageSize:  request.PageSize,
+		PageToken: request.PageToken,
+		Query:     []string{request.Filter, strconv.FormatBool(request.ShowDeleted)},
+	}, bearers, (*pb.Bearer).GetName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ListBearersResponse{Bearers: bearers, NextPageToken: nextPageToken}, nil
+}
+
+func (p *PrototypeHandler) WatchBearers(request *pb.WatchBearersRequest, stream pb.InterconnectService_WatchBearersServer) error {
+	p.mu.Lock()
+	sub, resumed := p.bearerHub.Subscribe(request.ResumeToken)
+	var snapshot []*pb.Bearer
+	if !resumed {
+		snapshot = make([]*pb.Bearer, 0, len(p.bearers))
+		for _, bearer := range p.bearers {
+			if bearer.DeleteTime == nil {
+				snapshot = append(snapshot, bearer)
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	return watch.Serve(stream.Context(), sub, snapshot, request.Filter, func(typ pb.WatchEventType, bearer *pb.Bearer, resumeToken string) error {
+		return stream.Send(&pb.WatchBearersResponse{Type: typ, Bearer: bearer, ResumeToken: resumeToken})
+	})
+}
+
+func (p *PrototypeHandler) GetBearer(_ context.Context, bearer *pb.GetBearerRequest) (*pb.Bearer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := resourcename.Bearer.Parse("name", bearer.Name); err != nil {
+		return nil, err
+	}
+	p.purgeDeletedLocked()
+	if p.bearers[bearer.Name] == nil {
+		return nil, status.Errorf(codes.NotFound, "bearer with requested ID was not found")
+	}
+
+	return p.bearers[bearer.Name], nil
+}
+
+func (p *PrototypeHandler) CreateBearer(_ context.Context, bearer *pb.CreateBearerRequest) (*pb.Bearer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.checkBearer(bearer); err != nil {
+		return nil, err
+	}
+	if bearer.ValidateOnly {
+		p.initBearerLocked(bearer)
+		return bearer.Bearer, nil
+	}
+	p.createBearerLocked(bearer)
+
+	return bearer.Bearer, nil
+}
+
+// createBearerLocked stores a checked bearer in its initial state.
+func (p *PrototypeHandler) createBearerLocked(bearer *pb.CreateBearerRequest) {
+	p.initBearerLocked(bearer)
+	p.bearers[bearer.Bearer.Name] = bearer.Bearer
+	p.bearerHub.Added(bearer.Bearer)
+	p.refreshStatesLocked()
+}
+
+// initBearerLocked sets the name, initial state and etag of a bearer that is
+// created.
+func (p *PrototypeHandler) initBearerLocked(bearer *pb.CreateBearerRequest) {
+	// Override the name of the attachment circuit to ensure that it has the correct resource name.
+	// It is up to the API to either validate the correctness of the name or just override it on creation.
+	bearer.Bearer.Name = resourcename.Bearer.Format(bearer.BearerId)
+	bearer.Bearer.State = pb.LifecycleState_LIFECYCLE_STATE_UNSPECIFIED
+	bearer.Bearer.State, bearer.Bearer.StateReason = p.bearerState(bearer.Bearer, p.now())
+	bearer.Bearer.AtRisk = false
+	etag.Set(bearer.Bearer)
+}
+
+// checkBearer checks whether the bearer can be created.
+func (p *PrototypeHandler) checkBearer(bearer *pb.CreateBearerRequest) error {
+	if err := resourcename.ValidateID("bearer_id", bearer.BearerId); err != nil {
+		return err
+	}
+	bearerName := resourcename.Bearer.Format(bearer.BearerId)
+	if p.bearers[bearerName] != nil {
+		return status.Errorf(codes.AlreadyExists, "bearer with requested ID was already created")
+	}
+	if bearer.Bearer.Interval.StartTime.AsTime().After(bearer.Bearer.Interval.EndTime.AsTime()) {
+		return status.Errorf(codes.InvalidArgument, "bearer has negative time interval argument")
+	}
+	if !p.checkForSufficientContactWindow(bearer.Bearer, bearerName) {
+		return insufficientContactWindow(bearer.Bearer)
+	}
+
+	return nil
+}
+
+// ProvisionBearer validates and creates the bearer in the PROVISIONING state.
+// The bearer leaves this state after the simulated provisioning delay, or
+// becomes CANCELLED if the operation is cancelled.
+func (p *PrototypeHandler) ProvisionBearer(_ context.Context, request *pb.ProvisionBearerRequest) (*longrunningpb.Operation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	create := &pb.CreateBearerRequest{BearerId: request.BearerId, Bearer: request.Bearer}
+	if err := p.checkBearer(create); err != nil {
+		return nil, err
+	}
+	name := resourcename.Bearer.Format(request.BearerId)
+	// The operation cannot finish before mu is released.
+	op, err := p.operations.Start("ProvisionBearer", name, func(ctx context.Context) (proto.Message, error) {
+		err := p.provision(ctx)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return finishProvisioningLocked(ctx, p, name, err, p.bearers, p.setBearerStateLocked)
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.provisioning[name] = true
+	p.createBearerLocked(create)
+
+	return op, nil
+}
+
+// insufficientContactWindow returns the FAILED_PRECONDITION error for a bearer
+// which does not reside within a contact window.
+func insufficientContactWindow(bearer *pb.Bearer) error {
+	return preconditionFailure("bearer has no sufficient contact window", &errdetails.PreconditionFailure_Violation{
+		Type:        "CONTACT_WINDOW",
+		Subject:     bearer.Transceiver,
+		Description: fmt.Sprintf("no contact window with %s covers the interval and frequencies of the bearer without conflicting with other bearers", bearer.Target),
+	})
+}
+
+// preconditionFailure returns a FAILED_PRECONDITION error with a
+// google.rpc.PreconditionFailure detail.
+func preconditionFailure(msg string, violation *errdetails.PreconditionFailure_Violation) error {
+	st := status.New(codes.FailedPrecondition, msg)
+	if withDetails, err := st.WithDetails(&errdetails.PreconditionFailure{
+		Violations: []*errdetails.PreconditionFailure_Violation{violation},
+	}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// checkForSufficientContactWindow checks whether the bearer with the given name
+// resides within a contact window without conflicting with other bearers.
+func (p *PrototypeHandler) checkForSufficientContactWindow(bearer *pb.Bearer, bearerName string) bool {
+	for _, knownBearer := range p.allocatedBearersLocked(bearerName) {
+		if availability.Conflict(bearer, knownBearer) {
+			log.Println("Bearers are overlapping.")
+
+			return false
+		}
+	}
+
+	for _, contactWindow := range p.contactWindows {
+		if windowCoversBearer(contactWindow, bearer, bearer.Interval.StartTime.AsTime()) {
+			return true
+		}
+	}
+
+	log.Println("Could not find any sufficient contact window.")
+
+	return false
+}
+
+// windowCoversBearer reports whether the contact window covers the interval of
+// the bearer from the given time on, and its frequencies and bandwidths.
+func windowCoversBearer(contactWindow *pb.ContactWindow, bearer *pb.Bearer, from time.Time) bool {
+	if contactWindow.Target != bearer.Target || contactWindow.Transceiver != bearer.Transceiver {
+		return false
+	}
+
+	if contactWindow.Interval.StartTime.AsTime().After(maxTime(from, bearer.Interval.StartTime.AsTime())) ||
+		contactWindow.Interval.EndTime.AsTime().Before(bearer.Interval.EndTime.AsTime()) {
+		return false
+	}
+
+	return contactWindow.MinRxCenterFrequencyHz <= bearer.RxCenterFrequencyHz &&
+		contactWindow.MaxRxCenterFrequencyHz >= bearer.RxCenterFrequencyHz &&
+		contactWindow.MinRxBandwidthHz <= bearer.RxBandwidthHz &&
+		contactWindow.MaxRxBandwidthHz >= bearer.RxBandwidthHz &&
+		contactWindow.MinTxCenterFrequencyHz <= bearer.TxCenterFrequencyHz &&
+		contactWindow.MaxTxCenterFrequencyHz >= bearer.TxCenterFrequencyHz &&
+		contactWindow.MinTxBandwidthHz <= bearer.TxBandwidthHz &&
+		contactWindow.MaxTxBandwidthHz >= bearer.TxBandwidthHz
+}
+
+// allocatedBearersLocked returns the bearers and the bearers of reservations
+// which hold capacity, except the bearer or reservation with the given name.
+// Released bearers and expired reservations do not hold capacity.
+func (p *PrototypeHandler) allocatedBearersLocked(exclude string) []*pb.Bearer {
+	p.expireReservationsLocked()
+	allocated := make([]*pb.Bearer, 0, len(p.bearers)+len(p.reservations))
+	for name, bearer := range p.bearers {
+		if name != exclude && !isReleased(bearer.State) {
+			allocated = append(allocated, bearer)
+		}
+	}
+	for name, reservation := range p.reservations {
+		if name != exclude {
+			allocated = append(allocated, reservation.Bearer)
+		}
+	}
+	return allocated
+}
+
+// ReserveBearer holds the capacity of the bearer until the reservation is
+// committed, released or expires.
+func (p *PrototypeHandler) ReserveBearer(_ context.Context, request *pb.ReserveBearerRequest) (*pb.Reservation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expireReservationsLocked()
+	if err := resourcename.ValidateID("reservation_id", request.ReservationId); err != nil {
+		return nil, err
+	}
+	name := resourcename.Reservation.Format(request.ReservationId)
+	if p.reservations[name] != nil {
+		return nil, status.Errorf(codes.AlreadyExists, "reservation with requested ID was already created")
+	}
+	bearer := request.Reservation.GetBearer()
+	if bearer.GetInterval().GetStartTime() == nil || bearer.GetInterval().GetEndTime() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bearer interval is required")
+	}
+	if bearer.Interval.StartTime.AsTime().After(bearer.Interval.EndTime.AsTime()) {
+		return nil, status.Errorf(codes.InvalidArgument, "bearer has negative time interval argument")
+	}
+	now := p.now()
+	expireTime := now.Add(defaultReservationTTL)
+	switch expiration := request.Reservation.Expiration.(type) {
+	case *pb.Reservation_ExpireTime:
+		expireTime = expiration.ExpireTime.AsTime()
+	case *pb.Reservation_Ttl:
+		expireTime = now.Add(expiration.Ttl.AsDuration())
+	}
+	if !expireTime.After(now) {
+		return nil, status.Errorf(codes.InvalidArgument, "reservation must expire in the future")
+	}
+	if !p.checkForSufficientContactWindow(bearer, name) {
+		return nil, insufficientContactWindow(bearer)
+	}
+
+	// Only the specification of the bearer is reserved.
+	reserved := proto.Clone(bearer).(*pb.Bearer)
+	reserved.Name = ""
+	reserved.Etag = ""
+	reserved.State = pb.LifecycleState_LIFECYCLE_STATE_UNSPECIFIED
+	reserved.StateReason = ""
+	reservation := &pb.Reservation{
+		Name:       name,
+		Bearer:     reserved,
+		Expiration: &pb.Reservation_ExpireTime{ExpireTime: timestamppb.New(expireTime)},
+	}
+	etag.Set(reservation)
+	p.reservations[name] = reservation
+	p.expireReservationsLocked()
+
+	return reservation, nil
+}
+
+func (p *PrototypeHandler) GetReservation(_ context.Context, request *pb.GetReservationRequest) (*pb.Reservation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expireReservationsLocked()
+	if _, err := resourcename.Reservation.Parse("name", request.Name); err != nil {
+		return nil, err
+	}
+	if p.reservations[request.Name] == nil {
+		return nil, status.Errorf(codes.NotFound, "reservation with requested ID was not found")
+	}
+
+	return p.reservations[request.Name], nil
+}
+
+// CommitReservation creates the bearer of the reservation in place of the
+// reservation.
+func (p *PrototypeHandler) CommitReservation(_ context.Context, request *pb.CommitReservationRequest) (*pb.Bearer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := resourcename.Reservation.Parse("name", request.Name); err != nil {
+		return nil, err
+	}
+	p.expireReservationsLocked()
+	reservation := p.reservations[request.Name]
+	if reservation == nil {
+		return nil, status.Errorf(codes.NotFound, "reservation with requested ID was not found")
+	}
+	if err := etag.Check(reservation, request.Etag); err != nil {
+		return nil, err
+	}
+	create := &pb.CreateBearerRequest{
+		BearerId: request.BearerId,
+		Bearer:   proto.Clone(reservation.Bearer).(*pb.Bearer),
+	}
+	if err := resourcename.ValidateID("bearer_id", request.BearerId); err != nil {
+		return nil, err
+	}
+	if p.bearers[resourcename.Bearer.Format(request.BearerId)] != nil {
+		return nil, status.Errorf(codes.AlreadyExists, "bearer with requested ID was already created")
+	}
+	// The contact windows may have changed since the reservation was made.
+	if !p.checkForSufficientContactWindow(create.Bearer, reservation.Name) {
+		return nil, insufficientContactWindow(create.Bearer)
+	}
+
+	delete(p.reservations, reservation.Name)
+	p.expireReservationsLocked()
+	p.createBearerLocked(create)
+
+	return create.Bearer, nil
+}
+
+// ReleaseReservation deletes the reservation and frees its capacity.
+func (p *PrototypeHandler) ReleaseReservation(_ context.Context, request *pb.ReleaseReservationRequest) (*emptypb.Empty, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := resourcename.Reservation.Parse("name", request.Name); err != nil {
+		return nil, err
+	}
+	p.expireReservationsLocked()
+	reservation := p.reservations[request.Name]
+	if reservation == nil {
+		return nil, status.Errorf(codes.NotFound, "reservation with requested ID was not found")
+	}
+	if err := etag.Check(reservation, request.Etag); err != nil {
+		return nil, err
+	}
+
+	delete(p.reservations, reservation.Name)
+	p.expireReservationsLocked()
+
+	return &emptypb.Empty{}, nil
+}
+
+// expireReservationsLocked releases the expired reservations, and schedules
+// the next expiry.
+func (p *PrototypeHandler) expireReservationsLocked() {
+	now := p.now()
+	var next time.Time
+	for name, reservation := range p.reservations {
+		expireTime := reservation.GetExpireTime().AsTime()
+		if !expireTime.After(now) {
+			delete(p.reservations, name)
+			continue
+		}
+		if next.IsZero() || expireTime.Before(next) {
+			next = expireTime
+		}
+	}
+
+	if p.reservationTimer != nil {
+		p.reservationTimer.Stop()
+	}
+	if !next.IsZero() {
+		p.reservationTimer = time.AfterFunc(next.Sub(now), p.expireReservations)
+	}
+}
+
+func (p *PrototypeHandler) expireReservations() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expireReservationsLocked()
+}
+
+// UpdateBearer replaces the bearer, or only the fields selected by the update
+// mask, if the updated bearer still resides within a contact window and covers
+// its attachment circuits.
+func (p *PrototypeHandler) UpdateBearer(_ context.Context, request *pb.UpdateBearerRequest) (*pb.Bearer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := fieldmask.Validate(request.UpdateMask, (&pb.Bearer{}).ProtoReflect().Descriptor(), "etag"); err != nil {
+		return nil, err
+	}
+	if _, err := resourcename.Bearer.Parse("bearer.name", request.Bearer.GetName()); err != nil {
+		return nil, err
+	}
+	current := p.bearers[request.Bearer.Name]
+	if current == nil {
+		return nil, status.Errorf(codes.NotFound, "bearer with requested ID was not found")
+	}
+	if err := etag.Check(current, request.Bearer.Etag); err != nil {
+		return nil, err
+	}
+	if p.provisioning[current.Name] || isReleased(current.State) || current.DeleteTime != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "bearer is %v and cannot be updated", current.State)
+	}
+	updated := proto.Clone(current).(*pb.Bearer)
+	fieldmask.Apply(updated, request.Bearer, request.UpdateMask)
+	updated.Name = current.Name
+	if fieldmask.IsFull(request.UpdateMask) {
+		if err := fieldmask.CheckImmutable(current, updated); err != nil {
+			return nil, err
+		}
+	}
+
+	if updated.Interval.GetStartTime() == nil || updated.Interval.GetEndTime() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bearer interval is required")
+	}
+	if updated.Interval.StartTime.AsTime().After(updated.Interval.EndTime.AsTime()) {
+		return nil, status.Errorf(codes.InvalidArgument, "bearer has negative time interval argument")
+	}
+	if !p.checkForSufficientContactWindow(updated, updated.Name) {
+		return nil, insufficientContactWindow(updated)
+	}
+	for _, circuit := range p.attachmentCircuits {
+		// Soft-deleted attachment circuits are checked when they are restored.
+		if circuit.L2Connection.Bearer != updated.Name || circuit.DeleteTime != nil {
+			continue
+		}
+		if updated.Interval.StartTime.AsTime().After(circuit.Interval.StartTime.AsTime()) ||
+			updated.Interval.EndTime.AsTime().Before(circuit.Interval.EndTime.AsTime()) {
+			return nil, status.Errorf(codes.FailedPrecondition, "bearer would no longer cover the interval of attachment circuit %s", circuit.Name)
+		}
 	}
 
-	return nil
+	updated.State, updated.StateReason = p.bearerState(updated, p.now())
+	// The bearer fits into a contact window again.
+	updated.AtRisk = false
+	etag.Set(updated)
+	p.bearers[updated.Name] = updated
+	p.bearerHub.Modified(current, updated)
+	p.refreshStatesLocked()
+
+	return updated, nil
 }
 
-func (p *PrototypeHandler) UpdateTransceiver(_ context.Context, trans *pb.UpdateTransceiverRequest) (*pb.Transceiver, error) {
+// DeleteBearer soft-deletes the bearer, which keeps its capacity until it is
+// purged. With force, its attachment circuits are soft-deleted as well.
+func (p *PrototypeHandler) DeleteBearer(_ context.Context, bearer *pb.DeleteBearerRequest) (*pb.DeleteBearerResponse, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if p.transceivers[trans.Transceiver.Name] == nil {
-		return nil, status.Errorf(codes.NotFound, "transceiver with requested ID was not found")
+	if _, err := resourcename.Bearer.Parse("name", bearer.Name); err != nil {
+		return nil, err
 	}
-	if err := checkForAdmissibleTransceiver(trans.Transceiver); err != nil {
+	if p.bearers[bearer.Name] == nil || p.bearers[bearer.Name].DeleteTime != nil {
+		return nil, status.Errorf(codes.NotFound, "bearer with requested ID was not found")
+	}
+	if err := etag.Check(p.bearers[bearer.Name], bearer.Etag); err != nil {
 		return nil, err
 	}
 
-	for _, bearer := range p.bearers {
-		// In this example, we simply prohibit that a client update their transceiver if it is used in a connection.
-		// In a real API implementation, more complicated logic could be applied to ensure that it is actually possible to update.
-		if bearer.Transceiver == trans.Transceiver.Name {
-			return nil, status.Error(codes.FailedPrecondition, "transceiver has bearer attached and cannot be updated")
-		}
+	c := p.newCascade()
+	if err := c.deleteBearer(bearer.Name, bearer.Force); err != nil {
+		c.rollback()
+		return nil, err
 	}
 
-	p.transceivers[trans.Transceiver.Name] = trans.Transceiver
-
-	return trans.Transceiver, nil
+	return &pb.DeleteBearerResponse{DeletedResources: c.commit()}, nil
 }
 
-func (p *PrototypeHandler) DeleteTransceiver(_ context.Context, trans *pb.DeleteTransceiverRequest) (*emptypb.Empty, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// cascade soft-deletes a resource together with the resources that depend on
+// it. The deleted resources are only published to Watch streams when the
+// cascade is committed, and are restored when it is rolled back.
+type cascade struct {
+	p *PrototypeHandler
+	// deleteTime and purgeTime are set on all resources deleted by the cascade.
+	deleteTime, purgeTime *timestamppb.Timestamp
+	// contactWindows are the contact windows before the cascade.
+	contactWindows   []*pb.ContactWindow
+	deletedResources []string
+
+	// transceivers, bearers and attachmentCircuits are the deleted resources
+	// as they were before the cascade.
+	transceivers       []*pb.Transceiver
+	bearers            []*pb.Bearer
+	attachmentCircuits []*pb.AttachmentCircuit
+	deletedWindows     []*pb.ContactWindow
+}
 
-	if p.transceivers[trans.Name] == nil {
-		return nil, status.Errorf(codes.NotFound, "transceiver with requested ID was not found")
+func (p *PrototypeHandler) newCascade() *cascade {
+	now := p.now()
+	return &cascade{
+		p:              p,
+		deleteTime:     timestamppb.New(now),
+		purgeTime:      timestamppb.New(now.Add(p.deleteGracePeriod)),
+		contactWindows: p.contactWindows,
 	}
-	for _, bearer := range p.bearers {
+}
+
+func (c *cascade) deleteTransceiver(name string, force bool) error {
+	for _, bearerName := range slices.Sorted(maps.Keys(c.p.bearers)) {
 		// In order to ensure that the connection setup is valid, we need to check for attached bearers.
-		if bearer.Transceiver == trans.Name {
-			return nil, status.Error(codes.FailedPrecondition, "transceiver has bearer attached and cannot be deleted")
+		if c.p.bearers[bearerName].Transceiver != name || c.p.bearers[bearerName].DeleteTime != nil {
+			continue
+		}
+		if !force {
+			return status.Error(codes.FailedPrecondition, "transceiver has bearer attached and cannot be deleted")
+		}
+		if err := c.deleteBearer(bearerName, true); err != nil {
+			return err
 		}
 	}
 
-	delete(p.transceivers, trans.Name)
-
-	newWindows := make([]*pb.ContactWindow, 0, len(p.contactWindows))
-	for _, window := range p.contactWindows {
-		if window.Transceiver != trans.Name {
+	newWindows := make([]*pb.ContactWindow, 0, len(c.p.contactWindows))
+	for _, window := range c.p.contactWindows {
+		if window.Transceiver != name {
 			newWindows = append(newWindows, window)
+		} else {
+			c.deletedWindows = append(c.deletedWindows, window)
+			c.deletedResources = append(c.deletedResources, window.Name)
 		}
 	}
-	p.contactWindows = newWindows
+	c.p.contactWindows = newWindows
+
+	transceiver := c.p.transceivers[name]
+	c.transceivers = append(c.transceivers, transceiver)
+	c.deletedResources = append(c.deletedResources, name)
+	deleted := proto.Clone(transceiver).(*pb.Transceiver)
+	deleted.DeleteTime, deleted.PurgeTime = c.deleteTime, c.purgeTime
+	etag.Set(deleted)
+	c.p.transceivers[name] = deleted
+	return nil
+}
 
-	return &emptypb.Empty{}, nil
+func (c *cascade) deleteBearer(name string, force bool) error {
+	if c.p.provisioning[name] {
+		return status.Errorf(codes.FailedPrecondition, "%s is being provisioned, cancel its operation first", name)
+	}
+	for _, circuitName := range slices.Sorted(maps.Keys(c.p.attachmentCircuits)) {
+		// In order to ensure that the connection setup is valid, we need to check attached bearers.
+		if c.p.attachmentCircuits[circuitName].L2Connection.Bearer != name || c.p.attachmentCircuits[circuitName].DeleteTime != nil {
+			continue
+		}
+		if !force {
+			return status.Error(codes.FailedPrecondition, "bearer has attachment circuit attached and cannot be deleted")
+		}
+		if err := c.deleteAttachmentCircuit(circuitName); err != nil {
+			return err
+		}
+	}
+
+	bearer := c.p.bearers[name]
+	c.bearers = append(c.bearers, bearer)
+	c.deletedResources = append(c.deletedResources, name)
+	deleted := proto.Clone(bearer).(*pb.Bearer)
+	deleted.DeleteTime, deleted.PurgeTime = c.deleteTime, c.purgeTime
+	deleted.State, deleted.StateReason = c.p.bearerState(deleted, c.deleteTime.AsTime())
+	etag.Set(deleted)
+	c.p.bearers[name] = deleted
+	return nil
 }
 
-func (p *PrototypeHandler) ListContactWindows(_ context.Context, request *pb.ListContactWindowsRequest) (*pb.ListContactWindowsResponse, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// deleteAttachmentCircuit deletes an attachment circuit of a deleted bearer.
+func (c *cascade) deleteAttachmentCircuit(name string) error {
+	if c.p.provisioning[name] {
+		return status.Errorf(codes.FailedPrecondition, "%s is being provisioned, cancel its operation first", name)
+	}
+	c.softDeleteAttachmentCircuit(name)
+	return nil
+}
+
+// softDeleteAttachmentCircuit deletes an attachment circuit.
+func (c *cascade) softDeleteAttachmentCircuit(name string) {
+	circuit := c.p.attachmentCircuits[name]
+	c.attachmentCircuits = append(c.attachmentCircuits, circuit)
+	c.deletedResources = append(c.deletedResources, name)
+	deleted := proto.Clone(circuit).(*pb.AttachmentCircuit)
+	deleted.DeleteTime, deleted.PurgeTime = c.deleteTime, c.purgeTime
+	deleted.State, deleted.StateReason = c.p.attachmentCircuitState(deleted)
+	etag.Set(deleted)
+	c.p.attachmentCircuits[name] = deleted
+}
 
-	if request.Filter != "" {
-		return nil, status.Errorf(codes.Unimplemented, "filters are not yet implemented")
+// rollback restores the deleted resources.
+func (c *cascade) rollback() {
+	for _, circuit := range c.attachmentCircuits {
+		c.p.attachmentCircuits[circuit.Name] = circuit
+	}
+	for _, bearer := range c.bearers {
+		c.p.bearers[bearer.Name] = bearer
 	}
+	for _, transceiver := range c.transceivers {
+		c.p.transceivers[transceiver.Name] = transceiver
+	}
+	c.p.contactWindows = c.contactWindows
+}
 
-	return &pb.ListContactWindowsResponse{
-		ContactWindows: p.contactWindows,
-	}, nil
+// commit publishes the deleted resources and returns their names in the order
+// in which they were deleted. The resources that were deleted together with
+// the last one are remembered, so that they are restored together with it.
+func (c *cascade) commit() []string {
+	var dependents []string
+	for _, circuit := range c.attachmentCircuits {
+		c.p.attachmentCircuitHub.Deleted(circuit)
+		dependents = append(dependents, circuit.Name)
+	}
+	for _, bearer := range c.bearers {
+		c.p.bearerHub.Deleted(bearer)
+		dependents = append(dependents, bearer.Name)
+	}
+	for _, window := range c.deletedWindows {
+		c.p.contactWindowHub.Deleted(window)
+	}
+	for _, transceiver := range c.transceivers {
+		c.p.deletedContactWindows[transceiver.Name] = c.deletedWindows
+	}
+
+	root := c.deletedResources[len(c.deletedResources)-1]
+	dependents = slices.DeleteFunc(dependents, func(name string) bool { return name == root })
+	if len(dependents) > 0 {
+		c.p.cascades[root] = dependents
+	}
+	c.p.purgeDeletedLocked()
+	return c.deletedResources
 }
 
-func (p *PrototypeHandler) ListBearers(_ context.Context, request *pb.ListBearersRequest) (*pb.ListBearersResponse, error) {
+// UndeleteTransceiver restores a soft-deleted transceiver together with its
+// contact windows and the bearers and attachment circuits that were deleted
+// with it.
+func (p *PrototypeHandler) UndeleteTransceiver(_ context.Context, request *pb.UndeleteTransceiverRequest) (*pb.Transceiver, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if request.Filter != "" {
-		return nil, status.Errorf(codes.Unimplemented, "filters are not yet implemented")
+	if _, err := resourcename.Transceiver.Parse("name", request.Name); err != nil {
+		return nil, err
 	}
-
-	bearers := make([]*pb.Bearer, 0, len(p.bearers))
-	for _, bearer := range p.bearers {
-		bearers = append(bearers, bearer)
+	p.purgeDeletedLocked()
+	current := p.transceivers[request.Name]
+	if current == nil {
+		return nil, status.Errorf(codes.NotFound, "transceiver with requested ID was not found")
+	}
+	if current.DeleteTime == nil {
+		return nil, status.Errorf(codes.AlreadyExists, "transceiver is not deleted")
+	}
+	if err := etag.Check(current, request.Etag); err != nil {
+		return nil, err
 	}
 
-	return &pb.ListBearersResponse{Bearers: bearers}, nil
+	restored := proto.Clone(current).(*pb.Transceiver)
+	restored.DeleteTime, restored.PurgeTime = nil, nil
+	etag.Set(restored)
+	p.transceivers[restored.Name] = restored
+	p.replaceContactWindows(restored.Name, p.deletedContactWindows[restored.Name])
+	delete(p.deletedContactWindows, restored.Name)
+	p.restoreDependentsLocked(restored.Name)
+
+	return restored, nil
 }
 
-func (p *PrototypeHandler) GetBearer(_ context.Context, bearer *pb.GetBearerRequest) (*pb.Bearer, error) {
+// UndeleteBearer restores a soft-deleted bearer together with the attachment
+// circuits that were deleted with it. The capacity of the bearer was kept
+// while it was deleted, but its contact window may have changed since.
+func (p *PrototypeHandler) UndeleteBearer(_ context.Context, request *pb.UndeleteBearerRequest) (*pb.Bearer, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if p.bearers[bearer.Name] == nil {
+	if _, err := resourcename.Bearer.Parse("name", request.Name); err != nil {
+		return nil, err
+	}
+	p.purgeDeletedLocked()
+	current := p.bearers[request.Name]
+	if current == nil {
 		return nil, status.Errorf(codes.NotFound, "bearer with requested ID was not found")
 	}
+	if current.DeleteTime == nil {
+		return nil, status.Errorf(codes.AlreadyExists, "bearer is not deleted")
+	}
+	if err := etag.Check(current, request.Etag); err != nil {
+		return nil, err
+	}
+	if transceiver := p.transceivers[current.Transceiver]; transceiver == nil || transceiver.DeleteTime != nil {
+		return nil, preconditionFailure("the transceiver of the bearer is deleted", &errdetails.PreconditionFailure_Violation{
+			Type:        "TRANSCEIVER",
+			Subject:     current.Transceiver,
+			Description: "undelete the transceiver instead, which restores its bearers",
+		})
+	}
+	if !isReleased(current.State) && !p.checkForSufficientContactWindow(current, current.Name) {
+		return nil, insufficientContactWindow(current)
+	}
 
-	return p.bearers[bearer.Name], nil
+	restored := p.restoreBearerLocked(current)
+	p.restoreDependentsLocked(restored.Name)
+
+	return p.bearers[restored.Name], nil
 }
 
-func (p *PrototypeHandler) CreateBearer(_ context.Context, bearer *pb.CreateBearerRequest) (*pb.Bearer, error) {
+// UndeleteAttachmentCircuit restores a soft-deleted attachment circuit if its
+// bearer still covers it.
+func (p *PrototypeHandler) UndeleteAttachmentCircuit(_ context.Context, request *pb.UndeleteAttachmentCircuitRequest) (*pb.AttachmentCircuit, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	bearerName := fmt.Sprintf("bearers/%s", bearer.BearerId)
-	if p.bearers[bearerName] != nil {
-		return nil, status.Errorf(codes.AlreadyExists, "bearer with requested ID was already created")
+	if _, err := resourcename.AttachmentCircuit.Parse("name", request.Name); err != nil {
+		return nil, err
 	}
-	if bearer.Bearer.Interval.StartTime.AsTime().After(bearer.Bearer.Interval.EndTime.AsTime()) {
-		return nil, status.Errorf(codes.InvalidArgument, "bearer has negative time interval argument")
+	p.purgeDeletedLocked()
+	current := p.attachmentCircuits[request.Name]
+	if current == nil {
+		return nil, status.Errorf(codes.NotFound, "attachment circuit with requested ID was not found")
 	}
-	if !p.checkForSufficientContactWindow(bearer.Bearer) {
-		return nil, status.Errorf(codes.FailedPrecondition, "bearer has no sufficient contact window")
+	if current.DeleteTime == nil {
+		return nil, status.Errorf(codes.AlreadyExists, "attachment circuit is not deleted")
+	}
+	if err := etag.Check(current, request.Etag); err != nil {
+		return nil, err
+	}
+	if !isReleased(current.State) && !p.checkForSufficientBearer(current) {
+		return nil, insufficientBearer(current)
 	}
-	// Override the name of the attachment circuit to ensure that it has the correct resource name.
-	// It is up to the API to either validate the correctness of the name or just override it on creation.
-	bearer.Bearer.Name = bearerName
 
-	p.bearers[bearerName] = bearer.Bearer
+	return p.restoreAttachmentCircuitLocked(current), nil
+}
 
-	return bearer.Bearer, nil
+// restoreBearerLocked replaces a soft-deleted bearer by a restored copy and
+// publishes it.
+func (p *PrototypeHandler) restoreBearerLocked(bearer *pb.Bearer) *pb.Bearer {
+	restored := proto.Clone(bearer).(*pb.Bearer)
+	restored.DeleteTime, restored.PurgeTime = nil, nil
+	if restored.State == pb.LifecycleState_LIFECYCLE_STATE_DELETED {
+		restored.State = pb.LifecycleState_LIFECYCLE_STATE_UNSPECIFIED
+	}
+	restored.State, restored.StateReason = p.bearerState(restored, p.now())
+	etag.Set(restored)
+	p.bearers[restored.Name] = restored
+	p.bearerHub.Added(restored)
+	return restored
 }
 
-func (p *PrototypeHandler) checkForSufficientContactWindow(bearer *pb.Bearer) bool {
-	for _, contactWindow := range p.contactWindows {
-		if contactWindow.Target != bearer.Target || contactWindow.Transceiver != bearer.Transceiver {
-			continue
-		}
+// restoreAttachmentCircuitLocked replaces a soft-deleted attachment circuit by
+// a restored copy and publishes it. Its bearer must be restored first.
+func (p *PrototypeHandler) restoreAttachmentCircuitLocked(circuit *pb.AttachmentCircuit) *pb.AttachmentCircuit {
+	restored := proto.Clone(circuit).(*pb.AttachmentCircuit)
+	restored.DeleteTime, restored.PurgeTime = nil, nil
+	if restored.State == pb.LifecycleState_LIFECYCLE_STATE_DELETED {
+		restored.State = pb.LifecycleState_LIFECYCLE_STATE_UNSPECIFIED
+	}
+	restored.State, restored.StateReason = p.attachmentCircuitState(restored)
+	etag.Set(restored)
+	p.attachmentCircuits[restored.Name] = restored
+	p.attachmentCircuitHub.Added(restored)
+	return restored
+}
 
-		if contactWindow.Interval.StartTime.AsTime().After(bearer.Interval.StartTime.AsTime()) ||
-			contactWindow.Interval.EndTime.AsTime().Before(bearer.Interval.EndTime.AsTime()) {
-			continue
+// restoreDependentsLocked restores the resources that were soft-deleted
+// together with the named resource. Bearers are restored before their
+// attachment circuits. Resources which were removed in the meantime, e.g. by
+// the reaper, are skipped.
+func (p *PrototypeHandler) restoreDependentsLocked(name string) {
+	dependents := p.cascades[name]
+	delete(p.cascades, name)
+	for _, dependent := range slices.Backward(dependents) {
+		if bearer := p.bearers[dependent]; bearer.GetDeleteTime() != nil {
+			p.restoreBearerLocked(bearer)
 		}
-
-		if contactWindow.MinRxCenterFrequencyHz > bearer.RxCenterFrequencyHz ||
-			contactWindow.MaxRxCenterFrequencyHz < bearer.RxCenterFrequencyHz ||
-			contactWindow.MinRxBandwidthHz > bearer.RxBandwidthHz ||
-			contactWindow.MaxRxBandwidthHz < bearer.RxBandwidthHz ||
-			contactWindow.MinTxCenterFrequencyHz > bearer.TxCenterFrequencyHz ||
-			contactWindow.MaxTxCenterFrequencyHz < bearer.TxCenterFrequencyHz ||
-			contactWindow.MinTxBandwidthHz > bearer.TxBandwidthHz ||
-			contactWindow.MaxTxBandwidthHz < bearer.TxBandwidthHz {
-			continue
+	}
+	for _, dependent := range slices.Backward(dependents) {
+		if circuit := p.attachmentCircuits[dependent]; circuit.GetDeleteTime() != nil {
+			p.restoreAttachmentCircuitLocked(circuit)
 		}
+	}
+	p.refreshStatesLocked()
+}
 
-		for _, knownBearer := range p.bearers {
-			if bearer.Target != knownBearer.Target || bearer.Transceiver != knownBearer.Transceiver {
-				continue
-			}
-
-			if math.Abs(float64(knownBearer.RxCenterFrequencyHz-bearer.RxCenterFrequencyHz))-(float64(knownBearer.RxBandwidthHz)+float64(bearer.RxBandwidthHz))/2 > 0 &&
-				math.Abs(float64(knownBearer.TxCenterFrequencyHz-bearer.TxCenterFrequencyHz))-(float64(knownBearer.TxBandwidthHz)+float64(bearer.TxBandwidthHz))/2 > 0 {
-				return true
-			}
-
-			if knownBearer.Interval.EndTime.AsTime().After(bearer.Interval.StartTime.AsTime()) && bearer.Interval.StartTime.AsTime().After(knownBearer.Interval.StartTime.AsTime()) ||
-				knownBearer.Interval.EndTime.AsTime().After(bearer.Interval.EndTime.AsTime()) && bearer.Interval.EndTime.AsTime().After(knownBearer.Interval.StartTime.AsTime()) ||
-				bearer.Interval.EndTime.AsTime().After(knownBearer.Interval.EndTime.AsTime()) && knownBearer.Interval.StartTime.AsTime().After(bearer.Interval.StartTime.AsTime()) {
-				log.Println("Bearers are overlapping.")
-
-				return false
+// purgeDeletedLocked removes the soft-deleted resources whose grace period
+// ended, and schedules the next purge.
+func (p *PrototypeHandler) purgeDeletedLocked() {
+	now := p.now()
+	var next time.Time
+	purge := func(name string, purgeTime *timestamppb.Timestamp) bool {
+		if purgeTime == nil {
+			return false
+		}
+		if t := purgeTime.AsTime(); t.After(now) {
+			if next.IsZero() || t.Before(next) {
+				next = t
 			}
+			return false
 		}
-
+		delete(p.cascades, name)
 		return true
 	}
+	for name, circuit := range p.attachmentCircuits {
+		if purge(name, circuit.PurgeTime) {
+			delete(p.attachmentCircuits, name)
+		}
+	}
+	for name, bearer := range p.bearers {
+		if purge(name, bearer.PurgeTime) {
+			delete(p.bearers, name)
+		}
+	}
+	for name, transceiver := range p.transceivers {
+		if purge(name, transceiver.PurgeTime) {
+			delete(p.transceivers, name)
+			delete(p.deletedContactWindows, name)
+		}
+	}
 
-	log.Println("Could not find any sufficient contact window.")
-
-	return false
+	if p.purgeTimer != nil {
+		p.purgeTimer.Stop()
+	}
+	if !next.IsZero() {
+		p.purgeTimer = time.AfterFunc(next.Sub(now), p.purgeDeleted)
+	}
 }
 
-func (p *PrototypeHandler) DeleteBearer(_ context.Context, bearer *pb.DeleteBearerRequest) (*emptypb.Empty, error) {
+func (p *PrototypeHandler) purgeDeleted() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if p.bearers[bearer.Name] == nil {
-		return nil, status.Errorf(codes.NotFound, "bearer with requested ID was not found")
-	}
-	for _, ac := range p.attachmentCircuits {
-		// In order to ensure that the connection setup is valid, we need to check attached bearers.
-		if bearer.Name == ac.L2Connection.Bearer {
-			return nil, status.Error(codes.FailedPrecondition, "bearer has attachment circuit attached and cannot be deleted")
-		}
-	}
-	delete(p.bearers, bearer.Name)
-
-	return &emptypb.Empty{}, nil
+	p.purgeDeletedLocked()
 }
 
 func (p *PrototypeHandler) ListAttachmentCircuits(_ context.Context, request *pb.ListAttachmentCircuitsRequest) (*pb.ListAttachmentCircuitsResponse, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if request.Filter != "" {
-		return nil, status.Errorf(codes.Unimplemented, "filters are not yet implemented")
-	}
-
+	p.purgeDeletedLocked()
 	attachmentCircuits := make([]*pb.AttachmentCircuit, 0, len(p.attachmentCircuits))
 	for _, circuit := range p.attachmentCircuits {
-		attachmentCircuits = append(attachmentCircuits, circuit)
+		if request.ShowDeleted || circuit.DeleteTime == nil {
+			attachmentCircuits = append(attachmentCircuits, circuit)
+		}
+	}
+	attachmentCircuits, err := handler.ApplyFilter(request.Filter, attachmentCircuits)
+	if err != nil {
+		return nil, err
+	}
+	attachmentCircuits, nextPageToken, err := pagination.Paginate(p.paginator, pagination.Request{
+		PageSize:  request.PageSize,
+		PageToken: request.PageToken,
+		Query:     []string{request.Filter, strconv.FormatBool(request.ShowDeleted)},
+	}, attachmentCircuits, (*pb.AttachmentCircuit).GetName)
+	if err != nil {
+		return nil, err
 	}
 
-	return &pb.ListAttachmentCircuitsResponse{AttachmentCircuits: attachmentCircuits}, nil
+	return &pb.ListAttachmentCircuitsResponse{AttachmentCircuits: attachmentCircuits, NextPageToken: nextPageToken}, nil
+}
+
+func (p *PrototypeHandler) WatchAttachmentCircuits(request *pb.WatchAttachmentCircuitsRequest, stream pb.InterconnectService_WatchAttachmentCircuitsServer) error {
+	p.mu.Lock()
+	sub, resumed := p.attachmentCircuitHub.Subscribe(request.ResumeToken)
+	var snapshot []*pb.AttachmentCircuit
+	if !resumed {
+		snapshot = make([]*pb.AttachmentCircuit, 0, len(p.attachmentCircuits))
+		for _, circuit := range p.attachmentCircuits {
+			if circuit.DeleteTime == nil {
+				snapshot = append(snapshot, circuit)
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	return watch.Serve(stream.Context(), sub, snapshot, request.Filter, func(typ pb.WatchEventType, circuit *pb.AttachmentCircuit, resumeToken string) error {
+		return stream.Send(&pb.WatchAttachmentCircuitsResponse{Type: typ, AttachmentCircuit: circuit, ResumeToken: resumeToken})
+	})
 }
 
 func (p *PrototypeHandler) GetAttachmentCircuit(_ context.Context, circuit *pb.GetAttachmentCircuitRequest) (*pb.AttachmentCircuit, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if _, err := resourcename.AttachmentCircuit.Parse("name", circuit.Name); err != nil {
+		return nil, err
+	}
+	p.purgeDeletedLocked()
 	if p.attachmentCircuits[circuit.Name] == nil {
 		return nil, status.Errorf(codes.NotFound, "attachment circuit with requested ID was not found")
 	}
@@ -373,25 +1849,95 @@ func (p *PrototypeHandler) CreateAttachmentCircuit(_ context.Context, ac *pb.Cre
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	attachmentCircuitName := fmt.Sprintf("attachmentCircuits/%s", ac.AttachmentCircuitId)
-	if p.attachmentCircuits[attachmentCircuitName] != nil {
-		return nil, status.Errorf(codes.AlreadyExists, "attachment circuit with requested ID was already created")
+	if err := p.checkAttachmentCircuit(ac); err != nil {
+		return nil, err
 	}
-	if !p.checkForSufficientBearer(ac.AttachmentCircuit) {
-		return nil, status.Errorf(codes.FailedPrecondition, "attachment circuit is not attached to existing bearer covering the provisioning window")
+	if ac.ValidateOnly {
+		p.initAttachmentCircuitLocked(ac)
+		return ac.AttachmentCircuit, nil
 	}
+	p.createAttachmentCircuitLocked(ac)
+
+	return ac.AttachmentCircuit, nil
+}
+
+// createAttachmentCircuitLocked stores a checked attachment circuit in its
+// initial state.
+func (p *PrototypeHandler) createAttachmentCircuitLocked(ac *pb.CreateAttachmentCircuitRequest) {
+	p.initAttachmentCircuitLocked(ac)
+	p.attachmentCircuits[ac.AttachmentCircuit.Name] = ac.AttachmentCircuit
+	p.attachmentCircuitHub.Added(ac.AttachmentCircuit)
+}
 
+// initAttachmentCircuitLocked sets the name, initial state and etag of an
+// attachment circuit that is created.
+func (p *PrototypeHandler) initAttachmentCircuitLocked(ac *pb.CreateAttachmentCircuitRequest) {
 	// Override the name of the attachment circuit to ensure that it has the correct resource name.
 	// It is up to the API to either validate the correctness of the name or just override it on creation.
-	ac.AttachmentCircuit.Name = attachmentCircuitName
-	p.attachmentCircuits[attachmentCircuitName] = ac.AttachmentCircuit
+	ac.AttachmentCircuit.Name = resourcename.AttachmentCircuit.Format(ac.AttachmentCircuitId)
+	ac.AttachmentCircuit.State = pb.LifecycleState_LIFECYCLE_STATE_UNSPECIFIED
+	ac.AttachmentCircuit.State, ac.AttachmentCircuit.StateReason = p.attachmentCircuitState(ac.AttachmentCircuit)
+	etag.Set(ac.AttachmentCircuit)
+}
 
-	return ac.AttachmentCircuit, nil
+// checkAttachmentCircuit checks whether the attachment circuit can be created.
+func (p *PrototypeHandler) checkAttachmentCircuit(ac *pb.CreateAttachmentCircuitRequest) error {
+	if err := resourcename.ValidateID("attachment_circuit_id", ac.AttachmentCircuitId); err != nil {
+		return err
+	}
+	attachmentCircuitName := resourcename.AttachmentCircuit.Format(ac.AttachmentCircuitId)
+	if p.attachmentCircuits[attachmentCircuitName] != nil {
+		return status.Errorf(codes.AlreadyExists, "attachment circuit with requested ID was already created")
+	}
+	if !p.checkForSufficientBearer(ac.AttachmentCircuit) {
+		return insufficientBearer(ac.AttachmentCircuit)
+	}
+
+	return nil
+}
+
+// ProvisionAttachmentCircuit validates and creates the attachment circuit in
+// the PROVISIONING state. Once provisioned, the attachment circuit follows the
+// state of its bearer.
+func (p *PrototypeHandler) ProvisionAttachmentCircuit(_ context.Context, request *pb.ProvisionAttachmentCircuitRequest) (*longrunningpb.Operation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	create := &pb.CreateAttachmentCircuitRequest{AttachmentCircuitId: request.AttachmentCircuitId, AttachmentCircuit: request.AttachmentCircuit}
+	if err := p.checkAttachmentCircuit(create); err != nil {
+		return nil, err
+	}
+	name := resourcename.AttachmentCircuit.Format(request.AttachmentCircuitId)
+	// The operation cannot finish before mu is released.
+	op, err := p.operations.Start("ProvisionAttachmentCircuit", name, func(ctx context.Context) (proto.Message, error) {
+		err := p.provision(ctx)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return finishProvisioningLocked(ctx, p, name, err, p.attachmentCircuits, p.setAttachmentCircuitStateLocked)
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.provisioning[name] = true
+	p.createAttachmentCircuitLocked(create)
+
+	return op, nil
+}
+
+// insufficientBearer returns the FAILED_PRECONDITION error for an attachment
+// circuit which is not attached to a bearer covering its interval.
+func insufficientBearer(circuit *pb.AttachmentCircuit) error {
+	return preconditionFailure("attachment circuit is not attached to existing bearer covering the provisioning window", &errdetails.PreconditionFailure_Violation{
+		Type:        "BEARER",
+		Subject:     circuit.GetL2Connection().GetBearer(),
+		Description: "the bearer does not exist, is released or deleted, or does not cover the interval of the attachment circuit",
+	})
 }
 
 func (p *PrototypeHandler) checkForSufficientBearer(attachmentCircuit *pb.AttachmentCircuit) bool {
 	for _, bearer := range p.bearers {
-		if bearer.Name != attachmentCircuit.L2Connection.Bearer {
+		if bearer.Name != attachmentCircuit.L2Connection.Bearer || isReleased(bearer.State) || bearer.DeleteTime != nil {
 			continue
 		}
 
@@ -406,14 +1952,73 @@ func (p *PrototypeHandler) checkForSufficientBearer(attachmentCircuit *pb.Attach
 	return false
 }
 
+// UpdateAttachmentCircuit replaces the attachment circuit, or only the fields
+// selected by the update mask, if the updated attachment circuit still resides
+// within the interval of its bearer.
+func (p *PrototypeHandler) UpdateAttachmentCircuit(_ context.Context, request *pb.UpdateAttachmentCircuitRequest) (*pb.AttachmentCircuit, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := fieldmask.Validate(request.UpdateMask, (&pb.AttachmentCircuit{}).ProtoReflect().Descriptor(), "etag"); err != nil {
+		return nil, err
+	}
+	if _, err := resourcename.AttachmentCircuit.Parse("attachment_circuit.name", request.AttachmentCircuit.GetName()); err != nil {
+		return nil, err
+	}
+	current := p.attachmentCircuits[request.AttachmentCircuit.Name]
+	if current == nil {
+		return nil, status.Errorf(codes.NotFound, "attachment circuit with requested ID was not found")
+	}
+	if err := etag.Check(current, request.AttachmentCircuit.Etag); err != nil {
+		return nil, err
+	}
+	if p.provisioning[current.Name] || isReleased(current.State) || current.DeleteTime != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "attachment circuit is %v and cannot be updated", current.State)
+	}
+	updated := proto.Clone(current).(*pb.AttachmentCircuit)
+	fieldmask.Apply(updated, request.AttachmentCircuit, request.UpdateMask)
+	updated.Name = current.Name
+	if fieldmask.IsFull(request.UpdateMask) {
+		if err := fieldmask.CheckImmutable(current, updated); err != nil {
+			return nil, err
+		}
+	}
+
+	if updated.Interval.GetStartTime() == nil || updated.Interval.GetEndTime() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "attachment circuit interval is required")
+	}
+	if !p.checkForSufficientBearer(updated) {
+		return nil, insufficientBearer(updated)
+	}
+
+	updated.State = pb.LifecycleState_LIFECYCLE_STATE_UNSPECIFIED
+	updated.State, updated.StateReason = p.attachmentCircuitState(updated)
+	etag.Set(updated)
+	p.attachmentCircuits[updated.Name] = updated
+	p.attachmentCircuitHub.Modified(current, updated)
+
+	return updated, nil
+}
+
 func (p *PrototypeHandler) DeleteAttachmentCircuit(_ context.Context, request *pb.DeleteAttachmentCircuitRequest) (*emptypb.Empty, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if p.attachmentCircuits[request.Name] == nil {
+	if _, err := resourcename.AttachmentCircuit.Parse("name", request.Name); err != nil {
+		return nil, err
+	}
+	if p.attachmentCircuits[request.Name] == nil || p.attachmentCircuits[request.Name].DeleteTime != nil {
 		return nil, status.Errorf(codes.NotFound, "attachment circuit with requested ID was not found")
 	}
-	delete(p.attachmentCircuits, request.Name)
+	if err := etag.Check(p.attachmentCircuits[request.Name], request.Etag); err != nil {
+		return nil, err
+	}
+	if p.provisioning[request.Name] {
+		return nil, status.Errorf(codes.FailedPrecondition, "%s is being provisioned, cancel its operation first", request.Name)
+	}
+	c := p.newCascade()
+	c.softDeleteAttachmentCircuit(request.Name)
+	c.commit()
 
 	return &emptypb.Empty{}, nil
 }
@@ -422,6 +2027,9 @@ func (p *PrototypeHandler) GetTarget(_ context.Context, targetRequest *pb.GetTar
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if _, err := resourcename.Target.Parse("name", targetRequest.Name); err != nil {
+		return nil, err
+	}
 	if p.targets[targetRequest.Name] == nil {
 		return nil, status.Errorf(codes.NotFound, "could not find target")
 	}
@@ -429,7 +2037,7 @@ func (p *PrototypeHandler) GetTarget(_ context.Context, targetRequest *pb.GetTar
 	return p.targets[targetRequest.Name], nil
 }
 
-func (p *PrototypeHandler) ListTargets(context.Context, *pb.ListTargetsRequest) (*pb.ListTargetsResponse, error) {
+func (p *PrototypeHandler) ListTargets(_ context.Context, request *pb.ListTargetsRequest) (*pb.ListTargetsResponse, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -437,9 +2045,91 @@ func (p *PrototypeHandler) ListTargets(context.Context, *pb.ListTargetsRequest)
 	for _, target := range p.targets {
 		targets = append(targets, target)
 	}
+	targets, nextPageToken, err := pagination.Paginate(p.paginator, pagination.Request{
+		PageSize:  request.PageSize,
+		PageToken: request.PageToken,
+	}, targets, (*pb.Target).GetName)
+	if err != nil {
+		return nil, err
+	}
 	targetResponse := &pb.ListTargetsResponse{
-		Targets: targets,
+		Targets:       targets,
+		NextPageToken: nextPageToken,
 	}
 
 	return targetResponse, nil
 }
+
+// ReapAttachmentCircuits removes the attachment circuits whose interval ended
+// before the cutoff. Soft-deleted attachment circuits are left to be purged.
+func (p *PrototypeHandler) ReapAttachmentCircuits(cutoff time.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	reaped := 0
+	for name, circuit := range p.attachmentCircuits {
+		if p.provisioning[name] || circuit.DeleteTime != nil || !circuit.Interval.EndTime.AsTime().Before(cutoff) {
+			continue
+		}
+		p.attachmentCircuitHub.Deleted(circuit)
+		delete(p.attachmentCircuits, name)
+		reaped++
+	}
+	return reaped
+}
+
+// ReapBearers removes the bearers whose interval ended before the cutoff and
+// which have no attachment circuit attached. Soft-deleted bearers are left to
+// be purged.
+func (p *PrototypeHandler) ReapBearers(cutoff time.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	attached := make(map[string]bool, len(p.attachmentCircuits))
+	for _, circuit := range p.attachmentCircuits {
+		attached[circuit.L2Connection.Bearer] = true
+	}
+	reaped := 0
+	for name, bearer := range p.bearers {
+		if p.provisioning[name] || bearer.DeleteTime != nil || attached[name] || !bearer.Interval.EndTime.AsTime().Before(cutoff) {
+			continue
+		}
+		p.bearerHub.Deleted(bearer)
+		delete(p.bearers, name)
+		reaped++
+	}
+	return reaped
+}
+
+// ReapContactWindows removes the contact windows whose interval ended before
+// the cutoff and which do not cover a bearer.
+func (p *PrototypeHandler) ReapContactWindows(cutoff time.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	newWindows := make([]*pb.ContactWindow, 0, len(p.contactWindows))
+	for _, window := range p.contactWindows {
+		if window.Interval.EndTime.AsTime().Before(cutoff) && !p.coversBearer(window) {
+			p.contactWindowHub.Deleted(window)
+		} else {
+			newWindows = append(newWindows, window)
+		}
+	}
+	reaped := len(p.contactWindows) - len(newWindows)
+	p.contactWindows = newWindows
+	return reaped
+}
+
+// coversBearer reports whether a bearer lies within the contact window.
+func (p *PrototypeHandler) coversBearer(window *pb.ContactWindow) bool {
+	for _, bearer := range p.bearers {
+		if bearer.Transceiver == window.Transceiver && bearer.Target == window.Target &&
+			!bearer.Interval.StartTime.AsTime().Before(window.Interval.StartTime.AsTime()) &&
+			!bearer.Interval.EndTime.AsTime().After(window.Interval.EndTime.AsTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+var _ reaper.Store = (*PrototypeHandler)(nil)
@@ -16,14 +16,28 @@ package handler
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 	"testing"
 	"time"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	"github.com/google/go-cmp/cmp"
 	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"github.com/outernetcouncil/federation/pkg/go/frequencyplan"
+	"github.com/outernetcouncil/federation/pkg/go/linkbudget"
+	"github.com/outernetcouncil/federation/pkg/go/orbit"
+	"github.com/outernetcouncil/federation/pkg/go/resourcename"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/genproto/googleapis/type/interval"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"outernetcouncil.org/nmts/v1/proto/ek/physical"
 	"outernetcouncil.org/nmts/v1/proto/types/geophys"
@@ -52,7 +66,7 @@ func TestPrototypeHandler_Targets(t *testing.T) {
 
 	t.Run("GetTarget returns error if target is unknown", func(t *testing.T) {
 		_, err := h.GetTarget(ctx, &pb.GetTargetRequest{
-			Name: "unknown name",
+			Name: "targets/unknown",
 		})
 
 		if err == nil {
@@ -120,7 +134,7 @@ func TestPrototypeHandler_CreateTransceiver(t *testing.T) {
 		},
 		{
 			name:          "Fails creating a transceiver with wrong antenna type in transmit signal chain",
-			transceiverID: "wrong_antenna",
+			transceiverID: "wrong-antenna",
 			transceiver: &pb.Transceiver{
 				TransmitSignalChain: &pb.TransmitSignalChain{
 					Antenna: &physical.Antenna{
@@ -137,7 +151,7 @@ func TestPrototypeHandler_CreateTransceiver(t *testing.T) {
 		},
 		{
 			name:          "Fails creating a transceiver with wrong antenna type in receive signal chain",
-			transceiverID: "wrong_antenna",
+			transceiverID: "wrong-antenna",
 			transceiver: &pb.Transceiver{
 				TransmitSignalChain: &pb.TransmitSignalChain{
 					Antenna: &physical.Antenna{
@@ -154,7 +168,7 @@ func TestPrototypeHandler_CreateTransceiver(t *testing.T) {
 		},
 		{
 			name:          "Fails creating a transceiver with unspecified antenna type",
-			transceiverID: "wrong_antenna",
+			transceiverID: "wrong-antenna",
 			transceiver:   &pb.Transceiver{},
 			wantError:     true,
 		},
@@ -199,6 +213,100 @@ func TestPrototypeHandler_CreateTransceiver(t *testing.T) {
 	}
 }
 
+func TestPrototypeHandler_CreateTransceiver_ExplainsIncompatibility(t *testing.T) {
+	h := NewPrototypeHandler()
+	ctx := context.Background()
+
+	_, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{
+		TransceiverId: "rf",
+		Transceiver: &pb.Transceiver{
+			TransmitSignalChain: &pb.TransmitSignalChain{
+				Antenna: &physical.Antenna{
+					Type: physical.Antenna_RF,
+				},
+			},
+			ReceiveSignalChain: &pb.ReceiveSignalChain{
+				Antenna: &physical.Antenna{
+					Type: physical.Antenna_OPTICAL,
+				},
+			},
+		},
+	})
+
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition but was %v", err)
+	}
+	want := &errdetails.PreconditionFailure{
+		Violations: []*errdetails.PreconditionFailure_Violation{
+			{
+				Type:        "INCOMPATIBLE_TRANSCEIVER",
+				Subject:     "compatible_transceiver_types[0]",
+				Description: "`transmit_signal_chain.antenna.type = OPTICAL` is not satisfied, transmit_signal_chain.antenna.type is RF",
+			},
+		},
+	}
+	details := status.Convert(err).Details()
+	if len(details) != 1 {
+		t.Fatalf("expected one error detail but got %v", details)
+	}
+	if diff := cmp.Diff(want, details[0], protocmp.Transform()); diff != "" {
+		t.Fatalf("PreconditionFailure mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPrototypeHandler_ListTransceivers_Pagination(t *testing.T) {
+	h := NewPrototypeHandler()
+	ctx := context.Background()
+
+	for _, id := range []string{"c", "a", "b"} {
+		_, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{
+			TransceiverId: id,
+			Transceiver: &pb.Transceiver{
+				TransmitSignalChain: &pb.TransmitSignalChain{
+					Antenna: &physical.Antenna{
+						Type: physical.Antenna_OPTICAL,
+					},
+				},
+				ReceiveSignalChain: &pb.ReceiveSignalChain{
+					Antenna: &physical.Antenna{
+						Type: physical.Antenna_OPTICAL,
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Setup for pagination failed: %v", err)
+		}
+	}
+
+	var names []string
+	pageToken := ""
+	for pages := 1; ; pages++ {
+		resp, err := h.ListTransceivers(ctx, &pb.ListTransceiversRequest{PageSize: 2, PageToken: pageToken})
+		if err != nil {
+			t.Fatalf("Expected no error but was %v", err)
+		}
+		for _, transceiver := range resp.Transceivers {
+			names = append(names, transceiver.Name)
+		}
+		if resp.NextPageToken == "" {
+			if pages != 2 {
+				t.Fatalf("Expected 2 pages but got %d", pages)
+			}
+			break
+		}
+		pageToken = resp.NextPageToken
+	}
+	if diff := cmp.Diff([]string{"transceivers/a", "transceivers/b", "transceivers/c"}, names); diff != "" {
+		t.Fatalf("Transceivers mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err := h.ListTransceivers(ctx, &pb.ListTransceiversRequest{PageSize: 2, PageToken: "invalid"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an invalid page token but was %v", err)
+	}
+}
+
 func TestPrototypeHandler_UpdateTransceiver(t *testing.T) {
 	h, ctx := createExistingTransceiver(t)
 
@@ -275,6 +383,24 @@ func TestPrototypeHandler_UpdateTransceiver(t *testing.T) {
 			},
 			wantError: "FailedPrecondition",
 		},
+		{
+			name: "Fails updating a transceiver with a stale etag",
+			transceiver: &pb.Transceiver{
+				Name: "transceivers/existing",
+				TransmitSignalChain: &pb.TransmitSignalChain{
+					Antenna: &physical.Antenna{
+						Type: physical.Antenna_OPTICAL,
+					},
+				},
+				ReceiveSignalChain: &pb.ReceiveSignalChain{
+					Antenna: &physical.Antenna{
+						Type: physical.Antenna_OPTICAL,
+					},
+				},
+				Etag: "stale",
+			},
+			wantError: "Aborted",
+		},
 		{
 			name: "Fails updating a transceiver with unspecified antenna type",
 			transceiver: &pb.Transceiver{
@@ -301,6 +427,47 @@ func TestPrototypeHandler_UpdateTransceiver(t *testing.T) {
 	}
 }
 
+func TestPrototypeHandler_UpdateTransceiver_ConcurrentUpdates(t *testing.T) {
+	h, ctx := createExistingTransceiver(t)
+
+	current, err := h.GetTransceiver(ctx, &pb.GetTransceiverRequest{Name: "transceivers/existing"})
+	if err != nil {
+		t.Fatalf("GetTransceiver failed: %v", err)
+	}
+	if current.Etag == "" {
+		t.Fatalf("GetTransceiver returned no etag")
+	}
+
+	// Two planners read the same state and update it concurrently.
+	update := func() error {
+		transceiver := proto.Clone(current).(*pb.Transceiver)
+		transceiver.Platform = &physical.Platform{}
+		_, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{Transceiver: transceiver})
+		return err
+	}
+	if err := update(); err != nil {
+		t.Fatalf("first update failed: %v", err)
+	}
+	if err := update(); status.Code(err) != codes.Aborted {
+		t.Fatalf("second update returned %v, want Aborted", err)
+	}
+
+	updated, err := h.GetTransceiver(ctx, &pb.GetTransceiverRequest{Name: "transceivers/existing"})
+	if err != nil {
+		t.Fatalf("GetTransceiver failed: %v", err)
+	}
+	if updated.Platform == nil || updated.Etag == current.Etag {
+		t.Errorf("unexpected transceiver after updates: %v", updated)
+	}
+
+	if _, err := h.DeleteTransceiver(ctx, &pb.DeleteTransceiverRequest{Name: "transceivers/existing", Etag: current.Etag}); status.Code(err) != codes.Aborted {
+		t.Errorf("DeleteTransceiver with stale etag returned %v, want Aborted", err)
+	}
+	if _, err := h.DeleteTransceiver(ctx, &pb.DeleteTransceiverRequest{Name: "transceivers/existing", Etag: updated.Etag}); err != nil {
+		t.Errorf("DeleteTransceiver with current etag failed: %v", err)
+	}
+}
+
 func TestPrototypeHandler_Transceivers(t *testing.T) {
 	h, ctx := createExistingTransceiver(t)
 
@@ -325,7 +492,8 @@ func TestPrototypeHandler_Transceivers(t *testing.T) {
 				},
 			},
 		}, resp,
-			protocmp.Transform()); diff != "" {
+			protocmp.Transform(),
+			protocmp.IgnoreFields(&pb.Transceiver{}, "etag")); diff != "" {
 			t.Errorf("Bearer mismatch (-want +got):\n%s", diff)
 		}
 	})
@@ -366,7 +534,7 @@ func TestPrototypeHandler_Transceivers(t *testing.T) {
 	h, ctx = createExistingTransceiver(t)
 
 	_, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{
-		BearerId: "newBearer",
+		BearerId: "new-bearer",
 		Bearer: &pb.Bearer{
 			Target:      TARGET_NAME,
 			Transceiver: "transceivers/existing",
@@ -388,7 +556,7 @@ func TestPrototypeHandler_Transceivers(t *testing.T) {
 		t.Fatalf("Test setup failed: %v", err)
 	}
 
-	t.Run("cannot update transceiver if bearer is attached", func(t *testing.T) {
+	t.Run("can update transceiver if bearer is attached", func(t *testing.T) {
 		_, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{
 			Transceiver: &pb.Transceiver{
 				Name: "transceivers/existing",
@@ -405,11 +573,13 @@ func TestPrototypeHandler_Transceivers(t *testing.T) {
 			},
 		})
 
-		if err == nil {
-			t.Fatal("should have failed updating")
+		if err != nil {
+			t.Fatalf("UpdateTransceiver failed: %v", err)
 		}
-		if !strings.Contains(err.Error(), "has bearer attached") {
-			t.Fatal("error should relate to no bearer being attached but was %s", err.Error())
+		// The bearer still fits into the recomputed contact window.
+		bearer, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/new-bearer"})
+		if err != nil || bearer.AtRisk {
+			t.Errorf("GetBearer returned %v, %v, want a bearer that is not at risk", bearer, err)
 		}
 	})
 
@@ -437,7 +607,31 @@ func TestPrototypeHandler_Transceivers(t *testing.T) {
 		}
 	})
 
-	t.Run("ListTransceivers throws error on filter", func(t *testing.T) {
+	t.Run("ListTransceivers applies the filter", func(t *testing.T) {
+		resp, err := h.ListTransceivers(ctx, &pb.ListTransceiversRequest{
+			Filter: `name = "transceivers/existing" AND transmit_signal_chain.antenna.type = OPTICAL`,
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error but was %v", err)
+		}
+		if len(resp.Transceivers) != 1 {
+			t.Fatalf("Unexpected number of transceivers")
+		}
+
+		resp, err = h.ListTransceivers(ctx, &pb.ListTransceiversRequest{
+			Filter: "transmit_signal_chain.antenna.type = RF",
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error but was %v", err)
+		}
+		if len(resp.Transceivers) != 0 {
+			t.Fatalf("Unexpected number of transceivers")
+		}
+	})
+
+	t.Run("ListTransceivers throws error on invalid filter", func(t *testing.T) {
 		_, err := h.ListTransceivers(ctx, &pb.ListTransceiversRequest{
 			Filter: "test filter",
 		})
@@ -445,16 +639,223 @@ func TestPrototypeHandler_Transceivers(t *testing.T) {
 		if err == nil {
 			t.Fatal("Error expected")
 		}
-		if !strings.HasPrefix(err.Error(), "rpc error: code = Unimplemented") {
+		if !strings.HasPrefix(err.Error(), "rpc error: code = InvalidArgument") {
 			t.Fatalf("expected error did not match error %v", err)
 		}
 	})
 }
 
-func createExistingTransceiver(t *testing.T) (*PrototypeHandler, context.Context) {
+func TestPrototypeHandler_UpdateTransceiver_UpdateMask(t *testing.T) {
+	tests := []struct {
+		name        string
+		transceiver *pb.Transceiver
+		paths       []string
+		wantCode    codes.Code
+	}{
+		{
+			name:        "Fails updating the name",
+			transceiver: &pb.Transceiver{Name: "transceivers/existing"},
+			paths:       []string{"name"},
+			wantCode:    codes.InvalidArgument,
+		},
+		{
+			name:        "Fails updating the etag",
+			transceiver: &pb.Transceiver{Name: "transceivers/existing"},
+			paths:       []string{"etag"},
+			wantCode:    codes.InvalidArgument,
+		},
+		{
+			name:        "Fails updating an unknown field",
+			transceiver: &pb.Transceiver{Name: "transceivers/existing"},
+			paths:       []string{"no_such_field"},
+			wantCode:    codes.InvalidArgument,
+		},
+		{
+			name:        "Fails clearing the transmit signal chain",
+			transceiver: &pb.Transceiver{Name: "transceivers/existing"},
+			paths:       []string{"transmit_signal_chain"},
+			wantCode:    codes.FailedPrecondition,
+		},
+		{
+			name:        "Fails updating a transceiver with non-existing ID",
+			transceiver: &pb.Transceiver{Name: "transceivers/non-existant"},
+			paths:       []string{"platform"},
+			wantCode:    codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, ctx := createExistingTransceiver(t)
+			_, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{
+				Transceiver: tt.transceiver,
+				UpdateMask:  &fieldmaskpb.FieldMask{Paths: tt.paths},
+			})
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("UpdateTransceiver returned %v, want %v", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPrototypeHandler_UpdateTransceiver_UpdatesOnlyMaskedFields(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	h, ctx := createExistingTransceiver(t, WithClock(func() time.Time { return now }))
+	current, err := h.GetTransceiver(ctx, &pb.GetTransceiverRequest{Name: "transceivers/existing"})
+	if err != nil {
+		t.Fatalf("GetTransceiver failed: %v", err)
+	}
+
+	// Only the platform is sent, the signal chains are kept.
+	updated, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{
+		Transceiver: &pb.Transceiver{
+			Name:     "transceivers/existing",
+			Platform: &physical.Platform{},
+			Etag:     current.Etag,
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"platform"}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateTransceiver failed: %v", err)
+	}
+	want := proto.Clone(current).(*pb.Transceiver)
+	want.Platform = &physical.Platform{}
+	if diff := cmp.Diff(want, updated, protocmp.Transform(), protocmp.IgnoreFields(&pb.Transceiver{}, "etag")); diff != "" {
+		t.Errorf("updated transceiver mismatch (-want +got):\n%s", diff)
+	}
+	if updated.Etag == current.Etag {
+		t.Errorf("etag did not change after update")
+	}
+
+	// The contact windows keep their interval as time passes, until they end.
+	wantInterval := func(start time.Time) {
+		t.Helper()
+		response, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+		if err != nil || len(response.ContactWindows) != 1 {
+			t.Fatalf("ListContactWindows returned %v, %v, want one window", response, err)
+		}
+		want := &interval.Interval{StartTime: timestamppb.New(start), EndTime: timestamppb.New(start.Add(24 * time.Hour))}
+		if diff := cmp.Diff(want, response.ContactWindows[0].Interval, protocmp.Transform()); diff != "" {
+			t.Errorf("contact window interval at %v mismatch (-want +got):\n%s", now, diff)
+		}
+	}
+	updatePlatform := func() {
+		t.Helper()
+		if _, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{
+			Transceiver: &pb.Transceiver{Name: "transceivers/existing", Platform: &physical.Platform{}},
+			UpdateMask:  &fieldmaskpb.FieldMask{Paths: []string{"platform"}},
+		}); err != nil {
+			t.Fatalf("UpdateTransceiver failed: %v", err)
+		}
+	}
+	start := now
+	wantInterval(start)
+	now = now.Add(time.Minute)
+	updatePlatform()
+	wantInterval(start)
+	now = start.Add(24*time.Hour + time.Minute)
+	updatePlatform()
+	wantInterval(now)
+
+	// The signal chains determine the contact windows as well. Without
+	// spectrum in common with the target, the window is removed.
+	sub, _ := h.contactWindowHub.Subscribe("")
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{
+		Transceiver: &pb.Transceiver{
+			Name: "transceivers/existing",
+			ReceiveSignalChain: &pb.ReceiveSignalChain{
+				Receiver: &physical.Receiver{Signals: []*physical.ReceiveSignal{
+					{Signal: &physical.Signal{CenterFrequencyHz: 8000000000, BandwidthHz: 30000000}},
+				}},
+				Antenna: &physical.Antenna{
+					Type: physical.Antenna_OPTICAL,
+				},
+			},
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"receive_signal_chain"}},
+	}); err != nil {
+		t.Fatalf("UpdateTransceiver failed: %v", err)
+	}
+	if event, err := sub.Next(ctx); err != nil || event.Type != pb.WatchEventType_WATCH_EVENT_TYPE_DELETED || event.Old.GetTransceiver() != "transceivers/existing" {
+		t.Errorf("Next returned %v, %v, want the deleted contact window", event, err)
+	}
+}
+
+func TestPrototypeHandler_UpdateTransceiver_BearersAtRisk(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	h, ctx := createExistingTransceiver(t, WithClock(func() time.Time { return now }))
+	if _, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{
+		BearerId: "existing",
+		Bearer: &pb.Bearer{
+			Target:      TARGET_NAME,
+			Transceiver: "transceivers/existing",
+			Interval: &interval.Interval{
+				StartTime: timestamppb.New(now.Add(time.Hour)),
+				EndTime:   timestamppb.New(now.Add(2 * time.Hour)),
+			},
+			RxCenterFrequencyHz: 16000000000,
+			RxBandwidthHz:       30000000,
+			TxCenterFrequencyHz: 16000000000,
+			TxBandwidthHz:       30000000,
+		},
+	}); err != nil {
+		t.Fatalf("CreateBearer failed: %v", err)
+	}
+	windows, _ := h.contactWindowHub.Subscribe("")
+	bearers, _ := h.bearerHub.Subscribe("")
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	update := func(receiveSignals ...*physical.ReceiveSignal) {
+		t.Helper()
+		if _, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{
+			Transceiver: &pb.Transceiver{
+				Name: "transceivers/existing",
+				ReceiveSignalChain: &pb.ReceiveSignalChain{
+					Receiver: &physical.Receiver{Signals: receiveSignals},
+					Antenna:  &physical.Antenna{Type: physical.Antenna_OPTICAL},
+				},
+			},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"receive_signal_chain"}},
+		}); err != nil {
+			t.Fatalf("UpdateTransceiver failed: %v", err)
+		}
+	}
+
+	// The window shrinks to a band around 14 GHz, which the bearer is not in.
+	update(&physical.ReceiveSignal{Signal: &physical.Signal{CenterFrequencyHz: 14000000000, BandwidthHz: 30000000}})
+	event, err := windows.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if event.Type != pb.WatchEventType_WATCH_EVENT_TYPE_MODIFIED || event.Old.MaxRxCenterFrequencyHz != 18000000000 || event.New.MaxRxCenterFrequencyHz != 14000000000 {
+		t.Errorf("unexpected contact window event %v", event)
+	}
+	if event, err := bearers.Next(ctx); err != nil || event.Type != pb.WatchEventType_WATCH_EVENT_TYPE_MODIFIED || !event.New.AtRisk {
+		t.Errorf("Next returned %v, %v, want the bearer at risk", event, err)
+	}
+
+	// The bearer fits again once the window is restored.
+	update()
+	if event, err := bearers.Next(ctx); err != nil || event.New.AtRisk {
+		t.Errorf("Next returned %v, %v, want the bearer no longer at risk", event, err)
+	}
+
+	// Once the bearer is active, only the remainder of its interval must fit.
+	now = now.Add(90 * time.Minute)
+	update()
+	bearer, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/existing"})
+	if err != nil || bearer.AtRisk {
+		t.Errorf("GetBearer returned %v, %v, want the active bearer not at risk", bearer, err)
+	}
+}
+
+func createExistingTransceiver(t *testing.T, opts ...Option) (*PrototypeHandler, context.Context) {
 	t.Helper()
 
-	h := NewPrototypeHandler()
+	h := NewPrototypeHandler(opts...)
 	ctx := context.Background()
 
 	defaultTransceiver := &pb.Transceiver{
@@ -742,6 +1143,84 @@ func TestPrototypeHandler_CreateBearer(t *testing.T) {
 	}
 }
 
+func TestPrototypeHandler_CreateBearer_Conflicts(t *testing.T) {
+	h, ctx := createExistingTransceiver(t)
+	bearer := func(start, end int, centerHz int64) *pb.Bearer {
+		return &pb.Bearer{
+			Target:              TARGET_NAME,
+			Transceiver:         "transceivers/existing",
+			Interval:            createInterval(start, end),
+			RxCenterFrequencyHz: centerHz,
+			RxBandwidthHz:       30000000,
+			TxCenterFrequencyHz: centerHz,
+			TxBandwidthHz:       30000000,
+		}
+	}
+	// Several allocations that do not overlap in frequency, so that the
+	// conflict check cannot stop at the first one.
+	for i, centerHz := range []int64{15900000000, 16000000000, 16100000000} {
+		if _, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{
+			BearerId: fmt.Sprintf("existing-%d", i),
+			Bearer:   bearer(60*60*2, 60*60*3, centerHz),
+		}); err != nil {
+			t.Fatalf("Test setup failed: %v", err)
+		}
+	}
+	if _, err := h.ReserveBearer(ctx, &pb.ReserveBearerRequest{
+		ReservationId: "reserved",
+		Reservation:   &pb.Reservation{Bearer: bearer(60*60*5, 60*60*6, 16000000000)},
+	}); err != nil {
+		t.Fatalf("Test setup failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		bearer   *pb.Bearer
+		wantCode codes.Code
+	}{
+		{
+			name:     "Fails creating a bearer with the interval and frequency of any existing bearer",
+			bearer:   bearer(60*60*2, 60*60*3, 16100000000),
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Fails creating a bearer with the interval and frequency of a reservation",
+			bearer:   bearer(60*60*5, 60*60*6, 16000000000),
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name: "Fails creating a bearer overlapping in transmit frequency only",
+			bearer: &pb.Bearer{
+				Target:              TARGET_NAME,
+				Transceiver:         "transceivers/existing",
+				Interval:            createInterval(60*60*2, 60*60*3),
+				RxCenterFrequencyHz: 16200000000,
+				RxBandwidthHz:       30000000,
+				TxCenterFrequencyHz: 15900000000,
+				TxBandwidthHz:       30000000,
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Creates a bearer next to all existing bearers in frequency",
+			bearer:   bearer(60*60*2, 60*60*3, 16200000000),
+			wantCode: codes.OK,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{
+				BearerId: fmt.Sprintf("new-%d", i),
+				Bearer:   tt.bearer,
+			})
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("CreateBearer() returned %v, want %v", err, tt.wantCode)
+			}
+		})
+	}
+}
+
 func TestPrototypeHandler_Bearer(t *testing.T) {
 	h, ctx := createExistingTransceiver(t)
 
@@ -778,8 +1257,10 @@ func TestPrototypeHandler_Bearer(t *testing.T) {
 			RxBandwidthHz:       30000000,
 			TxCenterFrequencyHz: 16000000000,
 			TxBandwidthHz:       30000000,
+			State:               pb.LifecycleState_LIFECYCLE_STATE_ACTIVE,
 		}, resp,
-			protocmp.Transform()); diff != "" {
+			protocmp.Transform(),
+			protocmp.IgnoreFields(&pb.Bearer{}, "etag")); diff != "" {
 			t.Errorf("Bearer mismatch (-want +got):\n%s", diff)
 		}
 	})
@@ -868,15 +1349,39 @@ func TestPrototypeHandler_Bearer(t *testing.T) {
 		}
 	})
 
-	t.Run("ListBearers throws error on filter", func(t *testing.T) {
+	t.Run("ListBearers applies the filter", func(t *testing.T) {
+		resp, err := h.ListBearers(ctx, &pb.ListBearersRequest{
+			Filter: `transceiver = "transceivers/*" AND rx_center_frequency_hz:(>= 12e9 AND <= 18e9)`,
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error but was %v", err)
+		}
+		if len(resp.Bearers) != 1 {
+			t.Fatalf("Unexpected number of bearers")
+		}
+
+		resp, err = h.ListBearers(ctx, &pb.ListBearersRequest{
+			Filter: "rx_center_frequency_hz > 18000000000",
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error but was %v", err)
+		}
+		if len(resp.Bearers) != 0 {
+			t.Fatalf("Unexpected number of bearers")
+		}
+	})
+
+	t.Run("ListBearers throws error on invalid filter", func(t *testing.T) {
 		_, err := h.ListBearers(ctx, &pb.ListBearersRequest{
-			Filter: "test filter",
+			Filter: "no_such_field = 1",
 		})
 
 		if err == nil {
 			t.Fatal("Error expected")
 		}
-		if !strings.HasPrefix(err.Error(), "rpc error: code = Unimplemented") {
+		if !strings.HasPrefix(err.Error(), "rpc error: code = InvalidArgument") {
 			t.Fatalf("expected error did not match error %v", err)
 		}
 	})
@@ -972,7 +1477,7 @@ func TestPrototypeHandler_CreateAttachmentCircuit(t *testing.T) {
 		},
 		{
 			name: "Correctly refills the name when creating a new ac",
-			acID: "newAC",
+			acID: "new-ac",
 			ac: &pb.AttachmentCircuit{
 				Name:     "attachmentCircuits/blablabla",
 				Interval: createInterval(60*60*4, 60*60*5),
@@ -1052,8 +1557,10 @@ func TestPrototypeHandler_AttachmentCircuits(t *testing.T) {
 			L2Connection: &pb.AttachmentCircuit_L2Connection{
 				Bearer: "bearers/existing",
 			},
+			State: pb.LifecycleState_LIFECYCLE_STATE_ACTIVE,
 		}, resp,
-			protocmp.Transform()); diff != "" {
+			protocmp.Transform(),
+			protocmp.IgnoreFields(&pb.AttachmentCircuit{}, "etag")); diff != "" {
 			t.Errorf("AttachmentCircuit mismatch (-want +got):\n%s", diff)
 		}
 	})
@@ -1098,10 +1605,11 @@ func TestPrototypeHandler_AttachmentCircuits(t *testing.T) {
 			t.Fatalf("Unexpected number of attachment circuits")
 		}
 
+		// The ID of the soft-deleted attachment circuit cannot be reused.
 		_, err = h.CreateAttachmentCircuit(ctx, &pb.CreateAttachmentCircuitRequest{
-			AttachmentCircuitId: "existing",
+			AttachmentCircuitId: "recreated",
 			AttachmentCircuit: &pb.AttachmentCircuit{
-				Name:     "attachmentCircuits/existing",
+				Name:     "attachmentCircuits/recreated",
 				Interval: createInterval(60*60*2, 60*60*3),
 				L2Connection: &pb.AttachmentCircuit_L2Connection{
 					Bearer: "bearers/existing",
@@ -1122,70 +1630,1841 @@ func TestPrototypeHandler_AttachmentCircuits(t *testing.T) {
 		}
 	})
 
-	t.Run("ListAttachmentCircuits throws error on filter", func(t *testing.T) {
-		_, err := h.ListAttachmentCircuits(ctx, &pb.ListAttachmentCircuitsRequest{
-			Filter: "test filter",
+	t.Run("ListAttachmentCircuits applies the filter", func(t *testing.T) {
+		resp, err := h.ListAttachmentCircuits(ctx, &pb.ListAttachmentCircuitsRequest{
+			Filter: `l2_connection.bearer = "bearers/existing"`,
 		})
 
-		if err == nil {
-			t.Fatal("Error expected")
-		}
-		if !strings.HasPrefix(err.Error(), "rpc error: code = Unimplemented") {
-			t.Fatalf("expected error did not match error %v", err)
+		if err != nil {
+			t.Fatalf("Expected no error but was %v", err)
+		}
+		if len(resp.AttachmentCircuits) != 1 {
+			t.Fatalf("Unexpected number of attachment circuits")
+		}
+
+		resp, err = h.ListAttachmentCircuits(ctx, &pb.ListAttachmentCircuitsRequest{
+			Filter: `l2_connection.bearer = "bearers/other"`,
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error but was %v", err)
+		}
+		if len(resp.AttachmentCircuits) != 0 {
+			t.Fatalf("Unexpected number of attachment circuits")
+		}
+	})
+
+	t.Run("ListAttachmentCircuits throws error on invalid filter", func(t *testing.T) {
+		_, err := h.ListAttachmentCircuits(ctx, &pb.ListAttachmentCircuitsRequest{
+			Filter: "test filter",
+		})
+
+		if err == nil {
+			t.Fatal("Error expected")
+		}
+		if !strings.HasPrefix(err.Error(), "rpc error: code = InvalidArgument") {
+			t.Fatalf("expected error did not match error %v", err)
+		}
+	})
+}
+
+// createExistingBearer creates the bearer bearers/existing with the attachment
+// circuit attachmentCircuits/existing during the second hour from now.
+func createExistingBearer(t *testing.T, opts ...Option) (*PrototypeHandler, context.Context) {
+	t.Helper()
+
+	h, ctx := createExistingTransceiver(t, opts...)
+	_, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{
+		BearerId: "existing",
+		Bearer: &pb.Bearer{
+			Target:              TARGET_NAME,
+			Transceiver:         "transceivers/existing",
+			Interval:            createInterval(60*60, 60*60*2),
+			RxCenterFrequencyHz: 16000000000,
+			RxBandwidthHz:       30000000,
+			TxCenterFrequencyHz: 16000000000,
+			TxBandwidthHz:       30000000,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.CreateAttachmentCircuit(ctx, &pb.CreateAttachmentCircuitRequest{
+		AttachmentCircuitId: "existing",
+		AttachmentCircuit: &pb.AttachmentCircuit{
+			Interval: createInterval(60*60, 60*60*2),
+			L2Connection: &pb.AttachmentCircuit_L2Connection{
+				Bearer: "bearers/existing",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h, ctx
+}
+
+func TestPrototypeHandler_UpdateBearer(t *testing.T) {
+	tests := []struct {
+		name     string
+		bearer   *pb.Bearer
+		paths    []string
+		wantCode codes.Code
+	}{
+		{
+			name:   "Extends the interval",
+			bearer: &pb.Bearer{Name: "bearers/existing", Interval: createInterval(60*60, 60*60*3)},
+			paths:  []string{"interval"},
+		},
+		{
+			name:   "Retunes the bearer",
+			bearer: &pb.Bearer{Name: "bearers/existing", RxCenterFrequencyHz: 17000000000},
+			paths:  []string{"rx_center_frequency_hz"},
+		},
+		{
+			name: "Replaces the bearer",
+			bearer: &pb.Bearer{
+				Name:                "bearers/existing",
+				Target:              TARGET_NAME,
+				Transceiver:         "transceivers/existing",
+				Interval:            createInterval(60*60, 60*60*3),
+				RxCenterFrequencyHz: 16000000000,
+				RxBandwidthHz:       30000000,
+				TxCenterFrequencyHz: 16000000000,
+				TxBandwidthHz:       30000000,
+			},
+		},
+		{
+			name:     "Fails extending the interval beyond the contact window",
+			bearer:   &pb.Bearer{Name: "bearers/existing", Interval: createInterval(60*60, 60*60*25)},
+			paths:    []string{"interval"},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Fails retuning outside of the contact window",
+			bearer:   &pb.Bearer{Name: "bearers/existing", RxCenterFrequencyHz: 20000000000},
+			paths:    []string{"rx_center_frequency_hz"},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Fails retuning onto a neighbour with the same interval",
+			bearer:   &pb.Bearer{Name: "bearers/existing", RxCenterFrequencyHz: 16100000000},
+			paths:    []string{"rx_center_frequency_hz"},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Fails shortening the interval below an attachment circuit",
+			bearer:   &pb.Bearer{Name: "bearers/existing", Interval: createInterval(60*60, 60*90)},
+			paths:    []string{"interval"},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Fails clearing the interval",
+			bearer:   &pb.Bearer{Name: "bearers/existing"},
+			paths:    []string{"interval"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Fails updating the target",
+			bearer:   &pb.Bearer{Name: "bearers/existing", Target: "targets/other"},
+			paths:    []string{"target"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "Fails replacing the transceiver",
+			bearer: &pb.Bearer{
+				Name:                "bearers/existing",
+				Target:              TARGET_NAME,
+				Transceiver:         "transceivers/other",
+				Interval:            createInterval(60*60, 60*60*2),
+				RxCenterFrequencyHz: 16000000000,
+				RxBandwidthHz:       30000000,
+				TxCenterFrequencyHz: 16000000000,
+				TxBandwidthHz:       30000000,
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Fails updating a bearer with non-existing ID",
+			bearer:   &pb.Bearer{Name: "bearers/non-existant"},
+			paths:    []string{"interval"},
+			wantCode: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, ctx := createExistingBearer(t)
+			if _, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{
+				BearerId: "neighbour",
+				Bearer: &pb.Bearer{
+					Target:              TARGET_NAME,
+					Transceiver:         "transceivers/existing",
+					Interval:            createInterval(60*60, 60*60*2),
+					RxCenterFrequencyHz: 16100000000,
+					RxBandwidthHz:       30000000,
+					TxCenterFrequencyHz: 16100000000,
+					TxBandwidthHz:       30000000,
+				},
+			}); err != nil {
+				t.Fatalf("CreateBearer failed: %v", err)
+			}
+			current, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/existing"})
+			if err != nil {
+				t.Fatalf("GetBearer failed: %v", err)
+			}
+
+			var mask *fieldmaskpb.FieldMask
+			if tt.paths != nil {
+				mask = &fieldmaskpb.FieldMask{Paths: tt.paths}
+			}
+			updated, err := h.UpdateBearer(ctx, &pb.UpdateBearerRequest{Bearer: tt.bearer, UpdateMask: mask})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("UpdateBearer returned %v, want %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+
+			want := proto.Clone(current).(*pb.Bearer)
+			if mask == nil {
+				want = proto.Clone(tt.bearer).(*pb.Bearer)
+				want.State, want.StateReason = current.State, current.StateReason
+			}
+			for _, path := range tt.paths {
+				fd := want.ProtoReflect().Descriptor().Fields().ByName(protoreflect.Name(path))
+				want.ProtoReflect().Set(fd, tt.bearer.ProtoReflect().Get(fd))
+			}
+			if diff := cmp.Diff(want, updated, protocmp.Transform(), protocmp.IgnoreFields(&pb.Bearer{}, "etag")); diff != "" {
+				t.Errorf("updated bearer mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPrototypeHandler_UpdateAttachmentCircuit(t *testing.T) {
+	tests := []struct {
+		name     string
+		circuit  *pb.AttachmentCircuit
+		paths    []string
+		wantCode codes.Code
+	}{
+		{
+			name: "Updates the addressing",
+			circuit: &pb.AttachmentCircuit{
+				Name: "attachmentCircuits/existing",
+				IpConnection: &pb.AttachmentCircuit_IpConnection{
+					ProviderAddress: "10.0.0.1",
+					PrefixLength:    30,
+				},
+			},
+			paths: []string{"ip_connection"},
+		},
+		{
+			name:    "Shortens the interval",
+			circuit: &pb.AttachmentCircuit{Name: "attachmentCircuits/existing", Interval: createInterval(60*60, 60*90)},
+			paths:   []string{"interval"},
+		},
+		{
+			name:     "Fails extending the interval beyond the bearer",
+			circuit:  &pb.AttachmentCircuit{Name: "attachmentCircuits/existing", Interval: createInterval(60*60, 60*60*3)},
+			paths:    []string{"interval"},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name: "Fails moving the attachment circuit to another bearer",
+			circuit: &pb.AttachmentCircuit{
+				Name: "attachmentCircuits/existing",
+				L2Connection: &pb.AttachmentCircuit_L2Connection{
+					Bearer: "bearers/other",
+				},
+			},
+			paths:    []string{"l2_connection.bearer"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Fails replacing the attachment circuit without its L2 connection",
+			circuit:  &pb.AttachmentCircuit{Name: "attachmentCircuits/existing", Interval: createInterval(60*60, 60*60*2)},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Fails updating an attachment circuit with non-existing ID",
+			circuit:  &pb.AttachmentCircuit{Name: "attachmentCircuits/non-existant"},
+			paths:    []string{"interval"},
+			wantCode: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, ctx := createExistingBearer(t)
+			var mask *fieldmaskpb.FieldMask
+			if tt.paths != nil {
+				mask = &fieldmaskpb.FieldMask{Paths: tt.paths}
+			}
+			updated, err := h.UpdateAttachmentCircuit(ctx, &pb.UpdateAttachmentCircuitRequest{AttachmentCircuit: tt.circuit, UpdateMask: mask})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("UpdateAttachmentCircuit returned %v, want %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			got, err := h.GetAttachmentCircuit(ctx, &pb.GetAttachmentCircuitRequest{Name: "attachmentCircuits/existing"})
+			if err != nil {
+				t.Fatalf("GetAttachmentCircuit failed: %v", err)
+			}
+			if diff := cmp.Diff(updated, got, protocmp.Transform()); diff != "" {
+				t.Errorf("stored attachment circuit mismatch (-want +got):\n%s", diff)
+			}
+			if got.L2Connection.GetBearer() != "bearers/existing" {
+				t.Errorf("attachment circuit lost its bearer: %v", got)
+			}
+		})
+	}
+}
+
+func TestPrototypeHandler_ForceDelete(t *testing.T) {
+	t.Run("DeleteBearer with force deletes the attachment circuits", func(t *testing.T) {
+		h, ctx := createExistingBearer(t)
+		if _, err := h.DeleteBearer(ctx, &pb.DeleteBearerRequest{Name: "bearers/existing"}); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("DeleteBearer without force returned %v, want FailedPrecondition", err)
+		}
+
+		resp, err := h.DeleteBearer(ctx, &pb.DeleteBearerRequest{Name: "bearers/existing", Force: true})
+		if err != nil {
+			t.Fatalf("DeleteBearer failed: %v", err)
+		}
+		if diff := cmp.Diff([]string{"attachmentCircuits/existing", "bearers/existing"}, resp.DeletedResources); diff != "" {
+			t.Errorf("deleted resources mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("DeleteTransceiver with force deletes the dependency tree", func(t *testing.T) {
+		h, ctx := createExistingBearer(t)
+		sub, _ := h.attachmentCircuitHub.Subscribe("")
+		if _, err := h.DeleteTransceiver(ctx, &pb.DeleteTransceiverRequest{Name: "transceivers/existing"}); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("DeleteTransceiver without force returned %v, want FailedPrecondition", err)
+		}
+
+		resp, err := h.DeleteTransceiver(ctx, &pb.DeleteTransceiverRequest{Name: "transceivers/existing", Force: true})
+		if err != nil {
+			t.Fatalf("DeleteTransceiver failed: %v", err)
+		}
+		if diff := cmp.Diff([]string{
+			"attachmentCircuits/existing",
+			"bearers/existing",
+			"contactWindows/existing-mysat",
+			"transceivers/existing",
+		}, resp.DeletedResources); diff != "" {
+			t.Errorf("deleted resources mismatch (-want +got):\n%s", diff)
+		}
+		if circuit, err := h.GetAttachmentCircuit(ctx, &pb.GetAttachmentCircuitRequest{Name: "attachmentCircuits/existing"}); err != nil || circuit.State != pb.LifecycleState_LIFECYCLE_STATE_DELETED {
+			t.Errorf("GetAttachmentCircuit returned %v, %v, want a DELETED attachment circuit", circuit, err)
+		}
+		event, err := sub.Next(ctx)
+		if err != nil || event.Type != pb.WatchEventType_WATCH_EVENT_TYPE_DELETED {
+			t.Errorf("Next returned %v, %v, want a DELETED event", event, err)
+		}
+	})
+
+	t.Run("A failed cascade is rolled back", func(t *testing.T) {
+		h, ctx := createExistingBearer(t)
+		h.provisioningDelay = time.Hour
+		_, err := h.ProvisionAttachmentCircuit(ctx, &pb.ProvisionAttachmentCircuitRequest{
+			AttachmentCircuitId: "provisioning",
+			AttachmentCircuit: &pb.AttachmentCircuit{
+				Interval: createInterval(60*60, 60*60*2),
+				L2Connection: &pb.AttachmentCircuit_L2Connection{
+					Bearer: "bearers/existing",
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("ProvisionAttachmentCircuit failed: %v", err)
+		}
+		sub, _ := h.attachmentCircuitHub.Subscribe("")
+
+		if _, err := h.DeleteTransceiver(ctx, &pb.DeleteTransceiverRequest{Name: "transceivers/existing", Force: true}); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("DeleteTransceiver returned %v, want FailedPrecondition", err)
+		}
+		for _, name := range []string{"attachmentCircuits/existing", "attachmentCircuits/provisioning"} {
+			if _, err := h.GetAttachmentCircuit(ctx, &pb.GetAttachmentCircuitRequest{Name: name}); err != nil {
+				t.Errorf("GetAttachmentCircuit(%s) failed after rollback: %v", name, err)
+			}
+		}
+		if _, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/existing"}); err != nil {
+			t.Errorf("GetBearer failed after rollback: %v", err)
+		}
+		if _, err := h.GetTransceiver(ctx, &pb.GetTransceiverRequest{Name: "transceivers/existing"}); err != nil {
+			t.Errorf("GetTransceiver failed after rollback: %v", err)
+		}
+		windows, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+		if err != nil || len(windows.ContactWindows) != 1 {
+			t.Errorf("ListContactWindows returned %v, %v after rollback, want one window", windows, err)
+		}
+
+		// Nothing was published.
+		h.attachmentCircuitHub.Added(&pb.AttachmentCircuit{Name: "attachmentCircuits/sentinel"})
+		if event, err := sub.Next(ctx); err != nil || event.New.GetName() != "attachmentCircuits/sentinel" {
+			t.Errorf("Next returned %v, %v, want the sentinel", event, err)
+		}
+	})
+
+	t.Run("An attachment circuit that is being provisioned cannot be deleted", func(t *testing.T) {
+		h, ctx := createExistingBearer(t)
+		h.provisioningDelay = time.Hour
+		_, err := h.ProvisionAttachmentCircuit(ctx, &pb.ProvisionAttachmentCircuitRequest{
+			AttachmentCircuitId: "provisioning",
+			AttachmentCircuit: &pb.AttachmentCircuit{
+				Interval: createInterval(60*60, 60*60*2),
+				L2Connection: &pb.AttachmentCircuit_L2Connection{
+					Bearer: "bearers/existing",
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("ProvisionAttachmentCircuit failed: %v", err)
+		}
+
+		if _, err := h.DeleteAttachmentCircuit(ctx, &pb.DeleteAttachmentCircuitRequest{Name: "attachmentCircuits/provisioning"}); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("DeleteAttachmentCircuit returned %v, want FailedPrecondition", err)
+		}
+		circuit, err := h.GetAttachmentCircuit(ctx, &pb.GetAttachmentCircuitRequest{Name: "attachmentCircuits/provisioning"})
+		if err != nil || circuit.DeleteTime != nil {
+			t.Errorf("GetAttachmentCircuit returned %v, %v, want the attachment circuit that is not deleted", circuit, err)
+		}
+	})
+
+	t.Run("A bearer that is being provisioned cannot be deleted", func(t *testing.T) {
+		h, ctx := createExistingTransceiver(t)
+		h.provisioningDelay = time.Hour
+		_, err := h.ProvisionBearer(ctx, &pb.ProvisionBearerRequest{
+			BearerId: "provisioning",
+			Bearer: &pb.Bearer{
+				Target:              TARGET_NAME,
+				Transceiver:         "transceivers/existing",
+				Interval:            createInterval(60*60, 60*60*2),
+				RxCenterFrequencyHz: 16000000000,
+				RxBandwidthHz:       30000000,
+				TxCenterFrequencyHz: 16000000000,
+				TxBandwidthHz:       30000000,
+			},
+		})
+		if err != nil {
+			t.Fatalf("ProvisionBearer failed: %v", err)
+		}
+
+		for _, force := range []bool{false, true} {
+			if _, err := h.DeleteBearer(ctx, &pb.DeleteBearerRequest{Name: "bearers/provisioning", Force: force}); status.Code(err) != codes.FailedPrecondition {
+				t.Errorf("DeleteBearer with force %v returned %v, want FailedPrecondition", force, err)
+			}
+		}
+		bearer, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/provisioning"})
+		if err != nil || bearer.DeleteTime != nil {
+			t.Errorf("GetBearer returned %v, %v, want the bearer that is not deleted", bearer, err)
+		}
+	})
+}
+
+func TestPrototypeHandler_SoftDelete(t *testing.T) {
+	t.Run("A deleted bearer keeps its capacity until it is purged", func(t *testing.T) {
+		now := time.Now()
+		h, ctx := createExistingBearer(t, WithClock(func() time.Time { return now }), WithDeleteGracePeriod(time.Minute))
+		// The bearer of conflicting overlaps bearers/existing.
+		conflicting := &pb.CreateBearerRequest{BearerId: "conflicting", Bearer: &pb.Bearer{
+			Target:              TARGET_NAME,
+			Transceiver:         "transceivers/existing",
+			Interval:            createInterval(60*60+30*60, 60*60*3),
+			RxCenterFrequencyHz: 16000000000,
+			RxBandwidthHz:       30000000,
+			TxCenterFrequencyHz: 16000000000,
+			TxBandwidthHz:       30000000,
+		}}
+
+		if _, err := h.DeleteBearer(ctx, &pb.DeleteBearerRequest{Name: "bearers/existing", Force: true}); err != nil {
+			t.Fatalf("DeleteBearer failed: %v", err)
+		}
+		bearer, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/existing"})
+		if err != nil {
+			t.Fatalf("GetBearer failed: %v", err)
+		}
+		if bearer.State != pb.LifecycleState_LIFECYCLE_STATE_DELETED || !bearer.DeleteTime.AsTime().Equal(now) || !bearer.PurgeTime.AsTime().Equal(now.Add(time.Minute)) {
+			t.Errorf("GetBearer returned %v, want a DELETED bearer purged after the grace period", bearer)
+		}
+		if _, err := h.DeleteBearer(ctx, &pb.DeleteBearerRequest{Name: "bearers/existing"}); status.Code(err) != codes.NotFound {
+			t.Errorf("DeleteBearer of a deleted bearer returned %v, want NotFound", err)
+		}
+		if _, err := h.UpdateBearer(ctx, &pb.UpdateBearerRequest{Bearer: bearer}); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("UpdateBearer of a deleted bearer returned %v, want FailedPrecondition", err)
+		}
+		if _, err := h.CreateBearer(ctx, proto.Clone(conflicting).(*pb.CreateBearerRequest)); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("CreateBearer conflicting with a deleted bearer returned %v, want FailedPrecondition", err)
+		}
+
+		now = now.Add(time.Minute)
+		if _, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/existing"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetBearer of a purged bearer returned %v, want NotFound", err)
+		}
+		if _, err := h.GetAttachmentCircuit(ctx, &pb.GetAttachmentCircuitRequest{Name: "attachmentCircuits/existing"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetAttachmentCircuit of a purged attachment circuit returned %v, want NotFound", err)
+		}
+		if _, err := h.UndeleteBearer(ctx, &pb.UndeleteBearerRequest{Name: "bearers/existing"}); status.Code(err) != codes.NotFound {
+			t.Errorf("UndeleteBearer of a purged bearer returned %v, want NotFound", err)
+		}
+		if _, err := h.CreateBearer(ctx, proto.Clone(conflicting).(*pb.CreateBearerRequest)); err != nil {
+			t.Errorf("CreateBearer after the purge failed: %v", err)
+		}
+	})
+
+	t.Run("A full update ignores the deletion fields of the request", func(t *testing.T) {
+		h, ctx := createExistingBearer(t)
+		deleteTime, purgeTime := timestamppb.Now(), timestamppb.New(time.Now().Add(time.Minute))
+
+		transceiver, err := h.GetTransceiver(ctx, &pb.GetTransceiverRequest{Name: "transceivers/existing"})
+		if err != nil {
+			t.Fatalf("GetTransceiver failed: %v", err)
+		}
+		transceiver = proto.Clone(transceiver).(*pb.Transceiver)
+		transceiver.DeleteTime, transceiver.PurgeTime = deleteTime, purgeTime
+		if updated, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{Transceiver: transceiver}); err != nil || updated.DeleteTime != nil || updated.PurgeTime != nil {
+			t.Errorf("UpdateTransceiver returned %v, %v, want a transceiver that is not deleted", updated, err)
+		}
+
+		bearer, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/existing"})
+		if err != nil {
+			t.Fatalf("GetBearer failed: %v", err)
+		}
+		bearer = proto.Clone(bearer).(*pb.Bearer)
+		bearer.DeleteTime, bearer.PurgeTime = deleteTime, purgeTime
+		bearer.State = pb.LifecycleState_LIFECYCLE_STATE_DELETED
+		if updated, err := h.UpdateBearer(ctx, &pb.UpdateBearerRequest{Bearer: bearer}); err != nil || updated.DeleteTime != nil || updated.PurgeTime != nil || updated.State != pb.LifecycleState_LIFECYCLE_STATE_PENDING {
+			t.Errorf("UpdateBearer returned %v, %v, want a PENDING bearer that is not deleted", updated, err)
+		}
+
+		circuit, err := h.GetAttachmentCircuit(ctx, &pb.GetAttachmentCircuitRequest{Name: "attachmentCircuits/existing"})
+		if err != nil {
+			t.Fatalf("GetAttachmentCircuit failed: %v", err)
+		}
+		circuit = proto.Clone(circuit).(*pb.AttachmentCircuit)
+		circuit.DeleteTime, circuit.PurgeTime = deleteTime, purgeTime
+		if updated, err := h.UpdateAttachmentCircuit(ctx, &pb.UpdateAttachmentCircuitRequest{AttachmentCircuit: circuit}); err != nil || updated.DeleteTime != nil || updated.PurgeTime != nil {
+			t.Errorf("UpdateAttachmentCircuit returned %v, %v, want an attachment circuit that is not deleted", updated, err)
+		}
+
+		resp, err := h.ListBearers(ctx, &pb.ListBearersRequest{})
+		if err != nil || len(resp.Bearers) != 1 {
+			t.Errorf("ListBearers returned %v, %v, want the bearer to be listed", resp, err)
+		}
+	})
+
+	t.Run("ListBearers shows deleted bearers on request", func(t *testing.T) {
+		h, ctx := createExistingBearer(t)
+		if _, err := h.DeleteBearer(ctx, &pb.DeleteBearerRequest{Name: "bearers/existing", Force: true}); err != nil {
+			t.Fatalf("DeleteBearer failed: %v", err)
+		}
+		for _, showDeleted := range []bool{false, true} {
+			resp, err := h.ListBearers(ctx, &pb.ListBearersRequest{ShowDeleted: showDeleted})
+			if err != nil {
+				t.Fatalf("ListBearers failed: %v", err)
+			}
+			if want := map[bool]int{false: 0, true: 1}[showDeleted]; len(resp.Bearers) != want {
+				t.Errorf("ListBearers with show_deleted %v returned %d bearers, want %d", showDeleted, len(resp.Bearers), want)
+			}
+			circuits, err := h.ListAttachmentCircuits(ctx, &pb.ListAttachmentCircuitsRequest{ShowDeleted: showDeleted})
+			if err != nil {
+				t.Fatalf("ListAttachmentCircuits failed: %v", err)
+			}
+			if want := map[bool]int{false: 0, true: 1}[showDeleted]; len(circuits.AttachmentCircuits) != want {
+				t.Errorf("ListAttachmentCircuits with show_deleted %v returned %d attachment circuits, want %d", showDeleted, len(circuits.AttachmentCircuits), want)
+			}
+		}
+	})
+
+	t.Run("UndeleteBearer restores the attachment circuits deleted with it", func(t *testing.T) {
+		h, ctx := createExistingBearer(t)
+		if _, err := h.UndeleteBearer(ctx, &pb.UndeleteBearerRequest{Name: "bearers/existing"}); status.Code(err) != codes.AlreadyExists {
+			t.Errorf("UndeleteBearer of an existing bearer returned %v, want AlreadyExists", err)
+		}
+		if _, err := h.DeleteBearer(ctx, &pb.DeleteBearerRequest{Name: "bearers/existing", Force: true}); err != nil {
+			t.Fatalf("DeleteBearer failed: %v", err)
+		}
+		sub, _ := h.bearerHub.Subscribe("")
+
+		bearer, err := h.UndeleteBearer(ctx, &pb.UndeleteBearerRequest{Name: "bearers/existing"})
+		if err != nil {
+			t.Fatalf("UndeleteBearer failed: %v", err)
+		}
+		if bearer.State != pb.LifecycleState_LIFECYCLE_STATE_PENDING || bearer.DeleteTime != nil || bearer.PurgeTime != nil {
+			t.Errorf("UndeleteBearer returned %v, want a PENDING bearer", bearer)
+		}
+		circuit, err := h.GetAttachmentCircuit(ctx, &pb.GetAttachmentCircuitRequest{Name: "attachmentCircuits/existing"})
+		if err != nil || circuit.State != pb.LifecycleState_LIFECYCLE_STATE_PENDING || circuit.DeleteTime != nil {
+			t.Errorf("GetAttachmentCircuit returned %v, %v, want a restored PENDING attachment circuit", circuit, err)
+		}
+		if event, err := sub.Next(ctx); err != nil || event.Type != pb.WatchEventType_WATCH_EVENT_TYPE_ADDED {
+			t.Errorf("Next returned %v, %v, want an ADDED event", event, err)
+		}
+	})
+
+	t.Run("UndeleteTransceiver restores the dependency tree", func(t *testing.T) {
+		h, ctx := createExistingBearer(t)
+		if _, err := h.DeleteTransceiver(ctx, &pb.DeleteTransceiverRequest{Name: "transceivers/existing", Force: true}); err != nil {
+			t.Fatalf("DeleteTransceiver failed: %v", err)
+		}
+		if _, err := h.UndeleteBearer(ctx, &pb.UndeleteBearerRequest{Name: "bearers/existing"}); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("UndeleteBearer of a deleted transceiver returned %v, want FailedPrecondition", err)
+		}
+		if _, err := h.UndeleteAttachmentCircuit(ctx, &pb.UndeleteAttachmentCircuitRequest{Name: "attachmentCircuits/existing"}); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("UndeleteAttachmentCircuit of a deleted bearer returned %v, want FailedPrecondition", err)
+		}
+		transceivers, err := h.ListTransceivers(ctx, &pb.ListTransceiversRequest{})
+		if err != nil || len(transceivers.Transceivers) != 0 {
+			t.Errorf("ListTransceivers returned %v, %v, want no transceivers", transceivers, err)
+		}
+
+		if _, err := h.UndeleteTransceiver(ctx, &pb.UndeleteTransceiverRequest{Name: "transceivers/existing", Etag: "stale"}); status.Code(err) != codes.Aborted {
+			t.Errorf("UndeleteTransceiver with a stale etag returned %v, want Aborted", err)
+		}
+		transceiver, err := h.UndeleteTransceiver(ctx, &pb.UndeleteTransceiverRequest{Name: "transceivers/existing"})
+		if err != nil || transceiver.DeleteTime != nil {
+			t.Fatalf("UndeleteTransceiver returned %v, %v, want the restored transceiver", transceiver, err)
+		}
+		windows, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+		if err != nil || len(windows.ContactWindows) != 1 {
+			t.Errorf("ListContactWindows returned %v, %v, want the restored window", windows, err)
+		}
+		bearers, err := h.ListBearers(ctx, &pb.ListBearersRequest{})
+		if err != nil || len(bearers.Bearers) != 1 {
+			t.Errorf("ListBearers returned %v, %v, want the restored bearer", bearers, err)
+		}
+		circuits, err := h.ListAttachmentCircuits(ctx, &pb.ListAttachmentCircuitsRequest{})
+		if err != nil || len(circuits.AttachmentCircuits) != 1 {
+			t.Errorf("ListAttachmentCircuits returned %v, %v, want the restored attachment circuit", circuits, err)
+		}
+	})
+}
+
+func TestPrototypeHandler_ValidateOnly(t *testing.T) {
+	t.Run("CreateTransceiver", func(t *testing.T) {
+		h, ctx := createExistingTransceiver(t)
+		transceiver, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{
+			TransceiverId: "new",
+			Transceiver: &pb.Transceiver{
+				TransmitSignalChain: &pb.TransmitSignalChain{Antenna: &physical.Antenna{Type: physical.Antenna_OPTICAL}},
+				ReceiveSignalChain:  &pb.ReceiveSignalChain{Antenna: &physical.Antenna{Type: physical.Antenna_OPTICAL}},
+			},
+			ValidateOnly: true,
+		})
+		if err != nil {
+			t.Fatalf("CreateTransceiver failed: %v", err)
+		}
+		if transceiver.Name != "transceivers/new" || transceiver.Etag == "" {
+			t.Errorf("CreateTransceiver returned %v, want the transceiver with its name and etag", transceiver)
+		}
+		if _, err := h.GetTransceiver(ctx, &pb.GetTransceiverRequest{Name: "transceivers/new"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetTransceiver returned %v, want NotFound", err)
+		}
+		windows, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+		if err != nil || len(windows.ContactWindows) != 1 {
+			t.Errorf("ListContactWindows returned %v, %v, want only the window of the existing transceiver", windows, err)
+		}
+	})
+
+	t.Run("UpdateTransceiver", func(t *testing.T) {
+		h, ctx := createExistingTransceiver(t)
+		current, err := h.GetTransceiver(ctx, &pb.GetTransceiverRequest{Name: "transceivers/existing"})
+		if err != nil {
+			t.Fatalf("GetTransceiver failed: %v", err)
+		}
+		sub, _ := h.contactWindowHub.Subscribe("")
+
+		updated, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{
+			Transceiver:  &pb.Transceiver{Name: "transceivers/existing", Platform: &physical.Platform{}},
+			UpdateMask:   &fieldmaskpb.FieldMask{Paths: []string{"platform"}},
+			ValidateOnly: true,
+		})
+		if err != nil {
+			t.Fatalf("UpdateTransceiver failed: %v", err)
+		}
+		if updated.Platform == nil || updated.Etag == current.Etag {
+			t.Errorf("UpdateTransceiver returned %v, want the updated transceiver", updated)
+		}
+		got, err := h.GetTransceiver(ctx, &pb.GetTransceiverRequest{Name: "transceivers/existing"})
+		if err != nil {
+			t.Fatalf("GetTransceiver failed: %v", err)
+		}
+		if diff := cmp.Diff(current, got, protocmp.Transform()); diff != "" {
+			t.Errorf("transceiver changed (-want +got):\n%s", diff)
+		}
+		h.contactWindowHub.Added(&pb.ContactWindow{Name: "contactWindows/sentinel"})
+		if event, err := sub.Next(ctx); err != nil || event.New.GetName() != "contactWindows/sentinel" {
+			t.Errorf("Next returned %v, %v, want the sentinel", event, err)
+		}
+	})
+
+	t.Run("CreateBearer", func(t *testing.T) {
+		h, ctx := createExistingTransceiver(t)
+		request := &pb.CreateBearerRequest{
+			BearerId: "new",
+			Bearer: &pb.Bearer{
+				Target:              TARGET_NAME,
+				Transceiver:         "transceivers/existing",
+				Interval:            createInterval(60*60, 60*60*2),
+				RxCenterFrequencyHz: 16000000000,
+				RxBandwidthHz:       30000000,
+				TxCenterFrequencyHz: 16000000000,
+				TxBandwidthHz:       30000000,
+			},
+			ValidateOnly: true,
+		}
+		bearer, err := h.CreateBearer(ctx, proto.Clone(request).(*pb.CreateBearerRequest))
+		if err != nil {
+			t.Fatalf("CreateBearer failed: %v", err)
+		}
+		if bearer.Name != "bearers/new" || bearer.State != pb.LifecycleState_LIFECYCLE_STATE_PENDING || bearer.Etag == "" {
+			t.Errorf("CreateBearer returned %v, want the pending bearer with its name and etag", bearer)
+		}
+		if _, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/new"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetBearer returned %v, want NotFound", err)
+		}
+
+		// The contact window is not reserved by the validation.
+		request.ValidateOnly = false
+		if _, err := h.CreateBearer(ctx, request); err != nil {
+			t.Fatalf("CreateBearer failed: %v", err)
+		}
+
+		// An overlapping bearer is rejected with the violated precondition.
+		request = proto.Clone(request).(*pb.CreateBearerRequest)
+		request.BearerId = "overlapping"
+		request.Bearer.Interval = createInterval(60*60+30*60, 60*60*3)
+		request.ValidateOnly = true
+		_, err = h.CreateBearer(ctx, request)
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("CreateBearer returned %v, want FailedPrecondition", err)
+		}
+		want := &errdetails.PreconditionFailure{
+			Violations: []*errdetails.PreconditionFailure_Violation{
+				{
+					Type:        "CONTACT_WINDOW",
+					Subject:     "transceivers/existing",
+					Description: "no contact window with " + TARGET_NAME + " covers the interval and frequencies of the bearer without conflicting with other bearers",
+				},
+			},
+		}
+		details := status.Convert(err).Details()
+		if len(details) != 1 {
+			t.Fatalf("expected one error detail but got %v", details)
+		}
+		if diff := cmp.Diff(want, details[0], protocmp.Transform()); diff != "" {
+			t.Errorf("PreconditionFailure mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("CreateAttachmentCircuit", func(t *testing.T) {
+		h, ctx := createExistingBearer(t)
+		circuit, err := h.CreateAttachmentCircuit(ctx, &pb.CreateAttachmentCircuitRequest{
+			AttachmentCircuitId: "new",
+			AttachmentCircuit: &pb.AttachmentCircuit{
+				Interval:     createInterval(60*60, 60*60*2),
+				L2Connection: &pb.AttachmentCircuit_L2Connection{Bearer: "bearers/existing"},
+			},
+			ValidateOnly: true,
+		})
+		if err != nil {
+			t.Fatalf("CreateAttachmentCircuit failed: %v", err)
+		}
+		if circuit.Name != "attachmentCircuits/new" || circuit.State != pb.LifecycleState_LIFECYCLE_STATE_PENDING {
+			t.Errorf("CreateAttachmentCircuit returned %v, want the pending attachment circuit", circuit)
+		}
+		if _, err := h.GetAttachmentCircuit(ctx, &pb.GetAttachmentCircuitRequest{Name: "attachmentCircuits/new"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetAttachmentCircuit returned %v, want NotFound", err)
+		}
+
+		_, err = h.CreateAttachmentCircuit(ctx, &pb.CreateAttachmentCircuitRequest{
+			AttachmentCircuitId: "new",
+			AttachmentCircuit: &pb.AttachmentCircuit{
+				Interval:     createInterval(60*60, 60*60*2),
+				L2Connection: &pb.AttachmentCircuit_L2Connection{Bearer: "bearers/unknown"},
+			},
+			ValidateOnly: true,
+		})
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("CreateAttachmentCircuit returned %v, want FailedPrecondition", err)
+		}
+		details := status.Convert(err).Details()
+		if len(details) != 1 || details[0].(*errdetails.PreconditionFailure).Violations[0].Subject != "bearers/unknown" {
+			t.Errorf("expected a precondition failure of bearers/unknown but got %v", details)
+		}
+	})
+}
+
+func TestPrototypeHandler_QueryAvailability(t *testing.T) {
+	h, ctx := createExistingBearer(t)
+	bearerInterval := createInterval(60*60, 60*60*2)
+
+	resp, err := h.QueryAvailability(ctx, &pb.QueryAvailabilityRequest{
+		Transceiver: "transceivers/existing",
+		Target:      TARGET_NAME,
+		Interval:    bearerInterval,
+	})
+	if err != nil {
+		t.Fatalf("QueryAvailability failed: %v", err)
+	}
+	// The existing bearer splits both the receive and the transmit spectrum.
+	if len(resp.AvailabilityRegions) != 4 {
+		t.Fatalf("QueryAvailability returned %v, want 4 regions", resp.AvailabilityRegions)
+	}
+	for _, region := range resp.AvailabilityRegions {
+		if rx := region.RxFrequencyRange; rx.MinFrequencyHz <= 16015000000 && rx.MaxFrequencyHz >= 15985000000 {
+			t.Errorf("region %v overlaps the existing bearer", region)
+		}
+	}
+
+	// A bearer in an available region is accepted.
+	region := resp.AvailabilityRegions[0]
+	if _, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{
+		BearerId: "available",
+		Bearer: &pb.Bearer{
+			Target:              region.Target,
+			Transceiver:         "transceivers/existing",
+			Interval:            region.Interval,
+			RxCenterFrequencyHz: region.RxFrequencyRange.MaxFrequencyHz - 20000000,
+			RxBandwidthHz:       30000000,
+			TxCenterFrequencyHz: region.TxFrequencyRange.MaxFrequencyHz - 20000000,
+			TxBandwidthHz:       30000000,
+		},
+	}); err != nil {
+		t.Errorf("CreateBearer in available region failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		request  *pb.QueryAvailabilityRequest
+		wantCode codes.Code
+	}{
+		{
+			name:     "unknown transceiver",
+			request:  &pb.QueryAvailabilityRequest{Transceiver: "transceivers/unknown", Interval: bearerInterval},
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "unknown target",
+			request:  &pb.QueryAvailabilityRequest{Transceiver: "transceivers/existing", Target: "targets/unknown", Interval: bearerInterval},
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "missing interval",
+			request:  &pb.QueryAvailabilityRequest{Transceiver: "transceivers/existing"},
+			wantCode: codes.InvalidArgument,
+		},
+	} {
+		if _, err := h.QueryAvailability(ctx, tt.request); status.Code(err) != tt.wantCode {
+			t.Errorf("%s: QueryAvailability returned %v, want %v", tt.name, err, tt.wantCode)
+		}
+	}
+}
+
+func TestPrototypeHandler_Reservations(t *testing.T) {
+	now := time.Now()
+	h, ctx := createExistingTransceiver(t, WithClock(func() time.Time { return now }))
+	reservedBearer := func(start, end int) *pb.Bearer {
+		return &pb.Bearer{
+			Target:              TARGET_NAME,
+			Transceiver:         "transceivers/existing",
+			Interval:            createInterval(start, end),
+			RxCenterFrequencyHz: 16000000000,
+			RxBandwidthHz:       30000000,
+			TxCenterFrequencyHz: 16000000000,
+			TxBandwidthHz:       30000000,
+		}
+	}
+	// The bearer of conflicting overlaps the bearer of the first reservation.
+	conflicting := &pb.CreateBearerRequest{BearerId: "conflicting", Bearer: reservedBearer(60*60+30*60, 60*60*3)}
+
+	reservation, err := h.ReserveBearer(ctx, &pb.ReserveBearerRequest{
+		ReservationId: "committed",
+		Reservation:   &pb.Reservation{Bearer: reservedBearer(60*60, 60*60*2)},
+	})
+	if err != nil {
+		t.Fatalf("ReserveBearer failed: %v", err)
+	}
+	if reservation.Name != "reservations/committed" || !reservation.GetExpireTime().AsTime().Equal(now.Add(defaultReservationTTL)) || reservation.Etag == "" {
+		t.Errorf("ReserveBearer returned %v, want the reservation expiring after the default TTL", reservation)
+	}
+	if _, err := h.ReserveBearer(ctx, &pb.ReserveBearerRequest{
+		ReservationId: "committed",
+		Reservation:   &pb.Reservation{Bearer: reservedBearer(60*60*5, 60*60*6)},
+	}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("ReserveBearer with existing ID returned %v, want AlreadyExists", err)
+	}
+
+	// Reserved capacity counts against the conflict checks.
+	if _, err := h.CreateBearer(ctx, proto.Clone(conflicting).(*pb.CreateBearerRequest)); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("CreateBearer conflicting with a reservation returned %v, want FailedPrecondition", err)
+	}
+	if _, err := h.ReserveBearer(ctx, &pb.ReserveBearerRequest{
+		ReservationId: "conflicting",
+		Reservation:   &pb.Reservation{Bearer: proto.Clone(conflicting.Bearer).(*pb.Bearer)},
+	}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ReserveBearer conflicting with a reservation returned %v, want FailedPrecondition", err)
+	}
+	regions, err := h.QueryAvailability(ctx, &pb.QueryAvailabilityRequest{
+		Transceiver: "transceivers/existing",
+		Interval:    createInterval(60*60, 60*60*2),
+	})
+	if err != nil || len(regions.AvailabilityRegions) != 4 {
+		t.Errorf("QueryAvailability returned %v, %v, want the reserved spectrum to be allocated", regions, err)
+	}
+
+	bearer, err := h.CommitReservation(ctx, &pb.CommitReservationRequest{Name: "reservations/committed", BearerId: "committed", Etag: reservation.Etag})
+	if err != nil {
+		t.Fatalf("CommitReservation failed: %v", err)
+	}
+	if bearer.Name != "bearers/committed" || bearer.State != pb.LifecycleState_LIFECYCLE_STATE_PENDING {
+		t.Errorf("CommitReservation returned %v, want the pending bearer", bearer)
+	}
+	if _, err := h.GetReservation(ctx, &pb.GetReservationRequest{Name: "reservations/committed"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetReservation of committed reservation returned %v, want NotFound", err)
+	}
+	if _, err := h.CreateBearer(ctx, proto.Clone(conflicting).(*pb.CreateBearerRequest)); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("CreateBearer conflicting with the committed bearer returned %v, want FailedPrecondition", err)
+	}
+
+	// A released reservation frees its capacity.
+	if _, err := h.ReserveBearer(ctx, &pb.ReserveBearerRequest{
+		ReservationId: "released",
+		Reservation:   &pb.Reservation{Bearer: reservedBearer(60*60*5, 60*60*6)},
+	}); err != nil {
+		t.Fatalf("ReserveBearer failed: %v", err)
+	}
+	if _, err := h.ReleaseReservation(ctx, &pb.ReleaseReservationRequest{Name: "reservations/released"}); err != nil {
+		t.Fatalf("ReleaseReservation failed: %v", err)
+	}
+	if _, err := h.ReserveBearer(ctx, &pb.ReserveBearerRequest{
+		ReservationId: "expired",
+		Reservation: &pb.Reservation{
+			Bearer:     reservedBearer(60*60*5, 60*60*6),
+			Expiration: &pb.Reservation_Ttl{Ttl: durationpb.New(time.Minute)},
+		},
+	}); err != nil {
+		t.Fatalf("ReserveBearer after release failed: %v", err)
+	}
+
+	// An expired reservation is released automatically.
+	now = now.Add(2 * time.Minute)
+	if _, err := h.GetReservation(ctx, &pb.GetReservationRequest{Name: "reservations/expired"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetReservation of expired reservation returned %v, want NotFound", err)
+	}
+	if _, err := h.CommitReservation(ctx, &pb.CommitReservationRequest{Name: "reservations/expired", BearerId: "expired"}); status.Code(err) != codes.NotFound {
+		t.Errorf("CommitReservation of expired reservation returned %v, want NotFound", err)
+	}
+	if _, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{BearerId: "after", Bearer: reservedBearer(60*60*5, 60*60*6)}); err != nil {
+		t.Errorf("CreateBearer after the reservation expired failed: %v", err)
+	}
+
+	if _, err := h.ReserveBearer(ctx, &pb.ReserveBearerRequest{
+		ReservationId: "past",
+		Reservation: &pb.Reservation{
+			Bearer:     reservedBearer(60*60*7, 60*60*8),
+			Expiration: &pb.Reservation_Ttl{Ttl: durationpb.New(-time.Minute)},
+		},
+	}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ReserveBearer with negative TTL returned %v, want InvalidArgument", err)
+	}
+}
+
+func TestPrototypeHandler_MalformedNames(t *testing.T) {
+	h, ctx := createExistingTransceiver(t)
+
+	tests := []struct {
+		name      string
+		call      func() error
+		wantField string
+	}{
+		{
+			name: "CreateTransceiver with uppercase ID",
+			call: func() error {
+				_, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{TransceiverId: "Bad_ID", Transceiver: &pb.Transceiver{}})
+				return err
+			},
+			wantField: "transceiver_id",
+		},
+		{
+			name: "CreateBearer with ID starting with a digit",
+			call: func() error {
+				_, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{BearerId: "1bearer", Bearer: &pb.Bearer{}})
+				return err
+			},
+			wantField: "bearer_id",
+		},
+		{
+			name: "CreateAttachmentCircuit with ID ending with a hyphen",
+			call: func() error {
+				_, err := h.CreateAttachmentCircuit(ctx, &pb.CreateAttachmentCircuitRequest{AttachmentCircuitId: "circuit-", AttachmentCircuit: &pb.AttachmentCircuit{}})
+				return err
+			},
+			wantField: "attachment_circuit_id",
+		},
+		{
+			name: "ReserveBearer with empty ID",
+			call: func() error {
+				_, err := h.ReserveBearer(ctx, &pb.ReserveBearerRequest{Reservation: &pb.Reservation{}})
+				return err
+			},
+			wantField: "reservation_id",
+		},
+		{
+			name: "GetTransceiver with name of another collection",
+			call: func() error {
+				_, err := h.GetTransceiver(ctx, &pb.GetTransceiverRequest{Name: "bearers/existing"})
+				return err
+			},
+			wantField: "name",
+		},
+		{
+			name: "DeleteBearer with nested name",
+			call: func() error {
+				_, err := h.DeleteBearer(ctx, &pb.DeleteBearerRequest{Name: "bearers/a/b"})
+				return err
+			},
+			wantField: "name",
+		},
+		{
+			name: "QueryAvailability with name of another collection",
+			call: func() error {
+				_, err := h.QueryAvailability(ctx, &pb.QueryAvailabilityRequest{Transceiver: "bearers/existing", Interval: createInterval(0, 60*60)})
+				return err
+			},
+			wantField: "transceiver",
+		},
+		{
+			name: "GetTarget with singular collection",
+			call: func() error {
+				_, err := h.GetTarget(ctx, &pb.GetTargetRequest{Name: "target/mysat"})
+				return err
+			},
+			wantField: "name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("got %v, want InvalidArgument", err)
+			}
+			var violations []string
+			for _, detail := range status.Convert(err).Details() {
+				if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+					for _, v := range badRequest.FieldViolations {
+						violations = append(violations, v.Field)
+					}
+				}
+			}
+			if diff := cmp.Diff([]string{tt.wantField}, violations); diff != "" {
+				t.Errorf("field violations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPrototypeHandler_ListCompatibleTransceiverTypes(t *testing.T) {
+	h, ctx := createExistingTransceiver(t)
+
+	t.Run("ListCompatibleTransceiverTypes gets us some information about compatible transceivers", func(t *testing.T) {
+		response, err := h.ListCompatibleTransceiverTypes(ctx, &pb.ListCompatibleTransceiverTypesRequest{})
+
+		if err != nil {
+			t.Fatalf("expected no error, but was %v", err)
+		}
+		if diff := cmp.Diff(&pb.ListCompatibleTransceiverTypesResponse{
+			CompatibleTransceiverTypes: []*pb.CompatibleTransceiverType{
+				{
+					TransceiverFilter: "transmit_signal_chain.antenna.type = OPTICAL AND receive_signal_chain.antenna.type = OPTICAL",
+				},
+			},
+		}, response,
+			protocmp.Transform()); diff != "" {
+			t.Fatalf("Compatible transceivers mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestPrototypeHandler_ListContactWindows(t *testing.T) {
+	h, ctx := createExistingTransceiver(t)
+
+	t.Run("ListContactWindows gets a list of all contact windows", func(t *testing.T) {
+		response, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+
+		if err != nil {
+			t.Fatalf("expected no error, but was %v", err)
+		}
+		if len(response.ContactWindows) != 1 { // We created one transceiver, so there is one contact window
+			t.Fatalf("Unexected number of contact windows")
+		}
+	})
+
+	t.Run("ListContactWindows applies the filter", func(t *testing.T) {
+		since := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		response, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{
+			Filter: fmt.Sprintf(`transceiver = "transceivers/existing" AND interval.start_time > %q`, since),
+		})
+
+		if err != nil {
+			t.Fatalf("expected no error, but was %v", err)
+		}
+		if len(response.ContactWindows) != 1 {
+			t.Fatalf("Unexected number of contact windows")
+		}
+
+		response, err = h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{
+			Filter: `transceiver = "transceivers/other"`,
+		})
+
+		if err != nil {
+			t.Fatalf("expected no error, but was %v", err)
+		}
+		if len(response.ContactWindows) != 0 {
+			t.Fatalf("Unexected number of contact windows")
+		}
+	})
+
+	t.Run("ListContactWindows throws error on invalid filter", func(t *testing.T) {
+		_, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{
+			Filter: `interval.start_time > "tomorrow"`,
+		})
+
+		if err == nil {
+			t.Fatal("Error expected")
+		}
+		if !strings.HasPrefix(err.Error(), "rpc error: code = InvalidArgument") {
+			t.Fatalf("expected error did not match error %v", err)
+		}
+	})
+}
+
+func TestPrototypeHandler_ContactWindows_SortedByTarget(t *testing.T) {
+	h, _ := createExistingTransceiver(t)
+	for _, name := range []string{"targets/c", "targets/a", "targets/b"} {
+		target := proto.Clone(h.targets[TARGET_NAME]).(*pb.Target)
+		target.Name = name
+		h.targets[name] = target
+		h.frequencyPlans[name] = h.frequencyPlans[TARGET_NAME]
+	}
+
+	for range 10 {
+		windows, err := h.computeContactWindows(h.transceivers["transceivers/existing"])
+		if err != nil {
+			t.Fatalf("computeContactWindows failed: %v", err)
+		}
+		var got []string
+		for _, window := range windows {
+			got = append(got, window.Target)
+		}
+		if want := []string{"targets/a", "targets/b", "targets/c", TARGET_NAME}; !cmp.Equal(want, got) {
+			t.Fatalf("computeContactWindows returned windows with %q, want %q", got, want)
+		}
+	}
+}
+
+func TestContactWindowName(t *testing.T) {
+	long := strings.Repeat("a", 62) + "1"
+
+	if got, want := contactWindowName("gs1", "sat1", "1700000000"), "contactWindows/gs1-sat1-1700000000"; got != want {
+		t.Errorf("contactWindowName() = %q, want %q", got, want)
+	}
+
+	// IDs of 63 characters each are shortened to a valid ID that is unique.
+	names := map[string]bool{}
+	for _, ids := range [][]string{
+		{long, long},
+		{long, strings.Repeat("a", 62) + "2"},
+		{long, long, "1700000000"},
+		{long, long, "1700000060"},
+	} {
+		name := contactWindowName(ids...)
+		if _, err := resourcename.ContactWindow.Parse("name", name); err != nil {
+			t.Errorf("contactWindowName(%q) = %q, which is invalid: %v", ids, name, err)
+		}
+		if again := contactWindowName(ids...); again != name {
+			t.Errorf("contactWindowName(%q) = %q, then %q, want the same name", ids, name, again)
+		}
+		if names[name] {
+			t.Errorf("contactWindowName(%q) = %q, which is the name of other IDs", ids, name)
+		}
+		names[name] = true
+	}
+}
+
+func TestPrototypeHandler_ProvisionBearer(t *testing.T) {
+	h, ctx := createExistingTransceiver(t)
+	h.provisioningDelay = 0
+	bearer := &pb.Bearer{
+		Target:              TARGET_NAME,
+		Transceiver:         "transceivers/existing",
+		Interval:            createInterval(60*60, 60*60*2),
+		RxCenterFrequencyHz: 16000000000,
+		RxBandwidthHz:       30000000,
+		TxCenterFrequencyHz: 16000000000,
+		TxBandwidthHz:       30000000,
+	}
+
+	t.Run("ProvisionBearer creates the bearer asynchronously", func(t *testing.T) {
+		op, err := h.ProvisionBearer(ctx, &pb.ProvisionBearerRequest{BearerId: "async", Bearer: bearer})
+		if err != nil {
+			t.Fatalf("ProvisionBearer failed: %v", err)
+		}
+		op, err = h.Operations().WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: op.Name})
+		if err != nil {
+			t.Fatalf("WaitOperation failed: %v", err)
+		}
+		got := &pb.Bearer{}
+		if err := op.GetResponse().UnmarshalTo(got); err != nil {
+			t.Fatalf("operation did not succeed: %v", op)
+		}
+		if got.Name != "bearers/async" {
+			t.Errorf("provisioned bearer has name %q, want bearers/async", got.Name)
+		}
+		if got.State != pb.LifecycleState_LIFECYCLE_STATE_PENDING {
+			t.Errorf("provisioned bearer is in state %v, want PENDING", got.State)
+		}
+		if _, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/async"}); err != nil {
+			t.Errorf("GetBearer failed: %v", err)
+		}
+	})
+
+	t.Run("ProvisionBearer validates the bearer before starting an operation", func(t *testing.T) {
+		_, err := h.ProvisionBearer(ctx, &pb.ProvisionBearerRequest{BearerId: "async", Bearer: bearer})
+		if status.Code(err) != codes.AlreadyExists {
+			t.Errorf("ProvisionBearer returned %v, want AlreadyExists", err)
+		}
+	})
+
+	t.Run("Cancelled provisioning leaves the bearer cancelled", func(t *testing.T) {
+		h.provisioningDelay = time.Hour
+		bearer := proto.Clone(bearer).(*pb.Bearer)
+		bearer.Interval = createInterval(60*60*2, 60*60*3)
+		op, err := h.ProvisionBearer(ctx, &pb.ProvisionBearerRequest{BearerId: "cancelled", Bearer: bearer})
+		if err != nil {
+			t.Fatalf("ProvisionBearer failed: %v", err)
+		}
+		if _, err := h.Operations().CancelOperation(ctx, &longrunningpb.CancelOperationRequest{Name: op.Name}); err != nil {
+			t.Fatalf("CancelOperation failed: %v", err)
+		}
+		op, err = h.Operations().WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: op.Name})
+		if err != nil {
+			t.Fatalf("WaitOperation failed: %v", err)
+		}
+		if got := codes.Code(op.GetError().GetCode()); got != codes.Canceled {
+			t.Errorf("operation finished with %v, want Canceled", got)
+		}
+		got, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/cancelled"})
+		if err != nil {
+			t.Fatalf("GetBearer failed: %v", err)
+		}
+		if got.State != pb.LifecycleState_LIFECYCLE_STATE_CANCELLED {
+			t.Errorf("cancelled bearer is in state %v, want CANCELLED", got.State)
 		}
 	})
 }
 
-func TestPrototypeHandler_ListCompatibleTransceiverTypes(t *testing.T) {
-	h, ctx := createExistingTransceiver(t)
+func TestPrototypeHandler_BearerLifecycle(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	h, ctx := createExistingTransceiver(t, WithClock(func() time.Time { return now }))
+	_, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{
+		BearerId: "existing",
+		Bearer: &pb.Bearer{
+			Target:      TARGET_NAME,
+			Transceiver: "transceivers/existing",
+			Interval: &interval.Interval{
+				StartTime: timestamppb.New(now.Add(time.Hour)),
+				EndTime:   timestamppb.New(now.Add(2 * time.Hour)),
+			},
+			RxCenterFrequencyHz: 16000000000,
+			RxBandwidthHz:       30000000,
+			TxCenterFrequencyHz: 16000000000,
+			TxBandwidthHz:       30000000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateBearer failed: %v", err)
+	}
+	_, err = h.CreateAttachmentCircuit(ctx, &pb.CreateAttachmentCircuitRequest{
+		AttachmentCircuitId: "existing",
+		AttachmentCircuit: &pb.AttachmentCircuit{
+			Interval: &interval.Interval{
+				StartTime: timestamppb.New(now.Add(time.Hour)),
+				EndTime:   timestamppb.New(now.Add(2 * time.Hour)),
+			},
+			L2Connection: &pb.AttachmentCircuit_L2Connection{
+				Bearer: "bearers/existing",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateAttachmentCircuit failed: %v", err)
+	}
+	sub, _ := h.bearerHub.Subscribe("")
 
-	t.Run("ListCompatibleTransceiverTypes gets us some information about compatible transceivers", func(t *testing.T) {
-		response, err := h.ListCompatibleTransceiverTypes(ctx, &pb.ListCompatibleTransceiverTypesRequest{})
+	for _, tt := range []struct {
+		elapsed time.Duration
+		want    pb.LifecycleState
+	}{
+		{0, pb.LifecycleState_LIFECYCLE_STATE_PENDING},
+		{time.Hour, pb.LifecycleState_LIFECYCLE_STATE_ACTIVE},
+		{2 * time.Hour, pb.LifecycleState_LIFECYCLE_STATE_SUCCEEDED},
+	} {
+		if tt.elapsed > 0 {
+			now = now.Add(time.Hour)
+			// The state timer would fire at this time.
+			h.refreshStates()
+
+			event, err := sub.Next(ctx)
+			if err != nil {
+				t.Fatalf("Next failed: %v", err)
+			}
+			if event.Type != pb.WatchEventType_WATCH_EVENT_TYPE_MODIFIED || event.New.State != tt.want {
+				t.Errorf("after %v: unexpected bearer event %v", tt.elapsed, event)
+			}
+		}
 
+		bearer, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/existing"})
 		if err != nil {
-			t.Fatalf("expected no error, but was %v", err)
+			t.Fatalf("GetBearer failed: %v", err)
 		}
-		if diff := cmp.Diff(&pb.ListCompatibleTransceiverTypesResponse{
-			CompatibleTransceiverTypes: []*pb.CompatibleTransceiverType{
-				{
-					TransceiverFilter: "transmit_signal_chain.antenna.type = OPTICAL AND receive_signal_chain.antenna.type = OPTICAL",
-				},
+		circuit, err := h.GetAttachmentCircuit(ctx, &pb.GetAttachmentCircuitRequest{Name: "attachmentCircuits/existing"})
+		if err != nil {
+			t.Fatalf("GetAttachmentCircuit failed: %v", err)
+		}
+		if bearer.State != tt.want || circuit.State != tt.want {
+			t.Errorf("after %v: bearer is %v and attachment circuit is %v, want %v", tt.elapsed, bearer.State, circuit.State, tt.want)
+		}
+	}
+}
+
+func TestPrototypeHandler_Reap(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	h, ctx := createExistingTransceiver(t, WithClock(func() time.Time { return now }))
+	_, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{
+		BearerId: "existing",
+		Bearer: &pb.Bearer{
+			Target:      TARGET_NAME,
+			Transceiver: "transceivers/existing",
+			Interval: &interval.Interval{
+				StartTime: timestamppb.New(now.Add(time.Hour)),
+				EndTime:   timestamppb.New(now.Add(2 * time.Hour)),
 			},
-		}, response,
-			protocmp.Transform()); diff != "" {
-			t.Fatalf("Compatible transceivers mismatch (-want +got):\n%s", diff)
+			RxCenterFrequencyHz: 16000000000,
+			RxBandwidthHz:       30000000,
+			TxCenterFrequencyHz: 16000000000,
+			TxBandwidthHz:       30000000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateBearer failed: %v", err)
+	}
+	_, err = h.CreateAttachmentCircuit(ctx, &pb.CreateAttachmentCircuitRequest{
+		AttachmentCircuitId: "existing",
+		AttachmentCircuit: &pb.AttachmentCircuit{
+			Interval: &interval.Interval{
+				StartTime: timestamppb.New(now.Add(time.Hour)),
+				EndTime:   timestamppb.New(now.Add(2 * time.Hour)),
+			},
+			L2Connection: &pb.AttachmentCircuit_L2Connection{
+				Bearer: "bearers/existing",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateAttachmentCircuit failed: %v", err)
+	}
+
+	// The contact window lasts for a day.
+	afterWindow := now.Add(25 * time.Hour)
+	for _, tt := range []struct {
+		name string
+		reap func(time.Time) int
+		want int
+	}{
+		{"bearer with attachment circuit", h.ReapBearers, 0},
+		{"contact window covering a bearer", h.ReapContactWindows, 0},
+		{"attachment circuit", h.ReapAttachmentCircuits, 1},
+		{"bearer", h.ReapBearers, 1},
+		{"contact window", h.ReapContactWindows, 1},
+	} {
+		if got := tt.reap(afterWindow); got != tt.want {
+			t.Errorf("reaping %s removed %d resources, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	windows, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+	if err != nil {
+		t.Fatalf("ListContactWindows failed: %v", err)
+	}
+	if len(windows.ContactWindows) != 0 {
+		t.Errorf("ListContactWindows returned %v after reaping", windows.ContactWindows)
+	}
+	if _, err := h.GetBearer(ctx, &pb.GetBearerRequest{Name: "bearers/existing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetBearer returned %v after reaping, want NotFound", err)
+	}
+}
+
+func TestPrototypeHandler_Reap_SoftDeleted(t *testing.T) {
+	now := time.Now()
+	h, ctx := createExistingBearer(t, WithClock(func() time.Time { return now }), WithDeleteGracePeriod(48*time.Hour))
+	if _, err := h.DeleteBearer(ctx, &pb.DeleteBearerRequest{Name: "bearers/existing", Force: true}); err != nil {
+		t.Fatalf("DeleteBearer failed: %v", err)
+	}
+
+	// Soft-deleted resources are purged at their purge time, not reaped.
+	afterBearer := now.Add(3 * time.Hour)
+	if got := h.ReapAttachmentCircuits(afterBearer); got != 0 {
+		t.Errorf("reaping attachment circuits removed %d, want 0 within the grace period", got)
+	}
+	if got := h.ReapBearers(afterBearer); got != 0 {
+		t.Errorf("reaping bearers removed %d, want 0 within the grace period", got)
+	}
+
+	if _, err := h.UndeleteBearer(ctx, &pb.UndeleteBearerRequest{Name: "bearers/existing"}); err != nil {
+		t.Fatalf("UndeleteBearer failed: %v", err)
+	}
+	if circuit, err := h.GetAttachmentCircuit(ctx, &pb.GetAttachmentCircuitRequest{Name: "attachmentCircuits/existing"}); err != nil || circuit.DeleteTime != nil {
+		t.Errorf("GetAttachmentCircuit returned %v, %v, want the restored attachment circuit", circuit, err)
+	}
+}
+
+// watchStream is a server stream which passes the sent responses to a channel.
+type watchStream[T any] struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses chan *T
+}
+
+func newWatchStream[T any](ctx context.Context) *watchStream[T] {
+	return &watchStream[T]{ctx: ctx, responses: make(chan *T)}
+}
+
+func (s *watchStream[T]) Context() context.Context { return s.ctx }
+
+func (s *watchStream[T]) Send(resp *T) error {
+	select {
+	case s.responses <- resp:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func TestPrototypeHandler_WatchBearers(t *testing.T) {
+	h, ctx := createExistingTransceiver(t)
+	_, err := h.CreateBearer(ctx, &pb.CreateBearerRequest{
+		BearerId: "existing",
+		Bearer: &pb.Bearer{
+			Target:              TARGET_NAME,
+			Transceiver:         "transceivers/existing",
+			Interval:            createInterval(60*60, 60*60*2),
+			RxCenterFrequencyHz: 16000000000,
+			RxBandwidthHz:       30000000,
+			TxCenterFrequencyHz: 16000000000,
+			TxBandwidthHz:       30000000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Test setup failed: %v", err)
+	}
+
+	watch := func(resumeToken string) (*watchStream[pb.WatchBearersResponse], context.CancelFunc, chan error) {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		stream := newWatchStream[pb.WatchBearersResponse](ctx)
+		done := make(chan error, 1)
+		go func() {
+			done <- h.WatchBearers(&pb.WatchBearersRequest{ResumeToken: resumeToken}, stream)
+		}()
+		return stream, cancel, done
+	}
+	next := func(stream *watchStream[pb.WatchBearersResponse], wantType pb.WatchEventType, wantName string) string {
+		t.Helper()
+		select {
+		case resp := <-stream.responses:
+			if resp.Type != wantType || resp.Bearer.GetName() != wantName {
+				t.Fatalf("got %v event for %q, want %v event for %q", resp.Type, resp.Bearer.GetName(), wantType, wantName)
+			}
+			return resp.ResumeToken
+		case <-stream.ctx.Done():
+			t.Fatalf("timed out waiting for %v event", wantType)
+			return ""
 		}
+	}
+
+	stream, cancel, done := watch("")
+	next(stream, pb.WatchEventType_WATCH_EVENT_TYPE_SNAPSHOT, "bearers/existing")
+	resumeToken := next(stream, pb.WatchEventType_WATCH_EVENT_TYPE_SNAPSHOT_COMPLETE, "")
+	cancel()
+	if err := <-done; status.Code(err) != codes.Canceled {
+		t.Fatalf("WatchBearers returned %v, want Canceled", err)
+	}
+
+	// Changes made while disconnected are sent on resumption, without a snapshot.
+	if _, err := h.DeleteBearer(ctx, &pb.DeleteBearerRequest{Name: "bearers/existing"}); err != nil {
+		t.Fatalf("DeleteBearer failed: %v", err)
+	}
+	stream, cancel, done = watch(resumeToken)
+	defer cancel()
+	next(stream, pb.WatchEventType_WATCH_EVENT_TYPE_DELETED, "bearers/existing")
+
+	// The deleted bearer keeps its capacity until it is purged.
+	_, err = h.CreateBearer(ctx, &pb.CreateBearerRequest{
+		BearerId: "new",
+		Bearer: &pb.Bearer{
+			Target:              TARGET_NAME,
+			Transceiver:         "transceivers/existing",
+			Interval:            createInterval(60*60*2, 60*60*3),
+			RxCenterFrequencyHz: 16000000000,
+			RxBandwidthHz:       30000000,
+			TxCenterFrequencyHz: 16000000000,
+			TxBandwidthHz:       30000000,
+		},
 	})
+	if err != nil {
+		t.Fatalf("CreateBearer failed: %v", err)
+	}
+	next(stream, pb.WatchEventType_WATCH_EVENT_TYPE_ADDED, "bearers/new")
+	cancel()
+	<-done
 }
 
-func TestPrototypeHandler_ListContactWindows(t *testing.T) {
+func TestPrototypeHandler_WatchContactWindows(t *testing.T) {
 	h, ctx := createExistingTransceiver(t)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	stream := newWatchStream[pb.WatchContactWindowsResponse](ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- h.WatchContactWindows(&pb.WatchContactWindowsRequest{Filter: `transceiver = "transceivers/existing"`}, stream)
+	}()
+
+	var got []pb.WatchEventType
+	for len(got) < 3 {
+		select {
+		case resp := <-stream.responses:
+			got = append(got, resp.Type)
+		case <-ctx.Done():
+			t.Fatalf("timed out after %v", got)
+		}
+		if len(got) == 2 {
+			if _, err := h.DeleteTransceiver(ctx, &pb.DeleteTransceiverRequest{Name: "transceivers/existing"}); err != nil {
+				t.Fatalf("DeleteTransceiver failed: %v", err)
+			}
+		}
+	}
+	if diff := cmp.Diff([]pb.WatchEventType{
+		pb.WatchEventType_WATCH_EVENT_TYPE_SNAPSHOT,
+		pb.WatchEventType_WATCH_EVENT_TYPE_SNAPSHOT_COMPLETE,
+		pb.WatchEventType_WATCH_EVENT_TYPE_DELETED,
+	}, got); diff != "" {
+		t.Errorf("event types mismatch (-want +got):\n%s", diff)
+	}
+	cancel()
+	<-done
+}
 
-	t.Run("ListContactWindows gets a list of all contact windows", func(t *testing.T) {
+func TestPrototypeHandler_OrbitalContactWindows(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	// A circular polar orbit which passes over the null island at now.
+	satellite := &geophys.Motion{Type: &geophys.Motion_KeplerianElements{KeplerianElements: &geophys.KeplerianElements{
+		SemimajorAxisM:                   orbit.EarthRadiusM + 550e3,
+		InclinationDeg:                   90,
+		RightAscensionOfAscendingNodeDeg: orbit.GMST(now) * 180 / math.Pi,
+		Epoch:                            timestamppb.New(now),
+	}}}
+	groundStation := &geophys.Motion{Type: &geophys.Motion_GeodeticWgs84{GeodeticWgs84: &geophys.GeodeticWgs84{}}}
+	newTransceiver := func(motion *geophys.Motion) *pb.Transceiver {
+		return &pb.Transceiver{
+			TransmitSignalChain: &pb.TransmitSignalChain{Antenna: &physical.Antenna{Type: physical.Antenna_OPTICAL}},
+			ReceiveSignalChain:  &pb.ReceiveSignalChain{Antenna: &physical.Antenna{Type: physical.Antenna_OPTICAL}},
+			Platform:            &physical.Platform{Motion: motion},
+		}
+	}
+
+	t.Run("passes", func(t *testing.T) {
+		h := NewPrototypeHandler(WithClock(func() time.Time { return now }), WithOrbitalContactWindows(24*time.Hour, 10), WithTargetMotion(satellite))
+		ctx := context.Background()
+		if _, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{TransceiverId: "ground", Transceiver: newTransceiver(groundStation)}); err != nil {
+			t.Fatalf("CreateTransceiver failed: %v", err)
+		}
+		response, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+		if err != nil {
+			t.Fatalf("ListContactWindows failed: %v", err)
+		}
+		windows := response.ContactWindows
+		if len(windows) < 2 {
+			t.Fatalf("ListContactWindows returned %v, want several passes", windows)
+		}
+		for i, window := range windows {
+			if want := fmt.Sprintf("contactWindows/ground-mysat-%d", window.Interval.StartTime.AsTime().Truncate(orbit.DefaultStep).Unix()); window.Name != want {
+				t.Errorf("window %d is named %q, want %q", i, window.Name, want)
+			}
+			if window.Transceiver != "transceivers/ground" || window.Target != TARGET_NAME {
+				t.Errorf("window %v is not between the transceiver and the target", window)
+			}
+			start, end := window.Interval.StartTime.AsTime(), window.Interval.EndTime.AsTime()
+			if start.Before(now) || end.After(now.Add(24*time.Hour)) || !start.Before(end) {
+				t.Errorf("window %v is not within the horizon", window)
+			}
+			if d := end.Sub(start); d > 15*time.Minute {
+				t.Errorf("window %v lasts %v, want at most one pass", window.Name, d)
+			}
+		}
+		// The satellite is overhead at now.
+		if got := windows[0].Interval.StartTime.AsTime(); !got.Equal(now) {
+			t.Errorf("first window starts at %v, want %v", got, now)
+		}
+
+		// Recomputing the windows during the first pass and after it keeps
+		// the windows of the passes, except for the one cut off by the horizon.
+		defer func(start time.Time) { now = start }(now)
+		for _, tt := range []struct {
+			elapsed time.Duration
+			ended   int
+		}{
+			{elapsed: time.Minute + 10*time.Second, ended: 0},
+			{elapsed: time.Hour, ended: 1},
+		} {
+			now = windows[0].Interval.StartTime.AsTime().Add(tt.elapsed)
+			transceiver := newTransceiver(groundStation)
+			transceiver.Name = "transceivers/ground"
+			if _, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{Transceiver: transceiver, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"platform"}}}); err != nil {
+				t.Fatalf("UpdateTransceiver failed: %v", err)
+			}
+			response, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+			if err != nil {
+				t.Fatalf("ListContactWindows failed: %v", err)
+			}
+			want := windows[tt.ended : len(windows)-1]
+			if len(response.ContactWindows) < len(want) {
+				t.Fatalf("ListContactWindows returned %v after %v, want at least %v", response.ContactWindows, tt.elapsed, want)
+			}
+			if diff := cmp.Diff(want, response.ContactWindows[:len(want)], protocmp.Transform()); diff != "" {
+				t.Errorf("windows recomputed after %v mismatch (-want +got):\n%s", tt.elapsed, diff)
+			}
+		}
+	})
+
+	t.Run("between satellites", func(t *testing.T) {
+		// A satellite in the same orbit slightly ahead is always visible.
+		ahead := proto.Clone(satellite).(*geophys.Motion)
+		ahead.GetKeplerianElements().TrueAnomalyDeg = 30
+		h := NewPrototypeHandler(WithClock(func() time.Time { return now }), WithOrbitalContactWindows(6*time.Hour, 10), WithTargetMotion(satellite))
+		ctx := context.Background()
+		if _, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{TransceiverId: "leo", Transceiver: newTransceiver(ahead)}); err != nil {
+			t.Fatalf("CreateTransceiver failed: %v", err)
+		}
 		response, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+		if err != nil {
+			t.Fatalf("ListContactWindows failed: %v", err)
+		}
+		rx, tx, _ := defaultFrequencyPlan.Limits(newTransceiver(ahead))
+		want := []*pb.ContactWindow{
+			newContactWindow(fmt.Sprintf("contactWindows/leo-mysat-%d", now.Unix()), "transceivers/leo", TARGET_NAME, now, now.Add(6*time.Hour), rx, tx),
+		}
+		if diff := cmp.Diff(want, response.ContactWindows, protocmp.Transform()); diff != "" {
+			t.Errorf("ListContactWindows mismatch (-want +got):\n%s", diff)
+		}
+	})
 
+	t.Run("state vector table", func(t *testing.T) {
+		// Windows are limited to the times covered by the table.
+		position := orbit.Geodetic(0, 0, 0)
+		table := &geophys.Motion{Type: &geophys.Motion_StateVectorTable{StateVectorTable: &geophys.StateVectorTable{
+			ReferenceFrame: geophys.StateVectorTable_ECEF,
+			StateVectors: []*geophys.StateVector{
+				{Time: timestamppb.New(now.Add(-time.Hour)), Position: &geophys.Cartesian{XM: position.X, YM: position.Y, ZM: position.Z}},
+				{Time: timestamppb.New(now.Add(time.Hour)), Position: &geophys.Cartesian{XM: position.X, YM: position.Y, ZM: position.Z}},
+			},
+		}}}
+		h := NewPrototypeHandler(WithClock(func() time.Time { return now }), WithOrbitalContactWindows(24*time.Hour, 10), WithTargetMotion(satellite))
+		ctx := context.Background()
+		if _, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{TransceiverId: "table", Transceiver: newTransceiver(table)}); err != nil {
+			t.Fatalf("CreateTransceiver failed: %v", err)
+		}
+		response, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
 		if err != nil {
-			t.Fatalf("expected no error, but was %v", err)
+			t.Fatalf("ListContactWindows failed: %v", err)
 		}
-		if len(response.ContactWindows) != 1 { // We created one transceiver, so there is one contact window
-			t.Fatalf("Unexected number of contact windows")
+		if len(response.ContactWindows) == 0 {
+			t.Fatalf("ListContactWindows returned no windows, want the pass at %v", now)
+		}
+		for _, window := range response.ContactWindows {
+			if window.Interval.EndTime.AsTime().After(now.Add(time.Hour)) {
+				t.Errorf("window %v ends after the table", window)
+			}
 		}
 	})
 
-	t.Run("ListContactWindows returns unimplemented when used with a filter", func(t *testing.T) {
-		_, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{
-			Filter: "test filter",
+	t.Run("link budget", func(t *testing.T) {
+		// Optical terminals with 10 cm apertures at 1550 nm.
+		transceiver := newTransceiver(groundStation)
+		transceiver.TransmitSignalChain.Modulator = &physical.Modulator{ModulationSchemes: []string{"OOK"}}
+		transceiver.TransmitSignalChain.Antenna.GainDb = 106
+		transceiver.TransmitSignalChain.Transmitter = &physical.Transmitter{Signals: []*physical.TransmitSignal{
+			{Signal: &physical.Signal{CenterFrequencyHz: 193400000000000, BandwidthHz: 10000000000}, PowerDbw: -10},
+		}}
+		transceiver.ReceiveSignalChain.Demodulator = &physical.Demodulator{ModulationSchemes: []string{"OOK"}}
+		transceiver.ReceiveSignalChain.Antenna.GainDb = 106
+		transceiver.ReceiveSignalChain.Receiver = &physical.Receiver{
+			Signals:                 []*physical.ReceiveSignal{{Signal: &physical.Signal{CenterFrequencyHz: 193400000000000, BandwidthHz: 10000000000}}},
+			SystemNoiseTemperatureK: 1000,
+		}
+		terminal := linkbudget.Terminal{
+			Transmitter:       linkbudget.Transmitter{PowerDBW: -10, AntennaGainDBi: 106},
+			Receiver:          linkbudget.Receiver{AntennaGainDBi: 106, SystemNoiseTemperatureK: 1000},
+			ModulationSchemes: []string{"OOK", "BPSK"},
+		}
+		// Require a margin which is only available in the upper part of a pass.
+		uplinks, _, err := linkbudget.Links(transceiver, terminal)
+		if err != nil {
+			t.Fatalf("Links failed: %v", err)
+		}
+		minMarginDB := uplinks[0].At(550e3).MarginDB - 3
+		c := []frequencyplan.Channel{{LowHz: 191000000000000, HighHz: 196000000000000}}
+		opticalPlan := WithFrequencyPlan(frequencyplan.Plan{Rx: c, Tx: c})
+
+		newHandler := func(opts ...Option) *PrototypeHandler {
+			opts = append(opts, WithClock(func() time.Time { return now }), WithOrbitalContactWindows(24*time.Hour, 10), WithTargetMotion(satellite), opticalPlan)
+			h := NewPrototypeHandler(opts...)
+			if _, err := h.CreateTransceiver(context.Background(), &pb.CreateTransceiverRequest{TransceiverId: "ground", Transceiver: proto.Clone(transceiver).(*pb.Transceiver)}); err != nil {
+				t.Fatalf("CreateTransceiver failed: %v", err)
+			}
+			return h
+		}
+		visible, err := newHandler().ListContactWindows(context.Background(), &pb.ListContactWindowsRequest{})
+		if err != nil {
+			t.Fatalf("ListContactWindows failed: %v", err)
+		}
+		closing, err := newHandler(WithLinkBudget(terminal, minMarginDB)).ListContactWindows(context.Background(), &pb.ListContactWindowsRequest{})
+		if err != nil {
+			t.Fatalf("ListContactWindows failed: %v", err)
+		}
+		if len(closing.ContactWindows) == 0 {
+			t.Fatalf("ListContactWindows returned no windows, want the pass at %v", now)
+		}
+		if got, limit := closing.ContactWindows[0].Interval.EndTime.AsTime(), visible.ContactWindows[0].Interval.EndTime.AsTime(); !got.Before(limit) {
+			t.Errorf("the pass ends at %v, want before the end of visibility at %v", got, limit)
+		}
+		for _, window := range closing.ContactWindows {
+			// The end of a window is only accurate to a second, in which the
+			// margin changes by less than 0.1 dB.
+			if window.PredictedLinkMarginDb == nil || *window.PredictedLinkMarginDb < minMarginDB-0.1 {
+				t.Errorf("window %v predicts a margin below %v", window, minMarginDB)
+			}
+		}
+		if visible.ContactWindows[0].PredictedLinkMarginDb != nil {
+			t.Errorf("window %v predicts a margin without link budget", visible.ContactWindows[0])
+		}
+
+		unreachable, err := newHandler(WithLinkBudget(terminal, minMarginDB+4)).ListContactWindows(context.Background(), &pb.ListContactWindowsRequest{})
+		if err != nil || len(unreachable.ContactWindows) != 0 {
+			t.Errorf("ListContactWindows returned %v, %v, want no windows above the maximum margin", unreachable, err)
+		}
+
+		h := NewPrototypeHandler(WithClock(func() time.Time { return now }), WithOrbitalContactWindows(24*time.Hour, 10), WithTargetMotion(satellite), WithLinkBudget(terminal, minMarginDB), opticalPlan)
+		_, err = h.CreateTransceiver(context.Background(), &pb.CreateTransceiverRequest{TransceiverId: "nosignals", Transceiver: newTransceiver(groundStation)})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("CreateTransceiver without signals returned %v, want InvalidArgument", err)
+		}
+	})
+
+	t.Run("unsupported transceiver motion", func(t *testing.T) {
+		h := NewPrototypeHandler(WithClock(func() time.Time { return now }), WithOrbitalContactWindows(6*time.Hour, 10), WithTargetMotion(satellite))
+		ctx := context.Background()
+		for _, motion := range []*geophys.Motion{
+			nil,
+			{Type: &geophys.Motion_TwoLineElementSet{TwoLineElementSet: &geophys.TwoLineElementSet{Line1: "1", Line2: "2"}}},
+		} {
+			_, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{TransceiverId: "invalid", Transceiver: newTransceiver(motion)})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("CreateTransceiver(%v) returned %v, want InvalidArgument", motion, err)
+			}
+		}
+
+		if _, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{TransceiverId: "ground", Transceiver: newTransceiver(groundStation)}); err != nil {
+			t.Fatalf("CreateTransceiver failed: %v", err)
+		}
+		_, err := h.UpdateTransceiver(ctx, &pb.UpdateTransceiverRequest{
+			Transceiver: &pb.Transceiver{Name: "transceivers/ground", Platform: &physical.Platform{}},
+			UpdateMask:  &fieldmaskpb.FieldMask{Paths: []string{"platform"}},
 		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("UpdateTransceiver returned %v, want InvalidArgument", err)
+		}
+	})
 
-		if err == nil {
-			t.Fatal("Error expected")
+	t.Run("invalid target motion", func(t *testing.T) {
+		// The example target has no motion by default, so it has no windows.
+		h := NewPrototypeHandler(WithClock(func() time.Time { return now }), WithOrbitalContactWindows(6*time.Hour, 10))
+		ctx := context.Background()
+		if _, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{TransceiverId: "ground", Transceiver: newTransceiver(groundStation)}); err != nil {
+			t.Fatalf("CreateTransceiver failed: %v", err)
 		}
-		if !strings.HasPrefix(err.Error(), "rpc error: code = Unimplemented") {
-			t.Fatalf("expected error did not match error %v", err)
+		response, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+		if err != nil || len(response.ContactWindows) != 0 {
+			t.Errorf("ListContactWindows returned %v, %v, want no windows", response, err)
 		}
 	})
 }
 
+func TestPrototypeHandler_FrequencyPlan(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	newTransceiver := func(rxCenterFrequencyHz, txCenterFrequencyHz int64) *pb.Transceiver {
+		return &pb.Transceiver{
+			TransmitSignalChain: &pb.TransmitSignalChain{
+				Transmitter: &physical.Transmitter{Signals: []*physical.TransmitSignal{
+					{Signal: &physical.Signal{CenterFrequencyHz: txCenterFrequencyHz, BandwidthHz: 30000000}},
+				}},
+				Antenna: &physical.Antenna{Type: physical.Antenna_OPTICAL},
+			},
+			ReceiveSignalChain: &pb.ReceiveSignalChain{
+				Receiver: &physical.Receiver{Signals: []*physical.ReceiveSignal{
+					{Signal: &physical.Signal{CenterFrequencyHz: rxCenterFrequencyHz, BandwidthHz: 100000000}},
+				}},
+				Antenna: &physical.Antenna{Type: physical.Antenna_OPTICAL},
+			},
+		}
+	}
+	h := NewPrototypeHandler(WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+	if _, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{TransceiverId: "ku", Transceiver: newTransceiver(12000000000, 14000000000)}); err != nil {
+		t.Fatalf("CreateTransceiver failed: %v", err)
+	}
+	// The transmit signal is outside of the frequency plan.
+	if _, err := h.CreateTransceiver(ctx, &pb.CreateTransceiverRequest{TransceiverId: "x", Transceiver: newTransceiver(12000000000, 8000000000)}); err != nil {
+		t.Fatalf("CreateTransceiver failed: %v", err)
+	}
+	response, err := h.ListContactWindows(ctx, &pb.ListContactWindowsRequest{})
+	if err != nil {
+		t.Fatalf("ListContactWindows failed: %v", err)
+	}
+	want := []*pb.ContactWindow{{
+		Name:                   "contactWindows/ku-mysat",
+		Interval:               &interval.Interval{StartTime: timestamppb.New(now), EndTime: timestamppb.New(now.Add(24 * time.Hour))},
+		Transceiver:            "transceivers/ku",
+		Target:                 TARGET_NAME,
+		MinRxCenterFrequencyHz: 12000000000,
+		MaxRxCenterFrequencyHz: 12030000000,
+		MinRxBandwidthHz:       20000000,
+		MaxRxBandwidthHz:       40000000,
+		MinTxCenterFrequencyHz: 14000000000,
+		MaxTxCenterFrequencyHz: 14000000000,
+		MinTxBandwidthHz:       20000000,
+		MaxTxBandwidthHz:       30000000,
+	}}
+	if diff := cmp.Diff(want, response.ContactWindows, protocmp.Transform()); diff != "" {
+		t.Errorf("ListContactWindows mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func createInterval(startTimeOffset int, endTimeOffset int) *interval.Interval {
 	return &interval.Interval{
 		StartTime: &timestamppb.Timestamp{
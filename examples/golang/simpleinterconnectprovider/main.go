@@ -25,10 +25,17 @@ import (
 	"time"
 
 	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"outernetcouncil.org/nmts/v1/proto/types/geophys"
 
 	"github.com/outernetcouncil/federation/examples/golang/simpleinterconnectprovider/config"
 	examplehandler "github.com/outernetcouncil/federation/examples/golang/simpleinterconnectprovider/handler"
+	configpb "github.com/outernetcouncil/federation/gen/go/examples/golang/simpleinterconnectprovider/config"
+	"github.com/outernetcouncil/federation/pkg/go/frequencyplan"
+	"github.com/outernetcouncil/federation/pkg/go/idempotency"
 	"github.com/outernetcouncil/federation/pkg/go/interconnectprovider"
+	"github.com/outernetcouncil/federation/pkg/go/linkbudget"
+	"github.com/outernetcouncil/federation/pkg/go/reaper"
 	"github.com/outernetcouncil/federation/pkg/go/server"
 )
 
@@ -46,6 +53,19 @@ func baseContext(ctx context.Context) context.Context {
 	return log.With().Timestamp().Logger().WithContext(ctx)
 }
 
+func frequencyChannels(params []*configpb.FrequencyChannel) []frequencyplan.Channel {
+	channels := make([]frequencyplan.Channel, 0, len(params))
+	for _, c := range params {
+		channels = append(channels, frequencyplan.Channel{
+			LowHz:          c.GetLowHz(),
+			HighHz:         c.GetHighHz(),
+			MinBandwidthHz: c.GetMinBandwidthHz(),
+			MaxBandwidthHz: c.GetMaxBandwidthHz(),
+		})
+	}
+	return channels
+}
+
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -83,12 +103,57 @@ func main() {
 	}
 
 	// Initialize Servers based on configuration
-	grpcServer := server.NewGrpcServer(int(cp.GetPort()), examplehandler.NewPrototypeHandler(), *logger)
+	var handlerOpts []examplehandler.Option
+	if gracePeriod := cp.GetSoftDeleteParams().GetGracePeriod().AsDuration(); gracePeriod > 0 {
+		handlerOpts = append(handlerOpts, examplehandler.WithDeleteGracePeriod(gracePeriod))
+	}
+	if frequencyPlanParams := cp.GetContactWindowParams().GetTargetFrequencyPlan(); frequencyPlanParams != nil {
+		handlerOpts = append(handlerOpts, examplehandler.WithFrequencyPlan(frequencyplan.Plan{
+			Rx: frequencyChannels(frequencyPlanParams.GetRxChannels()),
+			Tx: frequencyChannels(frequencyPlanParams.GetTxChannels()),
+		}))
+	}
+	if contactWindowParams := cp.GetContactWindowParams(); contactWindowParams.GetHorizon() != nil {
+		handlerOpts = append(handlerOpts, examplehandler.WithOrbitalContactWindows(contactWindowParams.GetHorizon().AsDuration(), contactWindowParams.GetMinElevationDeg()))
+		if line1, line2 := contactWindowParams.GetTargetTleLine1(), contactWindowParams.GetTargetTleLine2(); line1 != "" || line2 != "" {
+			handlerOpts = append(handlerOpts, examplehandler.WithTargetMotion(&geophys.Motion{
+				Type: &geophys.Motion_TwoLineElementSet{TwoLineElementSet: &geophys.TwoLineElementSet{Line1: line1, Line2: line2}},
+			}))
+		}
+		if linkBudgetParams := contactWindowParams.GetLinkBudgetParams(); linkBudgetParams != nil {
+			handlerOpts = append(handlerOpts, examplehandler.WithLinkBudget(linkbudget.Terminal{
+				Transmitter: linkbudget.Transmitter{
+					PowerDBW:       linkBudgetParams.GetTargetTransmitPowerDbw(),
+					AntennaGainDBi: linkBudgetParams.GetTargetAntennaGainDbi(),
+				},
+				Receiver: linkbudget.Receiver{
+					AntennaGainDBi:          linkBudgetParams.GetTargetAntennaGainDbi(),
+					SystemNoiseTemperatureK: linkBudgetParams.GetTargetSystemNoiseTemperatureK(),
+				},
+				ModulationSchemes: linkBudgetParams.GetTargetModulationSchemes(),
+			}, linkBudgetParams.GetMinMarginDb()))
+		}
+	}
+	handler := examplehandler.NewPrototypeHandler(handlerOpts...)
+	var idempotencyOpts []idempotency.Option
+	if window := cp.GetIdempotencyParams().GetWindow().AsDuration(); window > 0 {
+		idempotencyOpts = append(idempotencyOpts, idempotency.WithWindow(window))
+	}
+	requestCache := idempotency.New(idempotencyOpts...)
+	grpcServer := server.NewGrpcServer(int(cp.GetPort()), handler, *logger, grpc.UnaryInterceptor(requestCache.UnaryServerInterceptor()))
 	pprofServer := server.NewPprofServer(cp.GetObservabilityParams().GetPprofAddress(), *logger)
 	channelzServer := server.NewChannelzServer(cp.GetObservabilityParams().GetChannelzAddress(), *logger)
+	servers := []server.Server{grpcServer, pprofServer, channelzServer}
+	if reaperParams := cp.GetReaperParams(); reaperParams.GetRetention() != nil {
+		var opts []reaper.Option
+		if interval := reaperParams.GetInterval().AsDuration(); interval > 0 {
+			opts = append(opts, reaper.WithInterval(interval))
+		}
+		servers = append(servers, reaper.New(handler, reaperParams.GetRetention().AsDuration(), *logger, opts...))
+	}
 
 	// Create InterconnectProvider with initialized servers
-	connector := interconnectprovider.NewInterconnectProvider(*logger, grpcServer, pprofServer, channelzServer)
+	connector := interconnectprovider.NewInterconnectProvider(*logger, servers...)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
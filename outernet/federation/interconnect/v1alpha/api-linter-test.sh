@@ -33,5 +33,8 @@ all_paths=$(process_virtual_imports "../org_outernetcouncil_nmts+")
 	-I "../googleapis+" \
 	--descriptor-set-in "$path_before_bazel_out"protobuf+/src/google/protobuf/duration_proto-descriptor-set.proto.bin \
 	--descriptor-set-in "$path_before_bazel_out"protobuf+/src/google/protobuf/empty_proto-descriptor-set.proto.bin \
+	--descriptor-set-in "$path_before_bazel_out"protobuf+/src/google/protobuf/field_mask_proto-descriptor-set.proto.bin \
+	--descriptor-set-in "$path_before_bazel_out"protobuf+/src/google/protobuf/timestamp_proto-descriptor-set.proto.bin \
+	--descriptor-set-in "$path_before_bazel_out"protobuf+/src/google/protobuf/any_proto-descriptor-set.proto.bin \
 	$all_paths \
 	--set-exit-status
@@ -0,0 +1,207 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package availability computes the regions of contact windows that are not
+// allocated to bearers.
+//
+// A contact window spans its interval and, for the receive and the transmit
+// direction, the band from its minimum center frequency minus half its
+// maximum bandwidth to its maximum center frequency plus half its maximum
+// bandwidth. A bearer occupies its interval and the band of half its
+// bandwidth on either side of its center frequency, in both directions. Two
+// bearers conflict if their intervals overlap and their receive or their
+// transmit bands overlap, so a region is a time slice together with a free
+// receive band and a free transmit band.
+package availability
+
+import (
+	"slices"
+	"time"
+
+	"google.golang.org/genproto/googleapis/type/interval"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+// Band is a closed range of frequencies in Hz.
+type Band struct {
+	Low, High int64
+}
+
+// bearerBand returns the band occupied by a bearer.
+func bearerBand(centerHz, bandwidthHz int64) Band {
+	return Band{Low: centerHz - bandwidthHz/2, High: centerHz + bandwidthHz/2}
+}
+
+// Overlaps reports whether the bands share at least one frequency.
+func (b Band) Overlaps(o Band) bool {
+	return b.Low <= o.High && o.Low <= b.High
+}
+
+// overlaps reports whether the half-open intervals [as, ae) and [bs, be)
+// overlap.
+func overlaps(as, ae, bs, be time.Time) bool {
+	return as.Before(be) && bs.Before(ae)
+}
+
+// Conflict reports whether two bearers conflict: they belong to the same
+// transceiver and target, their intervals overlap and their receive or their
+// transmit bands overlap.
+func Conflict(a, b *pb.Bearer) bool {
+	if a.Transceiver != b.Transceiver || a.Target != b.Target {
+		return false
+	}
+	if !overlaps(a.Interval.GetStartTime().AsTime(), a.Interval.GetEndTime().AsTime(),
+		b.Interval.GetStartTime().AsTime(), b.Interval.GetEndTime().AsTime()) {
+		return false
+	}
+	return bearerBand(a.RxCenterFrequencyHz, a.RxBandwidthHz).Overlaps(bearerBand(b.RxCenterFrequencyHz, b.RxBandwidthHz)) ||
+		bearerBand(a.TxCenterFrequencyHz, a.TxBandwidthHz).Overlaps(bearerBand(b.TxCenterFrequencyHz, b.TxBandwidthHz))
+}
+
+// Subtract returns the parts of the bands that do not overlap b, in the order
+// of the bands. Bands touching b are shortened by 1 Hz, since bearers whose
+// bands touch conflict.
+func Subtract(bands []Band, b Band) []Band {
+	var result []Band
+	for _, band := range bands {
+		if !band.Overlaps(b) {
+			result = append(result, band)
+			continue
+		}
+		if band.Low < b.Low {
+			result = append(result, Band{Low: band.Low, High: b.Low - 1})
+		}
+		if band.High > b.High {
+			result = append(result, Band{Low: b.High + 1, High: band.High})
+		}
+	}
+	return result
+}
+
+// limits are the constraints of a contact window on the center frequency and
+// bandwidth of a bearer in one direction.
+type limits struct {
+	minCenter, maxCenter       int64
+	minBandwidth, maxBandwidth int64
+}
+
+func (l limits) band() Band {
+	return Band{Low: l.minCenter - l.maxBandwidth/2, High: l.maxCenter + l.maxBandwidth/2}
+}
+
+// maxBandwidthIn returns the widest bandwidth of a bearer within the band, or
+// false if not even a bearer with the minimum bandwidth fits.
+func (l limits) maxBandwidthIn(b Band) (int64, bool) {
+	// The widest bearer is centered in the band, as far as the center
+	// frequency limits allow.
+	center := min(max(b.Low+(b.High-b.Low)/2, l.minCenter), l.maxCenter)
+	bandwidth := min(2*min(center-b.Low, b.High-center), l.maxBandwidth)
+	if center < b.Low || center > b.High || bandwidth < l.minBandwidth {
+		return 0, false
+	}
+	return bandwidth, true
+}
+
+// Regions returns the regions of the contact window within [start, end) that
+// are not allocated to any of the bearers. Bearers of other transceivers or
+// targets are ignored. Regions are ordered by start time, receive frequency
+// and transmit frequency. Consecutive time slices with the same free bands are
+// merged.
+func Regions(window *pb.ContactWindow, bearers []*pb.Bearer, start, end time.Time) []*pb.AvailabilityRegion {
+	if ws := window.Interval.GetStartTime().AsTime(); ws.After(start) {
+		start = ws
+	}
+	if we := window.Interval.GetEndTime().AsTime(); we.Before(end) {
+		end = we
+	}
+	if !start.Before(end) {
+		return nil
+	}
+
+	var relevant []*pb.Bearer
+	breakpoints := []time.Time{start, end}
+	for _, bearer := range bearers {
+		if bearer.Transceiver != window.Transceiver || bearer.Target != window.Target {
+			continue
+		}
+		bs, be := bearer.Interval.GetStartTime().AsTime(), bearer.Interval.GetEndTime().AsTime()
+		if !overlaps(bs, be, start, end) {
+			continue
+		}
+		relevant = append(relevant, bearer)
+		for _, t := range []time.Time{bs, be} {
+			if t.After(start) && t.Before(end) {
+				breakpoints = append(breakpoints, t)
+			}
+		}
+	}
+	slices.SortFunc(breakpoints, time.Time.Compare)
+	breakpoints = slices.CompactFunc(breakpoints, time.Time.Equal)
+
+	rx := limits{window.MinRxCenterFrequencyHz, window.MaxRxCenterFrequencyHz, window.MinRxBandwidthHz, window.MaxRxBandwidthHz}
+	tx := limits{window.MinTxCenterFrequencyHz, window.MaxTxCenterFrequencyHz, window.MinTxBandwidthHz, window.MaxTxBandwidthHz}
+
+	type segment struct {
+		start, end time.Time
+		rx, tx     []Band
+	}
+	var segments []segment
+	for i := 0; i+1 < len(breakpoints); i++ {
+		s := segment{start: breakpoints[i], end: breakpoints[i+1], rx: []Band{rx.band()}, tx: []Band{tx.band()}}
+		for _, bearer := range relevant {
+			bs, be := bearer.Interval.GetStartTime().AsTime(), bearer.Interval.GetEndTime().AsTime()
+			if !overlaps(bs, be, s.start, s.end) {
+				continue
+			}
+			s.rx = Subtract(s.rx, bearerBand(bearer.RxCenterFrequencyHz, bearer.RxBandwidthHz))
+			s.tx = Subtract(s.tx, bearerBand(bearer.TxCenterFrequencyHz, bearer.TxBandwidthHz))
+		}
+		if n := len(segments); n > 0 && slices.Equal(segments[n-1].rx, s.rx) && slices.Equal(segments[n-1].tx, s.tx) {
+			segments[n-1].end = s.end
+			continue
+		}
+		segments = append(segments, s)
+	}
+
+	var regions []*pb.AvailabilityRegion
+	for _, s := range segments {
+		for _, rxBand := range s.rx {
+			maxRx, ok := rx.maxBandwidthIn(rxBand)
+			if !ok {
+				continue
+			}
+			for _, txBand := range s.tx {
+				maxTx, ok := tx.maxBandwidthIn(txBand)
+				if !ok {
+					continue
+				}
+				regions = append(regions, &pb.AvailabilityRegion{
+					ContactWindow: window.Name,
+					Target:        window.Target,
+					Interval: &interval.Interval{
+						StartTime: timestamppb.New(s.start),
+						EndTime:   timestamppb.New(s.end),
+					},
+					RxFrequencyRange: &pb.FrequencyRange{MinFrequencyHz: rxBand.Low, MaxFrequencyHz: rxBand.High},
+					TxFrequencyRange: &pb.FrequencyRange{MinFrequencyHz: txBand.Low, MaxFrequencyHz: txBand.High},
+					MaxRxBandwidthHz: maxRx,
+					MaxTxBandwidthHz: maxTx,
+				})
+			}
+		}
+	}
+	return regions
+}
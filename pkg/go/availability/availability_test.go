@@ -0,0 +1,265 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package availability
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/type/interval"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+var epoch = time.Unix(1700000000, 0)
+
+func hours(start, end int) *interval.Interval {
+	return &interval.Interval{
+		StartTime: timestamppb.New(epoch.Add(time.Duration(start) * time.Hour)),
+		EndTime:   timestamppb.New(epoch.Add(time.Duration(end) * time.Hour)),
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		name  string
+		bands []Band
+		b     Band
+		want  []Band
+	}{
+		{
+			name:  "disjoint",
+			bands: []Band{{Low: 0, High: 10}},
+			b:     Band{Low: 20, High: 30},
+			want:  []Band{{Low: 0, High: 10}},
+		},
+		{
+			name:  "inside",
+			bands: []Band{{Low: 0, High: 100}},
+			b:     Band{Low: 20, High: 30},
+			want:  []Band{{Low: 0, High: 19}, {Low: 31, High: 100}},
+		},
+		{
+			name:  "touching",
+			bands: []Band{{Low: 0, High: 20}, {Low: 30, High: 40}},
+			b:     Band{Low: 20, High: 30},
+			want:  []Band{{Low: 0, High: 19}, {Low: 31, High: 40}},
+		},
+		{
+			name:  "covering",
+			bands: []Band{{Low: 20, High: 30}},
+			b:     Band{Low: 0, High: 100},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, Subtract(tt.bands, tt.b)); diff != "" {
+				t.Errorf("Subtract mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConflict(t *testing.T) {
+	bearer := func(start, end int, rxHz, txHz int64) *pb.Bearer {
+		return &pb.Bearer{
+			Transceiver:         "transceivers/a",
+			Target:              "targets/a",
+			Interval:            hours(start, end),
+			RxCenterFrequencyHz: rxHz,
+			RxBandwidthHz:       20,
+			TxCenterFrequencyHz: txHz,
+			TxBandwidthHz:       20,
+		}
+	}
+	otherTarget := bearer(0, 2, 100, 500)
+	otherTarget.Target = "targets/b"
+
+	existing := bearer(0, 2, 100, 500)
+	tests := []struct {
+		name   string
+		bearer *pb.Bearer
+		want   bool
+	}{
+		{name: "identical", bearer: bearer(0, 2, 100, 500), want: true},
+		{name: "contained", bearer: bearer(1, 2, 100, 500), want: true},
+		{name: "adjacent in time", bearer: bearer(2, 3, 100, 500), want: false},
+		{name: "touching receive band", bearer: bearer(0, 2, 120, 800), want: true},
+		{name: "overlapping transmit band only", bearer: bearer(0, 2, 300, 510), want: true},
+		{name: "disjoint bands", bearer: bearer(0, 2, 121, 521), want: false},
+		{name: "other target", bearer: otherTarget, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Conflict(existing, tt.bearer); got != tt.want {
+				t.Errorf("Conflict() = %v, want %v", got, tt.want)
+			}
+			if got := Conflict(tt.bearer, existing); got != tt.want {
+				t.Errorf("Conflict() with swapped bearers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegions(t *testing.T) {
+	window := &pb.ContactWindow{
+		Name:                   "contactWindows/a",
+		Interval:               hours(0, 24),
+		Transceiver:            "transceivers/a",
+		Target:                 "targets/a",
+		MinRxCenterFrequencyHz: 1000,
+		MaxRxCenterFrequencyHz: 2000,
+		MinRxBandwidthHz:       20,
+		MaxRxBandwidthHz:       100,
+		MinTxCenterFrequencyHz: 3000,
+		MaxTxCenterFrequencyHz: 4000,
+		MinTxBandwidthHz:       20,
+		MaxTxBandwidthHz:       100,
+	}
+	bearer := func(iv *interval.Interval, rx, tx int64) *pb.Bearer {
+		return &pb.Bearer{
+			Interval:            iv,
+			Transceiver:         "transceivers/a",
+			Target:              "targets/a",
+			RxCenterFrequencyHz: rx,
+			RxBandwidthHz:       40,
+			TxCenterFrequencyHz: tx,
+			TxBandwidthHz:       40,
+		}
+	}
+	region := func(iv *interval.Interval, rx, tx Band, maxRx, maxTx int64) *pb.AvailabilityRegion {
+		return &pb.AvailabilityRegion{
+			ContactWindow:    "contactWindows/a",
+			Target:           "targets/a",
+			Interval:         iv,
+			RxFrequencyRange: &pb.FrequencyRange{MinFrequencyHz: rx.Low, MaxFrequencyHz: rx.High},
+			TxFrequencyRange: &pb.FrequencyRange{MinFrequencyHz: tx.Low, MaxFrequencyHz: tx.High},
+			MaxRxBandwidthHz: maxRx,
+			MaxTxBandwidthHz: maxTx,
+		}
+	}
+	fullRx, fullTx := Band{Low: 950, High: 2050}, Band{Low: 2950, High: 4050}
+
+	tests := []struct {
+		name       string
+		bearers    []*pb.Bearer
+		start, end int
+		want       []*pb.AvailabilityRegion
+	}{
+		{
+			name:  "no bearers",
+			start: 1,
+			end:   2,
+			want: []*pb.AvailabilityRegion{
+				region(hours(1, 2), fullRx, fullTx, 100, 100),
+			},
+		},
+		{
+			name:  "clipped to the contact window",
+			start: 20,
+			end:   30,
+			want: []*pb.AvailabilityRegion{
+				region(hours(20, 24), fullRx, fullTx, 100, 100),
+			},
+		},
+		{
+			name:    "a bearer splits the spectrum during its interval",
+			bearers: []*pb.Bearer{bearer(hours(2, 3), 1500, 3500)},
+			start:   1,
+			end:     4,
+			want: []*pb.AvailabilityRegion{
+				region(hours(1, 2), fullRx, fullTx, 100, 100),
+				region(hours(2, 3), Band{Low: 950, High: 1479}, Band{Low: 2950, High: 3479}, 100, 100),
+				region(hours(2, 3), Band{Low: 950, High: 1479}, Band{Low: 3521, High: 4050}, 100, 100),
+				region(hours(2, 3), Band{Low: 1521, High: 2050}, Band{Low: 2950, High: 3479}, 100, 100),
+				region(hours(2, 3), Band{Low: 1521, High: 2050}, Band{Low: 3521, High: 4050}, 100, 100),
+				region(hours(3, 4), fullRx, fullTx, 100, 100),
+			},
+		},
+		{
+			name: "gaps narrower than the minimum bandwidth are omitted",
+			bearers: []*pb.Bearer{
+				bearer(hours(0, 24), 1500, 3500),
+				bearer(hours(0, 24), 1550, 3550),
+			},
+			start: 1,
+			end:   2,
+			want: []*pb.AvailabilityRegion{
+				region(hours(1, 2), Band{Low: 950, High: 1479}, Band{Low: 2950, High: 3479}, 100, 100),
+				region(hours(1, 2), Band{Low: 950, High: 1479}, Band{Low: 3571, High: 4050}, 100, 100),
+				region(hours(1, 2), Band{Low: 1571, High: 2050}, Band{Low: 2950, High: 3479}, 100, 100),
+				region(hours(1, 2), Band{Low: 1571, High: 2050}, Band{Low: 3571, High: 4050}, 100, 100),
+			},
+		},
+		{
+			name:    "bearers of other transceivers are ignored",
+			bearers: []*pb.Bearer{{Interval: hours(0, 24), Transceiver: "transceivers/b", Target: "targets/a", RxCenterFrequencyHz: 1500, RxBandwidthHz: 40}},
+			start:   1,
+			end:     2,
+			want: []*pb.AvailabilityRegion{
+				region(hours(1, 2), fullRx, fullTx, 100, 100),
+			},
+		},
+		{
+			name:  "empty time range",
+			start: 2,
+			end:   2,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Regions(window, tt.bearers, epoch.Add(time.Duration(tt.start)*time.Hour), epoch.Add(time.Duration(tt.end)*time.Hour))
+			if diff := cmp.Diff(tt.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("Regions mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegions_MaxBandwidth(t *testing.T) {
+	window := &pb.ContactWindow{
+		Interval:               hours(0, 1),
+		MinRxCenterFrequencyHz: 1000,
+		MaxRxCenterFrequencyHz: 1000,
+		MinRxBandwidthHz:       10,
+		MaxRxBandwidthHz:       100,
+		MinTxCenterFrequencyHz: 1000,
+		MaxTxCenterFrequencyHz: 1000,
+		MinTxBandwidthHz:       10,
+		MaxTxBandwidthHz:       100,
+	}
+	// The bearer occupies [1010, 1030], so a bearer centered at 1000 may
+	// span up to 1009.
+	bearers := []*pb.Bearer{{
+		Interval:            hours(0, 1),
+		RxCenterFrequencyHz: 1020,
+		RxBandwidthHz:       20,
+		TxCenterFrequencyHz: 1020,
+		TxBandwidthHz:       20,
+	}}
+
+	regions := Regions(window, bearers, epoch, epoch.Add(time.Hour))
+	if len(regions) != 1 {
+		t.Fatalf("Regions returned %v, want one region", regions)
+	}
+	if got := regions[0].MaxRxBandwidthHz; got != 18 {
+		t.Errorf("max_rx_bandwidth_hz is %d, want 18", got)
+	}
+}
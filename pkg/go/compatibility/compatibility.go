@@ -0,0 +1,145 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compatibility explains whether a Transceiver is compatible with the
+// transceiver types advertised by a provider.
+//
+// Clients can call Diagnose with the response of ListCompatibleTransceiverTypes
+// before they create a transceiver, and providers can attach the report to the
+// FailedPrecondition error of CreateTransceiver and UpdateTransceiver.
+package compatibility
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"github.com/outernetcouncil/federation/pkg/go/filter"
+)
+
+// ViolationType is the type of the google.rpc.PreconditionFailure violations
+// produced by a Report.
+const ViolationType = "INCOMPATIBLE_TRANSCEIVER"
+
+// Report is the result of matching a transceiver against every advertised
+// compatible transceiver type.
+type Report struct {
+	Types []TypeReport
+}
+
+// TypeReport explains the result for one CompatibleTransceiverType.
+type TypeReport struct {
+	// Index is the index of the type in the ListCompatibleTransceiverTypes
+	// response.
+	Index    int
+	Filter   string
+	Failures []filter.Failure
+}
+
+// Matched reports whether the transceiver satisfies the type.
+func (t TypeReport) Matched() bool { return len(t.Failures) == 0 }
+
+// Diagnose matches the transceiver against each advertised type and records
+// the clauses that it does not satisfy.
+func Diagnose(resp *pb.ListCompatibleTransceiverTypesResponse, transceiver *pb.Transceiver) (*Report, error) {
+	report := &Report{}
+	for i, compatibleType := range resp.GetCompatibleTransceiverTypes() {
+		f, err := filter.Parse(compatibleType.TransceiverFilter)
+		if err != nil {
+			return nil, fmt.Errorf("compatible transceiver type %d: %w", i, err)
+		}
+		failures, err := f.Explain(transceiver)
+		if err != nil {
+			return nil, fmt.Errorf("compatible transceiver type %d: %w", i, err)
+		}
+		report.Types = append(report.Types, TypeReport{
+			Index:    i,
+			Filter:   compatibleType.TransceiverFilter,
+			Failures: failures,
+		})
+	}
+	return report, nil
+}
+
+// Compatible reports whether the transceiver matches any of the types. A
+// provider which advertises no types accepts no transceivers.
+func (r *Report) Compatible() bool {
+	for _, t := range r.Types {
+		if t.Matched() {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the report for humans, one line per failed clause.
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, t := range r.Types {
+		if t.Matched() {
+			fmt.Fprintf(&b, "compatible_transceiver_types[%d]: matched\n", t.Index)
+			continue
+		}
+		for _, f := range t.Failures {
+			fmt.Fprintf(&b, "compatible_transceiver_types[%d]: %s\n", t.Index, describe(f))
+		}
+	}
+	return b.String()
+}
+
+// PreconditionFailure returns one violation per failed clause, with the
+// index of the compatible transceiver type as subject.
+func (r *Report) PreconditionFailure() *errdetails.PreconditionFailure {
+	details := &errdetails.PreconditionFailure{}
+	for _, t := range r.Types {
+		for _, f := range t.Failures {
+			details.Violations = append(details.Violations, &errdetails.PreconditionFailure_Violation{
+				Type:        ViolationType,
+				Subject:     fmt.Sprintf("compatible_transceiver_types[%d]", t.Index),
+				Description: describe(f),
+			})
+		}
+	}
+	return details
+}
+
+// Err returns nil if the transceiver is compatible, and a FailedPrecondition
+// status carrying the PreconditionFailure details otherwise.
+func (r *Report) Err() error {
+	if r.Compatible() {
+		return nil
+	}
+	st := status.New(codes.FailedPrecondition, "transceiver is not compatible, see ListCompatibleTransceiverTypes for details")
+	if withDetails, err := st.WithDetails(r.PreconditionFailure()); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+func describe(f filter.Failure) string {
+	switch {
+	case f.Path == "":
+		return fmt.Sprintf("`%s` is not satisfied", f.Clause)
+	case len(f.Actual) == 0:
+		return fmt.Sprintf("`%s` is not satisfied, %s is unset", f.Clause, f.Path)
+	case len(f.Actual) == 1:
+		return fmt.Sprintf("`%s` is not satisfied, %s is %s", f.Clause, f.Path, f.Actual[0])
+	default:
+		return fmt.Sprintf("`%s` is not satisfied, %s has values [%s]", f.Clause, f.Path, strings.Join(f.Actual, ", "))
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compatibility
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"outernetcouncil.org/nmts/v1/proto/ek/physical"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+func TestDiagnose(t *testing.T) {
+	resp := &pb.ListCompatibleTransceiverTypesResponse{
+		CompatibleTransceiverTypes: []*pb.CompatibleTransceiverType{
+			{TransceiverFilter: "transmit_signal_chain.antenna.type = OPTICAL AND receive_signal_chain.antenna.type = OPTICAL"},
+			{TransceiverFilter: "transmit_signal_chain.transmitter.signals.signal.center_frequency_hz:(>= 8000000000 AND <= 12000000000)"},
+		},
+	}
+	rf := &pb.Transceiver{
+		TransmitSignalChain: &pb.TransmitSignalChain{
+			Antenna: &physical.Antenna{Type: physical.Antenna_RF},
+			Transmitter: &physical.Transmitter{
+				Signals: []*physical.TransmitSignal{
+					{Signal: &physical.Signal{CenterFrequencyHz: 7000000000}},
+					{Signal: &physical.Signal{CenterFrequencyHz: 14000000000}},
+				},
+			},
+		},
+	}
+
+	report, err := Diagnose(resp, rf)
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	if report.Compatible() {
+		t.Fatalf("transceiver should not be compatible")
+	}
+
+	want := &errdetails.PreconditionFailure{
+		Violations: []*errdetails.PreconditionFailure_Violation{
+			{
+				Type:        ViolationType,
+				Subject:     "compatible_transceiver_types[0]",
+				Description: "`transmit_signal_chain.antenna.type = OPTICAL` is not satisfied, transmit_signal_chain.antenna.type is RF",
+			},
+			{
+				Type:        ViolationType,
+				Subject:     "compatible_transceiver_types[0]",
+				Description: "`receive_signal_chain.antenna.type = OPTICAL` is not satisfied, receive_signal_chain.antenna.type is unset",
+			},
+			{
+				Type:        ViolationType,
+				Subject:     "compatible_transceiver_types[1]",
+				Description: "`transmit_signal_chain.transmitter.signals.signal.center_frequency_hz:(>= 8000000000 AND <= 12000000000)` is not satisfied, transmit_signal_chain.transmitter.signals.signal.center_frequency_hz has values [7000000000, 14000000000]",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, report.PreconditionFailure(), protocmp.Transform()); diff != "" {
+		t.Errorf("PreconditionFailure mismatch (-want +got):\n%s", diff)
+	}
+
+	err = report.Err()
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Err() = %v, want FailedPrecondition", err)
+	}
+	if details := status.Convert(err).Details(); len(details) != 1 {
+		t.Errorf("Err() has details %v, want a PreconditionFailure", details)
+	}
+}
+
+func TestDiagnose_Compatible(t *testing.T) {
+	resp := &pb.ListCompatibleTransceiverTypesResponse{
+		CompatibleTransceiverTypes: []*pb.CompatibleTransceiverType{
+			{TransceiverFilter: "transmit_signal_chain.antenna.type = RF"},
+			{TransceiverFilter: "transmit_signal_chain.antenna.type = OPTICAL"},
+		},
+	}
+	optical := &pb.Transceiver{
+		TransmitSignalChain: &pb.TransmitSignalChain{
+			Antenna: &physical.Antenna{Type: physical.Antenna_OPTICAL},
+		},
+	}
+
+	report, err := Diagnose(resp, optical)
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	if !report.Compatible() || report.Err() != nil {
+		t.Fatalf("transceiver should be compatible:\n%s", report)
+	}
+	if report.Types[0].Matched() || !report.Types[1].Matched() {
+		t.Errorf("unexpected matches:\n%s", report)
+	}
+}
+
+func TestDiagnose_InvalidFilter(t *testing.T) {
+	resp := &pb.ListCompatibleTransceiverTypesResponse{
+		CompatibleTransceiverTypes: []*pb.CompatibleTransceiverType{
+			{TransceiverFilter: "transmit_signal_chain.antenna.type = LASER"},
+		},
+	}
+	transceiver := &pb.Transceiver{
+		TransmitSignalChain: &pb.TransmitSignalChain{
+			Antenna: &physical.Antenna{Type: physical.Antenna_OPTICAL},
+		},
+	}
+
+	if _, err := Diagnose(resp, transceiver); err == nil {
+		t.Errorf("Diagnose should have failed")
+	}
+}
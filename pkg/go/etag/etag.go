@@ -0,0 +1,73 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package etag computes and checks the etags of resources for optimistic
+// concurrency control, see https://google.aip.dev/154.
+//
+// An etag is a hash of the deterministic binary encoding of a resource,
+// excluding the etag field itself. It changes whenever the content of the
+// resource changes and is stable across calls within the same server binary.
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldName is the name of the etag field of a resource.
+const fieldName protoreflect.Name = "etag"
+
+func field(m protoreflect.Message) protoreflect.FieldDescriptor {
+	fd := m.Descriptor().Fields().ByName(fieldName)
+	if fd == nil || fd.Kind() != protoreflect.StringKind || fd.Cardinality() == protoreflect.Repeated {
+		panic("etag: " + string(m.Descriptor().FullName()) + " has no string etag field")
+	}
+	return fd
+}
+
+// Compute returns the etag of a resource. It panics if the resource has no
+// string field named etag.
+func Compute(m proto.Message) string {
+	clone := proto.Clone(m)
+	clone.ProtoReflect().Clear(field(clone.ProtoReflect()))
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(clone)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:18])
+}
+
+// Set sets the etag field of a resource to its computed etag.
+func Set(m proto.Message) {
+	r := m.ProtoReflect()
+	r.Set(field(r), protoreflect.ValueOfString(Compute(m)))
+}
+
+// Check fails with ABORTED if the etag is not empty and does not match the
+// etag of the current state of the resource.
+func Check(current proto.Message, etag string) error {
+	if etag == "" {
+		return nil
+	}
+	if etag != Compute(current) {
+		return status.Errorf(codes.Aborted, "etag %q does not match the current etag, get the resource and retry", etag)
+	}
+	return nil
+}
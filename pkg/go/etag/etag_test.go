@@ -0,0 +1,80 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etag
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+func TestCompute(t *testing.T) {
+	bearer := &pb.Bearer{Name: "bearers/a", RxBandwidthHz: 1}
+	etag := Compute(bearer)
+
+	if got := Compute(&pb.Bearer{Name: "bearers/a", RxBandwidthHz: 1}); got != etag {
+		t.Errorf("Compute of equal bearers returned %q and %q", etag, got)
+	}
+	if got := Compute(&pb.Bearer{Name: "bearers/a", RxBandwidthHz: 1, Etag: "stale"}); got != etag {
+		t.Errorf("Compute depends on the etag field: got %q, want %q", got, etag)
+	}
+	if got := Compute(&pb.Bearer{Name: "bearers/a", RxBandwidthHz: 2}); got == etag {
+		t.Errorf("Compute of different bearers returned the same etag %q", got)
+	}
+	if bearer.Etag != "" {
+		t.Errorf("Compute modified the bearer")
+	}
+}
+
+func TestSet(t *testing.T) {
+	bearer := &pb.Bearer{Name: "bearers/a"}
+	Set(bearer)
+	if bearer.Etag == "" || bearer.Etag != Compute(bearer) {
+		t.Errorf("Set set etag %q, want %q", bearer.Etag, Compute(bearer))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	current := &pb.Transceiver{Name: "transceivers/a"}
+	Set(current)
+
+	for _, test := range []struct {
+		name string
+		etag string
+		want codes.Code
+	}{
+		{name: "empty etag", etag: "", want: codes.OK},
+		{name: "current etag", etag: current.Etag, want: codes.OK},
+		{name: "stale etag", etag: Compute(&pb.Transceiver{Name: "transceivers/b"}), want: codes.Aborted},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := status.Code(Check(current, test.etag)); got != test.want {
+				t.Errorf("Check returned %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCompute_NoEtagField(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Compute did not panic")
+		}
+	}()
+	Compute(&pb.Target{})
+}
@@ -0,0 +1,224 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fieldmask implements partial updates with field masks, see
+// https://google.aip.dev/134 and https://google.aip.dev/161.
+//
+// A path selects a field by its name and may traverse singular message fields,
+// e.g. `platform.motion`. Repeated and map fields can only be replaced as a
+// whole. The wildcard path `*` selects all fields.
+package fieldmask
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Wildcard is the path that selects all fields.
+const Wildcard = "*"
+
+// IsFull reports whether the mask replaces the whole resource, i.e. whether it
+// is empty or consists of the wildcard.
+func IsFull(mask *fieldmaskpb.FieldMask) bool {
+	paths := mask.GetPaths()
+	return len(paths) == 0 || len(paths) == 1 && paths[0] == Wildcard
+}
+
+// Validate checks that every path of the mask names a field of the message
+// and does not select an immutable field. Fields annotated as IDENTIFIER,
+// OUTPUT_ONLY or IMMUTABLE are immutable, and so are the given paths and
+// their subfields. Errors are reported as InvalidArgument with a
+// google.rpc.BadRequest field violation per offending path.
+func Validate(mask *fieldmaskpb.FieldMask, md protoreflect.MessageDescriptor, immutable ...string) error {
+	var fieldViolations []*errdetails.BadRequest_FieldViolation
+	for i, path := range mask.GetPaths() {
+		violation := func(format string, args ...any) {
+			fieldViolations = append(fieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fmt.Sprintf("update_mask.paths[%d]", i),
+				Description: fmt.Sprintf(format, args...),
+			})
+		}
+		if path == Wildcard {
+			if len(mask.GetPaths()) > 1 {
+				violation("%q must be the only path", Wildcard)
+			}
+			continue
+		}
+
+		fds, err := lookup(md, path)
+		if err != nil {
+			violation("%v", err)
+			continue
+		}
+		for j, fd := range fds {
+			prefix := strings.Join(strings.Split(path, ".")[:j+1], ".")
+			if isImmutable(fd) || slices.Contains(immutable, prefix) {
+				violation("field %q is immutable", prefix)
+				break
+			}
+		}
+	}
+
+	return invalidArgument("invalid update_mask", fieldViolations)
+}
+
+// CheckImmutable checks that the fields annotated as IMMUTABLE have the same
+// value in the current and the updated message, for updates which replace the
+// whole resource. Errors are reported like by Validate.
+func CheckImmutable(current, updated proto.Message) error {
+	var fieldViolations []*errdetails.BadRequest_FieldViolation
+	c, u := current.ProtoReflect(), updated.ProtoReflect()
+	fields := c.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		behaviors, _ := proto.GetExtension(fd.Options(), annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+		if !slices.Contains(behaviors, annotations.FieldBehavior_IMMUTABLE) {
+			continue
+		}
+		// Compare messages which only have the field set.
+		cf, uf := c.New(), u.New()
+		if c.Has(fd) {
+			cf.Set(fd, c.Get(fd))
+		}
+		if u.Has(fd) {
+			uf.Set(fd, u.Get(fd))
+		}
+		if !proto.Equal(cf.Interface(), uf.Interface()) {
+			fieldViolations = append(fieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       string(fd.Name()),
+				Description: fmt.Sprintf("field %q is immutable", fd.Name()),
+			})
+		}
+	}
+	return invalidArgument("immutable fields cannot be changed", fieldViolations)
+}
+
+// invalidArgument returns an InvalidArgument error with the field violations,
+// or nil if there are none.
+func invalidArgument(msg string, fieldViolations []*errdetails.BadRequest_FieldViolation) error {
+	if len(fieldViolations) == 0 {
+		return nil
+	}
+	st := status.New(codes.InvalidArgument, msg)
+	if withDetails, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: fieldViolations}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// lookup resolves a path to the fields it traverses.
+func lookup(md protoreflect.MessageDescriptor, path string) ([]protoreflect.FieldDescriptor, error) {
+	var fds []protoreflect.FieldDescriptor
+	segments := strings.Split(path, ".")
+	for i, name := range segments {
+		if md == nil {
+			return nil, fmt.Errorf("%q is not a message field", strings.Join(segments[:i], "."))
+		}
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return nil, fmt.Errorf("%s has no field %q", md.FullName(), name)
+		}
+		fds = append(fds, fd)
+		md = nil
+		if fd.Message() != nil && fd.Cardinality() != protoreflect.Repeated {
+			md = fd.Message()
+		}
+	}
+	return fds, nil
+}
+
+func isImmutable(fd protoreflect.FieldDescriptor) bool {
+	behaviors, _ := proto.GetExtension(fd.Options(), annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+	for _, behavior := range behaviors {
+		switch behavior {
+		case annotations.FieldBehavior_IDENTIFIER, annotations.FieldBehavior_OUTPUT_ONLY, annotations.FieldBehavior_IMMUTABLE:
+			return true
+		}
+	}
+	return false
+}
+
+// Apply copies the fields selected by the mask from src to dst, which must be
+// messages of the same type. A selected field that is unset in src is cleared
+// in dst. If the mask is full, dst is replaced by src, except for the fields
+// annotated as OUTPUT_ONLY, which keep their value in dst. The mask must have
+// been validated.
+func Apply(dst, src proto.Message, mask *fieldmaskpb.FieldMask) {
+	if IsFull(mask) {
+		current := proto.Clone(dst).ProtoReflect()
+		proto.Reset(dst)
+		proto.Merge(dst, src)
+		d := dst.ProtoReflect()
+		fields := d.Descriptor().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			behaviors, _ := proto.GetExtension(fd.Options(), annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+			if !slices.Contains(behaviors, annotations.FieldBehavior_OUTPUT_ONLY) {
+				continue
+			}
+			if current.Has(fd) {
+				d.Set(fd, current.Get(fd))
+			} else {
+				d.Clear(fd)
+			}
+		}
+		return
+	}
+
+	src = proto.Clone(src)
+paths:
+	for _, path := range mask.GetPaths() {
+		d, s := dst.ProtoReflect(), src.ProtoReflect()
+		segments := strings.Split(path, ".")
+		for _, name := range segments[:len(segments)-1] {
+			fd := d.Descriptor().Fields().ByName(protoreflect.Name(name))
+			if !d.Has(fd) && !s.Has(fd) {
+				// The field is unset in both messages.
+				continue paths
+			}
+			s = s.Get(fd).Message()
+			d = d.Mutable(fd).Message()
+		}
+
+		fd := d.Descriptor().Fields().ByName(protoreflect.Name(segments[len(segments)-1]))
+		if s.Has(fd) {
+			d.Set(fd, s.Get(fd))
+		} else {
+			d.Clear(fd)
+		}
+	}
+}
+
+// Covers reports whether the mask selects the field at path or any of its
+// subfields or parents.
+func Covers(mask *fieldmaskpb.FieldMask, path string) bool {
+	if IsFull(mask) {
+		return true
+	}
+	for _, p := range mask.GetPaths() {
+		if p == path || strings.HasPrefix(p, path+".") || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
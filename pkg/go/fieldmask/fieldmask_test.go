@@ -0,0 +1,190 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fieldmask
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/genproto/googleapis/type/interval"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+func circuit(bearer string, start int64, prefixLength int32) *pb.AttachmentCircuit {
+	return &pb.AttachmentCircuit{
+		Name: "attachmentCircuits/a",
+		Interval: &interval.Interval{
+			StartTime: &timestamppb.Timestamp{Seconds: start},
+			EndTime:   &timestamppb.Timestamp{Seconds: start + 60},
+		},
+		L2Connection: &pb.AttachmentCircuit_L2Connection{Bearer: bearer},
+		IpConnection: &pb.AttachmentCircuit_IpConnection{PrefixLength: prefixLength},
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		src   *pb.AttachmentCircuit
+		want  *pb.AttachmentCircuit
+	}{
+		{
+			name:  "empty mask replaces the resource",
+			paths: nil,
+			src:   &pb.AttachmentCircuit{Name: "attachmentCircuits/a"},
+			want:  &pb.AttachmentCircuit{Name: "attachmentCircuits/a"},
+		},
+		{
+			name:  "wildcard replaces the resource",
+			paths: []string{"*"},
+			src:   circuit("bearers/b", 100, 24),
+			want:  circuit("bearers/b", 100, 24),
+		},
+		{
+			name:  "full mask keeps output only fields",
+			paths: nil,
+			src: func() *pb.AttachmentCircuit {
+				c := circuit("bearers/b", 100, 24)
+				c.State = pb.LifecycleState_LIFECYCLE_STATE_FAILED
+				c.DeleteTime = &timestamppb.Timestamp{Seconds: 5}
+				return c
+			}(),
+			want: circuit("bearers/b", 100, 24),
+		},
+		{
+			name:  "nested field",
+			paths: []string{"l2_connection.bearer"},
+			src:   circuit("bearers/b", 100, 24),
+			want:  circuit("bearers/b", 0, 30),
+		},
+		{
+			name:  "message field is replaced as a whole",
+			paths: []string{"interval"},
+			src:   &pb.AttachmentCircuit{Interval: &interval.Interval{EndTime: &timestamppb.Timestamp{Seconds: 5}}},
+			want: func() *pb.AttachmentCircuit {
+				c := circuit("bearers/a", 0, 30)
+				c.Interval = &interval.Interval{EndTime: &timestamppb.Timestamp{Seconds: 5}}
+				return c
+			}(),
+		},
+		{
+			name:  "unset field in source is cleared",
+			paths: []string{"ip_connection.prefix_length", "l2_connection"},
+			src:   &pb.AttachmentCircuit{},
+			want: func() *pb.AttachmentCircuit {
+				c := circuit("bearers/a", 0, 30)
+				c.L2Connection = nil
+				c.IpConnection = &pb.AttachmentCircuit_IpConnection{}
+				return c
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := circuit("bearers/a", 0, 30)
+			Apply(dst, tt.src, &fieldmaskpb.FieldMask{Paths: tt.paths})
+			if diff := cmp.Diff(tt.want, dst, protocmp.Transform()); diff != "" {
+				t.Errorf("Apply mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	md := (&pb.AttachmentCircuit{}).ProtoReflect().Descriptor()
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{name: "empty mask", paths: nil},
+		{name: "wildcard", paths: []string{"*"}},
+		{name: "nested fields", paths: []string{"interval.start_time", "routing_protocols"}},
+		{name: "annotated as immutable", paths: []string{"l2_connection.bearer"}, want: []string{"update_mask.paths[0]"}},
+		{name: "unknown field", paths: []string{"interval", "bogus"}, want: []string{"update_mask.paths[1]"}},
+		{name: "identifier", paths: []string{"name"}, want: []string{"update_mask.paths[0]"}},
+		{name: "immutable path", paths: []string{"etag"}, want: []string{"update_mask.paths[0]"}},
+		{name: "subfield of immutable path", paths: []string{"ip_connection.prefix_length"}, want: []string{"update_mask.paths[0]"}},
+		{name: "traverses repeated field", paths: []string{"routing_protocols.direct"}, want: []string{"update_mask.paths[0]"}},
+		{name: "wildcard with other paths", paths: []string{"*", "interval"}, want: []string{"update_mask.paths[0]"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&fieldmaskpb.FieldMask{Paths: tt.paths}, md, "etag", "ip_connection")
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate failed: %v", err)
+				}
+				return
+			}
+			st := status.Convert(err)
+			if st.Code() != codes.InvalidArgument {
+				t.Fatalf("Validate returned %v, want InvalidArgument", err)
+			}
+			var got []string
+			for _, detail := range st.Details() {
+				for _, violation := range detail.(*errdetails.BadRequest).FieldViolations {
+					got = append(got, violation.Field)
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("field violations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckImmutable(t *testing.T) {
+	current := circuit("bearers/a", 0, 30)
+	if err := CheckImmutable(current, circuit("bearers/a", 100, 24)); err != nil {
+		t.Errorf("CheckImmutable failed for mutable changes: %v", err)
+	}
+	for _, updated := range []*pb.AttachmentCircuit{
+		circuit("bearers/b", 0, 30),
+		{Name: "attachmentCircuits/a"},
+	} {
+		if err := CheckImmutable(current, updated); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("CheckImmutable(%v) returned %v, want InvalidArgument", updated, err)
+		}
+	}
+}
+
+func TestCovers(t *testing.T) {
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"platform.motion", "transmit_signal_chain"}}
+	for path, want := range map[string]bool{
+		"platform":                      true,
+		"platform.motion":               true,
+		"platform.name":                 false,
+		"transmit_signal_chain.antenna": true,
+		"receive_signal_chain":          false,
+	} {
+		if got := Covers(mask, path); got != want {
+			t.Errorf("Covers(%q) = %v, want %v", path, got, want)
+		}
+	}
+	if !Covers(nil, "platform") {
+		t.Errorf("Covers(nil) = false, want true")
+	}
+}
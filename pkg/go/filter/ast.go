@@ -0,0 +1,182 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Operator is a comparator used in a restriction.
+type Operator int
+
+const (
+	// OpNone marks a bare comparable without a comparator, e.g. a global
+	// text restriction.
+	OpNone Operator = iota
+	OpEquals
+	OpNotEquals
+	OpLess
+	OpLessEquals
+	OpGreater
+	OpGreaterEquals
+	OpHas
+)
+
+func (o Operator) String() string {
+	switch o {
+	case OpEquals:
+		return "="
+	case OpNotEquals:
+		return "!="
+	case OpLess:
+		return "<"
+	case OpLessEquals:
+		return "<="
+	case OpGreater:
+		return ">"
+	case OpGreaterEquals:
+		return ">="
+	case OpHas:
+		return ":"
+	default:
+		return ""
+	}
+}
+
+// Expr is a node of a parsed filter expression.
+type Expr interface {
+	// Position returns the byte offset of the node in the filter string.
+	Position() int
+	String() string
+	isExpr()
+}
+
+// AndExpr is satisfied if all of its arguments are satisfied. Both explicit
+// AND and whitespace-separated sequences produce an AndExpr.
+type AndExpr struct {
+	Pos  int
+	Args []Expr
+}
+
+// OrExpr is satisfied if any of its arguments is satisfied.
+type OrExpr struct {
+	Pos  int
+	Args []Expr
+}
+
+// NotExpr negates its argument. Both NOT and the '-' prefix produce a NotExpr.
+type NotExpr struct {
+	Pos int
+	Arg Expr
+}
+
+// Restriction compares the values found at Path against Arg.
+//
+// Path is relative to the message being matched. Inside a composite argument
+// it is relative to each value of the enclosing restriction, and an empty
+// Path refers to the value itself, as in the range restriction
+// `center_frequency_hz:(>= 8000000000 AND <= 12000000000)`.
+type Restriction struct {
+	Pos  int
+	Path []string
+	Op   Operator
+	Arg  Arg
+	// Inherited is set for a bare value inside a composite argument, which
+	// takes its comparator from the enclosing restriction.
+	Inherited bool
+}
+
+// Arg is the right hand side of a restriction.
+type Arg interface {
+	Position() int
+	String() string
+	isArg()
+}
+
+// Literal is a quoted or unquoted value.
+type Literal struct {
+	Pos    int
+	Value  string
+	Quoted bool
+}
+
+// Composite is a parenthesized expression used as an argument. It is
+// evaluated against each value of the enclosing restriction, and is
+// satisfied if a single value satisfies the whole expression.
+type Composite struct {
+	Pos  int
+	Expr Expr
+}
+
+func (e *AndExpr) Position() int     { return e.Pos }
+func (e *OrExpr) Position() int      { return e.Pos }
+func (e *NotExpr) Position() int     { return e.Pos }
+func (e *Restriction) Position() int { return e.Pos }
+func (a *Literal) Position() int     { return a.Pos }
+func (a *Composite) Position() int   { return a.Pos }
+
+func (*AndExpr) isExpr()     {}
+func (*OrExpr) isExpr()      {}
+func (*NotExpr) isExpr()     {}
+func (*Restriction) isExpr() {}
+func (*Literal) isArg()      {}
+func (*Composite) isArg()    {}
+
+func (e *AndExpr) String() string { return joinExprs(e.Args, " AND ") }
+func (e *OrExpr) String() string  { return joinExprs(e.Args, " OR ") }
+func (e *NotExpr) String() string { return "NOT " + wrap(e.Arg) }
+
+func (e *Restriction) String() string {
+	path := strings.Join(e.Path, ".")
+	switch {
+	case e.Op == OpNone || e.Inherited:
+		return e.Arg.String()
+	case path == "":
+		return e.Op.String() + " " + e.Arg.String()
+	case e.Op == OpHas:
+		return path + ":" + e.Arg.String()
+	default:
+		return path + " " + e.Op.String() + " " + e.Arg.String()
+	}
+}
+
+func (a *Literal) String() string {
+	if a.Quoted {
+		return strconv.Quote(a.Value)
+	}
+	return a.Value
+}
+
+func (a *Composite) String() string { return "(" + a.Expr.String() + ")" }
+
+func joinExprs(exprs []Expr, sep string) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = wrap(e)
+	}
+	return strings.Join(parts, sep)
+}
+
+// wrap parenthesizes compound expressions so that String round-trips through
+// Parse with the same precedence.
+func wrap(e Expr) string {
+	switch e.(type) {
+	case *AndExpr, *OrExpr:
+		return "(" + e.String() + ")"
+	default:
+		return e.String()
+	}
+}
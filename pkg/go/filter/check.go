@@ -0,0 +1,141 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Check validates the filter against the messages it will be matched with,
+// without needing an instance of them. It reports unknown field paths,
+// literals that do not fit the type of their field and comparators that are
+// not supported for a field. The returned error is nil or of type Errors, with
+// one entry per offending restriction.
+func (f *Filter) Check(md protoreflect.MessageDescriptor) error {
+	if f.expr == nil {
+		return nil
+	}
+	errs := check(f.expr, value{v: protoreflect.ValueOfMessage(dynamicpb.NewMessage(md))})
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// check mirrors eval, but evaluates each restriction against a single zero
+// value of its field and collects all errors instead of stopping at the first.
+func check(e Expr, subject value) Errors {
+	switch e := e.(type) {
+	case *AndExpr:
+		return checkAll(e.Args, subject)
+	case *OrExpr:
+		return checkAll(e.Args, subject)
+	case *NotExpr:
+		return check(e.Arg, subject)
+	case *Restriction:
+		errs := checkRestriction(e, subject)
+		for _, err := range errs {
+			if err.Clause == "" {
+				err.Clause = e.String()
+			}
+		}
+		return errs
+	default:
+		return Errors{errorf(e.Position(), "unsupported expression %s", e)}
+	}
+}
+
+func checkAll(args []Expr, subject value) Errors {
+	var errs Errors
+	for _, arg := range args {
+		errs = append(errs, check(arg, subject)...)
+	}
+	return errs
+}
+
+func checkRestriction(r *Restriction, subject value) Errors {
+	if r.Op == OpNone {
+		return Errors{errorf(r.Pos, "bare value %s is not supported, compare it to a field instead", r.Arg)}
+	}
+
+	v := subject
+	if len(r.Path) > 0 {
+		md := subject.messageDescriptor()
+		if md == nil {
+			return Errors{errorf(r.Pos, "cannot select field %q of a non-message value", r.Path[0])}
+		}
+		path, err := resolvePath(md, r.Path, r.Pos)
+		if err != nil {
+			return Errors{asError(err)}
+		}
+		v = zeroValue(path.leaf())
+	}
+
+	switch arg := r.Arg.(type) {
+	case *Composite:
+		if r.Op != OpHas && r.Op != OpEquals {
+			return Errors{errorf(r.Pos, "a parenthesized argument requires ':' or '=', got %q", r.Op)}
+		}
+		return check(arg.Expr, v)
+	case *Literal:
+		if r.Op == OpHas && arg.Value == "*" && !arg.Quoted {
+			return nil
+		}
+		op := r.Op
+		if op == OpNotEquals {
+			op = OpEquals
+		}
+		if _, err := compare(op, v, arg); err != nil {
+			return Errors{asError(err)}
+		}
+		return nil
+	default:
+		return Errors{errorf(r.Pos, "unsupported argument %s", r.Arg)}
+	}
+}
+
+// zeroValue returns the zero value of a single element of the field.
+func zeroValue(fd protoreflect.FieldDescriptor) value {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return value{v: protoreflect.ValueOfMessage(dynamicpb.NewMessage(fd.Message())), fd: fd}
+	case protoreflect.EnumKind:
+		return value{v: protoreflect.ValueOfEnum(0), fd: fd}
+	case protoreflect.BoolKind:
+		return value{v: protoreflect.ValueOfBool(false), fd: fd}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return value{v: protoreflect.ValueOfInt64(0), fd: fd}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return value{v: protoreflect.ValueOfUint64(0), fd: fd}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return value{v: protoreflect.ValueOfFloat64(0), fd: fd}
+	case protoreflect.BytesKind:
+		return value{v: protoreflect.ValueOfBytes(nil), fd: fd}
+	default:
+		return value{v: protoreflect.ValueOfString(""), fd: fd}
+	}
+}
+
+func asError(err error) *Error {
+	var ferr *Error
+	if errors.As(err, &ferr) {
+		return ferr
+	}
+	return &Error{Msg: err.Error()}
+}
@@ -0,0 +1,302 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	timestampName protoreflect.FullName = "google.protobuf.Timestamp"
+	durationName  protoreflect.FullName = "google.protobuf.Duration"
+)
+
+// eval evaluates the expression against subject, which is the message being
+// matched or, inside a composite argument, one of the values of the enclosing
+// restriction.
+func eval(e Expr, subject value) (bool, error) {
+	switch e := e.(type) {
+	case *AndExpr:
+		for _, arg := range e.Args {
+			if ok, err := eval(arg, subject); err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case *OrExpr:
+		for _, arg := range e.Args {
+			if ok, err := eval(arg, subject); err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case *NotExpr:
+		ok, err := eval(e.Arg, subject)
+		return !ok, err
+	case *Restriction:
+		return evalRestriction(e, subject)
+	default:
+		return false, errorf(e.Position(), "unsupported expression %s", e)
+	}
+}
+
+func evalRestriction(r *Restriction, subject value) (bool, error) {
+	if r.Op == OpNone {
+		return false, errorf(r.Pos, "bare value %s is not supported, compare it to a field instead", r.Arg)
+	}
+
+	values := []value{subject}
+	if len(r.Path) > 0 {
+		md := subject.messageDescriptor()
+		if md == nil {
+			return false, errorf(r.Pos, "cannot select field %q of a non-message value", r.Path[0])
+		}
+		path, err := resolvePath(md, r.Path, r.Pos)
+		if err != nil {
+			return false, err
+		}
+		values = collect(subject.v.Message(), path)
+	}
+
+	switch arg := r.Arg.(type) {
+	case *Composite:
+		if r.Op != OpHas && r.Op != OpEquals {
+			return false, errorf(r.Pos, "a parenthesized argument requires ':' or '=', got %q", r.Op)
+		}
+		for _, v := range values {
+			if ok, err := eval(arg.Expr, v); err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case *Literal:
+		if r.Op == OpHas && arg.Value == "*" && !arg.Quoted {
+			for _, v := range values {
+				if present(v) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+
+		op := r.Op
+		if op == OpNotEquals {
+			op = OpEquals
+		}
+		matched := false
+		for _, v := range values {
+			ok, err := compare(op, v, arg)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		return matched != (r.Op == OpNotEquals), nil
+	default:
+		return false, errorf(r.Pos, "unsupported argument %s", r.Arg)
+	}
+}
+
+// present reports whether a value is set, i.e. it is a message or list
+// element, or a scalar with a non-default value.
+func present(v value) bool {
+	switch {
+	case v.messageDescriptor() != nil:
+		return v.v.Message().IsValid()
+	case v.fd.IsList():
+		return true
+	default:
+		return !v.v.Equal(v.fd.Default())
+	}
+}
+
+// compare evaluates `v op lit` for a scalar value, a Timestamp or a Duration.
+func compare(op Operator, v value, lit *Literal) (bool, error) {
+	if md := v.messageDescriptor(); md != nil {
+		switch md.FullName() {
+		case timestampName:
+			return compareTimestamp(op, v, lit)
+		case durationName:
+			return compareDuration(op, v, lit)
+		default:
+			return false, errorf(lit.Pos, "%s is a message and only supports the presence test ':*'", fieldName(v))
+		}
+	}
+
+	var c int
+	switch v.fd.Kind() {
+	case protoreflect.BoolKind:
+		if op != OpEquals && op != OpHas {
+			return false, errorf(lit.Pos, "%s is a bool and does not support %q", fieldName(v), op)
+		}
+		b, err := strconv.ParseBool(lit.Value)
+		if err != nil {
+			return false, errorf(lit.Pos, "%s is a bool, got %s", fieldName(v), lit)
+		}
+		return v.v.Bool() == b, nil
+	case protoreflect.EnumKind:
+		n, err := enumNumber(v.fd.Enum(), lit)
+		if err != nil {
+			return false, err
+		}
+		c = cmp.Compare(v.v.Enum(), n)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var err error
+		if c, err = compareInt(v.v.Int(), lit); err != nil {
+			return false, errorf(lit.Pos, "%s is an integer, got %s", fieldName(v), lit)
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var err error
+		if c, err = compareUint(v.v.Uint(), lit); err != nil {
+			return false, errorf(lit.Pos, "%s is an integer, got %s", fieldName(v), lit)
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(lit.Value, 64)
+		if err != nil {
+			return false, errorf(lit.Pos, "%s is a number, got %s", fieldName(v), lit)
+		}
+		c = cmp.Compare(v.v.Float(), f)
+	case protoreflect.StringKind:
+		if (op == OpEquals || op == OpHas) && strings.Contains(lit.Value, "*") {
+			return matchWildcard(v.v.String(), lit.Value), nil
+		}
+		c = strings.Compare(v.v.String(), lit.Value)
+	case protoreflect.BytesKind:
+		c = strings.Compare(string(v.v.Bytes()), lit.Value)
+	default:
+		return false, errorf(lit.Pos, "%s has unsupported kind %s", fieldName(v), v.fd.Kind())
+	}
+	return ordered(op, c), nil
+}
+
+func ordered(op Operator, c int) bool {
+	switch op {
+	case OpEquals, OpHas:
+		return c == 0
+	case OpLess:
+		return c < 0
+	case OpLessEquals:
+		return c <= 0
+	case OpGreater:
+		return c > 0
+	case OpGreaterEquals:
+		return c >= 0
+	default:
+		return false
+	}
+}
+
+// compareTimestamp compares a google.protobuf.Timestamp with an RFC 3339
+// literal, e.g. "2026-10-20T00:00:00Z".
+func compareTimestamp(op Operator, v value, lit *Literal) (bool, error) {
+	t, err := time.Parse(time.RFC3339Nano, lit.Value)
+	if err != nil {
+		return false, errorf(lit.Pos, "%s is a timestamp, got %s, want an RFC 3339 string", fieldName(v), lit)
+	}
+	got := time.Unix(secondsAndNanos(v.v.Message()))
+	return ordered(op, got.Compare(t)), nil
+}
+
+// compareDuration compares a google.protobuf.Duration with a duration literal
+// such as 30s or 1.5h.
+func compareDuration(op Operator, v value, lit *Literal) (bool, error) {
+	d, err := time.ParseDuration(lit.Value)
+	if err != nil {
+		return false, errorf(lit.Pos, "%s is a duration, got %s, want e.g. 30s", fieldName(v), lit)
+	}
+	seconds, nanos := secondsAndNanos(v.v.Message())
+	got := time.Duration(seconds)*time.Second + time.Duration(nanos)
+	return ordered(op, cmp.Compare(got, d)), nil
+}
+
+// secondsAndNanos reads the fields shared by Timestamp and Duration.
+func secondsAndNanos(m protoreflect.Message) (int64, int64) {
+	fields := m.Descriptor().Fields()
+	return m.Get(fields.ByName("seconds")).Int(), m.Get(fields.ByName("nanos")).Int()
+}
+
+// matchWildcard matches s against a pattern in which '*' stands for any run
+// of characters other than '/', so that "transceivers/*" matches every
+// transceiver name but not the names of its sub-resources.
+func matchWildcard(s, pattern string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 {
+			if !strings.HasSuffix(s, part) || strings.Contains(s[:len(s)-len(part)], "/") {
+				return false
+			}
+			return true
+		}
+		j := strings.Index(s, part)
+		if j < 0 || strings.Contains(s[:j], "/") {
+			return false
+		}
+		s = s[j+len(part):]
+	}
+	return true
+}
+
+func compareInt(x int64, lit *Literal) (int, error) {
+	if n, err := strconv.ParseInt(lit.Value, 10, 64); err == nil {
+		return cmp.Compare(x, n), nil
+	}
+	f, err := strconv.ParseFloat(lit.Value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return cmp.Compare(float64(x), f), nil
+}
+
+func compareUint(x uint64, lit *Literal) (int, error) {
+	if n, err := strconv.ParseUint(lit.Value, 10, 64); err == nil {
+		return cmp.Compare(x, n), nil
+	}
+	f, err := strconv.ParseFloat(lit.Value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return cmp.Compare(float64(x), f), nil
+}
+
+// enumNumber resolves an enum literal by value name or number.
+func enumNumber(ed protoreflect.EnumDescriptor, lit *Literal) (protoreflect.EnumNumber, error) {
+	if ev := ed.Values().ByName(protoreflect.Name(lit.Value)); ev != nil {
+		return ev.Number(), nil
+	}
+	if n, err := strconv.ParseInt(lit.Value, 10, 32); err == nil {
+		return protoreflect.EnumNumber(n), nil
+	}
+	return 0, errorf(lit.Pos, "%s is not a value of enum %s", lit, ed.FullName())
+}
+
+func fieldName(v value) string {
+	if v.fd == nil {
+		return "value"
+	}
+	return string(v.fd.Name())
+}
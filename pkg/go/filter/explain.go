@@ -0,0 +1,130 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Failure is a clause of a filter that a message does not satisfy.
+type Failure struct {
+	// Clause is the unsatisfied restriction or negation, e.g.
+	// `transmit_signal_chain.antenna.type = OPTICAL`.
+	Clause string
+	Pos    int
+	// Path is the field path of the restriction, empty for clauses which are
+	// not a restriction.
+	Path string
+	// Actual holds the values found at Path, formatted as filter literals. It
+	// is empty if the field is unset.
+	Actual []string
+}
+
+// Explain returns the clauses that prevent the message from matching the
+// filter, or nil if it matches. For a conjunction it reports every
+// unsatisfied argument, and for a disjunction the failures of all its
+// arguments. Composite arguments are reported as a whole, with the values
+// that were tried.
+func (f *Filter) Explain(m proto.Message) ([]Failure, error) {
+	if f.expr == nil {
+		return nil, nil
+	}
+	return explain(f.expr, value{v: protoreflect.ValueOfMessage(m.ProtoReflect())})
+}
+
+func explain(e Expr, subject value) ([]Failure, error) {
+	ok, err := eval(e, subject)
+	if err != nil || ok {
+		return nil, err
+	}
+
+	switch e := e.(type) {
+	case *AndExpr:
+		return explainAll(e.Args, subject)
+	case *OrExpr:
+		return explainAll(e.Args, subject)
+	case *Restriction:
+		return []Failure{restrictionFailure(e, e.String(), subject)}, nil
+	case *NotExpr:
+		if r, ok := e.Arg.(*Restriction); ok {
+			return []Failure{restrictionFailure(r, e.String(), subject)}, nil
+		}
+		return []Failure{{Clause: e.String(), Pos: e.Pos}}, nil
+	default:
+		return []Failure{{Clause: e.String(), Pos: e.Position()}}, nil
+	}
+}
+
+func explainAll(args []Expr, subject value) ([]Failure, error) {
+	var failures []Failure
+	for _, arg := range args {
+		f, err := explain(arg, subject)
+		if err != nil {
+			return nil, err
+		}
+		failures = append(failures, f...)
+	}
+	return failures, nil
+}
+
+func restrictionFailure(r *Restriction, clause string, subject value) Failure {
+	failure := Failure{Clause: clause, Pos: r.Pos, Path: strings.Join(r.Path, ".")}
+	values := []value{subject}
+	if len(r.Path) > 0 {
+		// The path was resolved successfully by eval.
+		path, _ := resolvePath(subject.messageDescriptor(), r.Path, r.Pos)
+		values = collect(subject.v.Message(), path)
+	}
+	for _, v := range values {
+		failure.Actual = append(failure.Actual, formatValue(v))
+	}
+	return failure
+}
+
+// formatValue formats a value the way it would be written in a filter.
+func formatValue(v value) string {
+	if md := v.messageDescriptor(); md != nil {
+		m := v.v.Message()
+		switch md.FullName() {
+		case timestampName:
+			return strconv.Quote(time.Unix(secondsAndNanos(m)).UTC().Format(time.RFC3339Nano))
+		case durationName:
+			seconds, nanos := secondsAndNanos(m)
+			return (time.Duration(seconds)*time.Second + time.Duration(nanos)).String()
+		default:
+			return "{" + prototext.MarshalOptions{}.Format(m.Interface()) + "}"
+		}
+	}
+
+	switch v.fd.Kind() {
+	case protoreflect.EnumKind:
+		if ev := v.fd.Enum().Values().ByNumber(v.v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.v.Enum()))
+	case protoreflect.StringKind:
+		return strconv.Quote(v.v.String())
+	case protoreflect.BytesKind:
+		return strconv.Quote(string(v.v.Bytes()))
+	default:
+		return v.v.String()
+	}
+}
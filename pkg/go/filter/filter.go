@@ -0,0 +1,111 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filter implements the filter language of the Interconnect API.
+//
+// The syntax is derived from https://google.aip.dev/160 and extended to match
+// ranges in repeated fields. A composite argument is evaluated against each
+// value of its restriction and is satisfied if one value satisfies the whole
+// composite. For example, the following filter matches transceivers with a
+// transmit signal between 8 and 12 GHz, but not transceivers which only have
+// one signal below 8 GHz and another one above 12 GHz:
+//
+//	transmit_signal_chain.transmitter.signals.signal.center_frequency_hz:(>= 8000000000 AND <= 12000000000)
+//
+// Field paths may traverse repeated and nested fields. A restriction is
+// satisfied if any value found at its path satisfies it, except for `!=`,
+// which is satisfied if no value equals the argument. Enum fields are compared
+// by value name, google.protobuf.Timestamp fields with RFC 3339 strings such as
+// "2026-10-20T00:00:00Z", and google.protobuf.Duration fields with durations
+// such as 30s. In string equality, '*' matches any run of characters within a
+// segment of a resource name, e.g. `transceiver = "transceivers/*"`.
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Filter is a parsed filter expression.
+type Filter struct {
+	src  string
+	expr Expr
+}
+
+// Parse parses a filter expression. The empty filter matches every message.
+func Parse(src string) (*Filter, error) {
+	expr, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{src: src, expr: expr}, nil
+}
+
+// MustParse is like Parse but panics if the filter cannot be parsed.
+func MustParse(src string) *Filter {
+	f, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Expr returns the root of the filter's syntax tree, or nil for the empty
+// filter.
+func (f *Filter) Expr() Expr { return f.expr }
+
+// String returns the filter as it was given to Parse.
+func (f *Filter) String() string { return f.src }
+
+// Matches reports whether the message satisfies the filter.
+func (f *Filter) Matches(m proto.Message) (bool, error) {
+	if f.expr == nil {
+		return true, nil
+	}
+	return eval(f.expr, value{v: protoreflect.ValueOfMessage(m.ProtoReflect())})
+}
+
+// Error is a syntax or evaluation error located at a byte offset of the
+// filter string.
+type Error struct {
+	Pos int
+	Msg string
+	// Clause is the restriction that caused the error, if any.
+	Clause string
+}
+
+func (e *Error) Error() string {
+	if e.Clause != "" {
+		return fmt.Sprintf("invalid filter at position %d in `%s`: %s", e.Pos, e.Clause, e.Msg)
+	}
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos, e.Msg)
+}
+
+// Errors is the list of errors found by Check.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,465 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/genproto/googleapis/type/interval"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"outernetcouncil.org/nmts/v1/proto/ek/physical"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{filter: "", want: ""},
+		{filter: "a = b", want: "a = b"},
+		{filter: "a=b AND c!=d", want: "a = b AND c != d"},
+		{filter: "a = b c < 3", want: "a = b AND c < 3"},
+		{filter: "a = b OR c = d AND e = f", want: "(a = b OR c = d) AND e = f"},
+		{filter: "NOT a = b", want: "NOT a = b"},
+		{filter: "-a.b:x", want: "NOT a.b:x"},
+		{filter: `a = "quoted \"value\""`, want: `a = "quoted \"value\""`},
+		{filter: "a = -3.5", want: "a = -3.5"},
+		{filter: "a.b:(>= 8000000000 AND <= 12000000000)", want: "a.b:(>= 8000000000 AND <= 12000000000)"},
+		{filter: "a:(x OR y)", want: "a:(x OR y)"},
+		{filter: "a:(b.c = 1 AND d > 2)", want: "a:(b.c = 1 AND d > 2)"},
+		{filter: "a:*", want: "a:*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := Parse(tt.filter)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.filter, err)
+			}
+			got := ""
+			if f.Expr() != nil {
+				got = f.Expr().String()
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %q, want %q", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		filter  string
+		wantPos int
+	}{
+		{filter: "a =", wantPos: 3},
+		{filter: "(a = b", wantPos: 0},
+		{filter: "a = b)", wantPos: 5},
+		{filter: "a = 'unterminated", wantPos: 4},
+		{filter: ">= 3", wantPos: 0},
+		{filter: `"a" = b`, wantPos: 0},
+		{filter: "a..b = c", wantPos: 0},
+		{filter: "regex(a, b)", wantPos: 0},
+		{filter: "a ! b", wantPos: 2},
+		{filter: "a AND", wantPos: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			_, err := Parse(tt.filter)
+			if err == nil {
+				t.Fatalf("Parse(%q) should have failed", tt.filter)
+			}
+			ferr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Parse(%q) returned %T, want *Error", tt.filter, err)
+			}
+			if ferr.Pos != tt.wantPos {
+				t.Errorf("Parse(%q) error at position %d, want %d: %v", tt.filter, ferr.Pos, tt.wantPos, err)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	opticalTransceiver := &pb.Transceiver{
+		Name: "transceivers/optical",
+		TransmitSignalChain: &pb.TransmitSignalChain{
+			Antenna: &physical.Antenna{Type: physical.Antenna_OPTICAL},
+		},
+		ReceiveSignalChain: &pb.ReceiveSignalChain{
+			Antenna: &physical.Antenna{Type: physical.Antenna_OPTICAL},
+		},
+	}
+	bearers := &pb.ListBearersResponse{
+		Bearers: []*pb.Bearer{
+			{Name: "bearers/low", RxCenterFrequencyHz: 7000000000, Mac: pb.Mac_MAC_DVB_S2},
+			{Name: "bearers/high", RxCenterFrequencyHz: 13000000000, Mac: pb.Mac_MAC_ETH},
+		},
+	}
+	window := &pb.ContactWindow{
+		Name:        "contactWindows/gs1mysat",
+		Transceiver: "transceivers/gs1",
+		Interval: &interval.Interval{
+			StartTime: &timestamppb.Timestamp{Seconds: 1792454400}, // 2026-10-20T00:00:00Z
+			EndTime:   &timestamppb.Timestamp{Seconds: 1792458000, Nanos: 500000000},
+		},
+		MinRxCenterFrequencyHz: 12000000000,
+	}
+	circuit := &pb.AttachmentCircuit{
+		Name: "attachmentCircuits/ac",
+		RoutingProtocols: []*pb.AttachmentCircuit_RoutingProtocol{
+			{Type: &pb.AttachmentCircuit_RoutingProtocol_Direct{Direct: &emptypb.Empty{}}},
+			{Type: &pb.AttachmentCircuit_RoutingProtocol_StaticType{
+				StaticType: &pb.AttachmentCircuit_RoutingProtocol_Static{
+					Prefixes: []*pb.AttachmentCircuit_RoutingProtocol_Static_Prefix{
+						{Prefix: "10.0.0.0/8", NextHop: "192.168.0.1"},
+						{Prefix: "172.16.0.0/12", NextHop: "192.168.0.2"},
+					},
+				},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		msg    proto.Message
+		want   bool
+	}{
+		{
+			name:   "empty filter matches everything",
+			filter: "",
+			msg:    opticalTransceiver,
+			want:   true,
+		},
+		{
+			name:   "enum comparison by value name",
+			filter: "transmit_signal_chain.antenna.type = OPTICAL AND receive_signal_chain.antenna.type = OPTICAL",
+			msg:    opticalTransceiver,
+			want:   true,
+		},
+		{
+			name:   "enum comparison with a different value",
+			filter: "transmit_signal_chain.antenna.type = RF",
+			msg:    opticalTransceiver,
+			want:   false,
+		},
+		{
+			name:   "unset parent message yields no values",
+			filter: "transmit_signal_chain.antenna.type = OPTICAL",
+			msg:    &pb.Transceiver{},
+			want:   false,
+		},
+		{
+			name:   "inequality is satisfied if no value is equal",
+			filter: "transmit_signal_chain.antenna.type != RF",
+			msg:    opticalTransceiver,
+			want:   true,
+		},
+		{
+			name:   "JSON field names",
+			filter: "transmitSignalChain.antenna.type = OPTICAL",
+			msg:    opticalTransceiver,
+			want:   true,
+		},
+		{
+			name:   "string equality",
+			filter: `name = "transceivers/optical"`,
+			msg:    opticalTransceiver,
+			want:   true,
+		},
+		{
+			name:   "presence of a message field",
+			filter: "receive_signal_chain:*",
+			msg:    opticalTransceiver,
+			want:   true,
+		},
+		{
+			name:   "absence of a message field",
+			filter: "NOT platform:*",
+			msg:    opticalTransceiver,
+			want:   true,
+		},
+		{
+			name:   "repeated field matches if any value matches",
+			filter: "bearers.rx_center_frequency_hz > 12000000000",
+			msg:    bearers,
+			want:   true,
+		},
+		{
+			name:   "range over repeated field requires a single value in range",
+			filter: "bearers.rx_center_frequency_hz:(>= 8000000000 AND <= 12000000000)",
+			msg:    bearers,
+			want:   false,
+		},
+		{
+			name:   "separate restrictions may match different values",
+			filter: "bearers.rx_center_frequency_hz >= 8000000000 AND bearers.rx_center_frequency_hz <= 12000000000",
+			msg:    bearers,
+			want:   true,
+		},
+		{
+			name:   "range over repeated field with a value in range",
+			filter: "bearers.rx_center_frequency_hz:(>= 12e9 AND <= 14e9)",
+			msg:    bearers,
+			want:   true,
+		},
+		{
+			name:   "composite over repeated messages applies to a single element",
+			filter: "bearers:(rx_center_frequency_hz > 12000000000 AND mac = MAC_DVB_S2)",
+			msg:    bearers,
+			want:   false,
+		},
+		{
+			name:   "composite over repeated messages with a matching element",
+			filter: "bearers:(rx_center_frequency_hz > 12000000000 AND mac = MAC_ETH)",
+			msg:    bearers,
+			want:   true,
+		},
+		{
+			name:   "bare values in a composite inherit the comparator",
+			filter: "bearers.mac:(MAC_UNSPECIFIED OR MAC_ETH)",
+			msg:    bearers,
+			want:   true,
+		},
+		{
+			name:   "nested repeated fields",
+			filter: `routing_protocols.static_type.prefixes.next_hop = "192.168.0.2"`,
+			msg:    circuit,
+			want:   true,
+		},
+		{
+			name:   "unset oneof member yields no values",
+			filter: "routing_protocols.direct:* AND routing_protocols.static_type:*",
+			msg:    circuit,
+			want:   true,
+		},
+		{
+			name:   "timestamp comparison",
+			filter: `transceiver = "transceivers/gs1" AND interval.start_time >= "2026-10-20T00:00:00Z"`,
+			msg:    window,
+			want:   true,
+		},
+		{
+			name:   "timestamp comparison with fractional seconds and offset",
+			filter: `interval.end_time > "2026-10-20T02:00:00.4+01:00"`,
+			msg:    window,
+			want:   true,
+		},
+		{
+			name:   "timestamp before the value",
+			filter: `interval.start_time < "2026-10-20T00:00:00Z"`,
+			msg:    window,
+			want:   false,
+		},
+		{
+			name:   "resource name wildcard",
+			filter: `transceiver = "transceivers/*"`,
+			msg:    window,
+			want:   true,
+		},
+		{
+			name:   "resource name wildcard does not cross segments",
+			filter: `name = "contactWindows/*/gs1"`,
+			msg:    window,
+			want:   false,
+		},
+		{
+			name:   "resource name wildcard within an ID",
+			filter: "transceiver = transceivers/gs*",
+			msg:    window,
+			want:   true,
+		},
+		{
+			name:   "numeric comparison in scientific notation",
+			filter: "min_rx_center_frequency_hz >= 12e9",
+			msg:    window,
+			want:   true,
+		},
+		{
+			name:   "nested repeated fields without a match",
+			filter: `routing_protocols.static_type.prefixes:(prefix = "10.0.0.0/8" AND next_hop = "192.168.0.2")`,
+			msg:    circuit,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MustParse(tt.filter).Matches(tt.msg)
+			if err != nil {
+				t.Fatalf("Matches failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("%q matched %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches_Duration(t *testing.T) {
+	info := &errdetails.RetryInfo{RetryDelay: durationpb.New(90 * time.Second)}
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{filter: "retry_delay = 90s", want: true},
+		{filter: "retry_delay > 1m", want: true},
+		{filter: "retry_delay:(>= 1m AND < 1.5m)", want: false},
+		{filter: "retry_delay < 1m30.5s", want: true},
+	}
+	for _, tt := range tests {
+		got, err := MustParse(tt.filter).Matches(info)
+		if err != nil {
+			t.Fatalf("Matches(%q) failed: %v", tt.filter, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q matched %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestMatches_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+	}{
+		{name: "malformed timestamp", filter: `interval.start_time > "yesterday"`},
+		{name: "unknown field", filter: "no_such_field = 1"},
+		{name: "unknown enum value", filter: "mac = MAC_UNKNOWN"},
+		{name: "non-numeric value for integer field", filter: "rx_bandwidth_hz > wide"},
+		{name: "comparison of a message field", filter: "interval = 3"},
+		{name: "field of a scalar", filter: "rx_bandwidth_hz.value = 3"},
+		{name: "bare value", filter: "bearer"},
+		{name: "composite with ordering comparator", filter: "rx_bandwidth_hz < (3 OR 4)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bearer := &pb.Bearer{Interval: &interval.Interval{StartTime: &timestamppb.Timestamp{}}, RxBandwidthHz: 3}
+			if _, err := MustParse(tt.filter).Matches(bearer); err == nil {
+				t.Errorf("Matches(%q) should have failed", tt.filter)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	md := (&pb.Transceiver{}).ProtoReflect().Descriptor()
+	tests := []struct {
+		filter      string
+		wantClauses []string
+	}{
+		{filter: ""},
+		{filter: "transmit_signal_chain.antenna.type = OPTICAL AND receive_signal_chain.antenna.type = OPTICAL"},
+		{filter: "transmit_signal_chain.transmitter.signals.signal.center_frequency_hz:(>= 8000000000 AND <= 12000000000)"},
+		{filter: `name = "transceivers/*" AND platform:*`},
+		{
+			filter:      "transmit_signal_chain.antenna.kind = OPTICAL",
+			wantClauses: []string{"transmit_signal_chain.antenna.kind = OPTICAL"},
+		},
+		{
+			filter:      "transmit_signal_chain.antenna.type = LASER OR receive_signal_chain.antenna.type > 1",
+			wantClauses: []string{"transmit_signal_chain.antenna.type = LASER"},
+		},
+		{
+			filter:      "transmit_signal_chain.transmitter.signals.signal:(center_frequency_hz >= high AND bandwidth_hz = wide)",
+			wantClauses: []string{"center_frequency_hz >= high", "bandwidth_hz = wide"},
+		},
+		{
+			filter:      "transmit_signal_chain.antenna = OPTICAL",
+			wantClauses: []string{"transmit_signal_chain.antenna = OPTICAL"},
+		},
+		{
+			filter:      "name < (a OR b)",
+			wantClauses: []string{"name < (a OR b)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			err := MustParse(tt.filter).Check(md)
+			if len(tt.wantClauses) == 0 {
+				if err != nil {
+					t.Fatalf("Check(%q) failed: %v", tt.filter, err)
+				}
+				return
+			}
+			errs, ok := err.(Errors)
+			if !ok {
+				t.Fatalf("Check(%q) returned %T, want Errors", tt.filter, err)
+			}
+			var clauses []string
+			for _, e := range errs {
+				clauses = append(clauses, e.Clause)
+			}
+			if diff := cmp.Diff(tt.wantClauses, clauses); diff != "" {
+				t.Errorf("Check(%q) clauses mismatch (-want +got):\n%s", tt.filter, diff)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	window := &pb.ContactWindow{
+		Transceiver: "transceivers/gs1",
+		Interval: &interval.Interval{
+			StartTime: &timestamppb.Timestamp{Seconds: 1792454400},
+		},
+		MinRxCenterFrequencyHz: 12000000000,
+	}
+
+	tests := []struct {
+		filter string
+		want   []Failure
+	}{
+		{filter: `transceiver = "transceivers/gs1"`},
+		{
+			filter: `transceiver = "transceivers/gs2" OR min_rx_center_frequency_hz < 1e9`,
+			want: []Failure{
+				{Clause: `transceiver = "transceivers/gs2"`, Pos: 0, Path: "transceiver", Actual: []string{`"transceivers/gs1"`}},
+				{Clause: "min_rx_center_frequency_hz < 1e9", Pos: 36, Path: "min_rx_center_frequency_hz", Actual: []string{"12000000000"}},
+			},
+		},
+		{
+			filter: `NOT interval.start_time = "2026-10-20T00:00:00Z" AND interval.end_time:*`,
+			want: []Failure{
+				{Clause: `NOT interval.start_time = "2026-10-20T00:00:00Z"`, Pos: 4, Path: "interval.start_time", Actual: []string{`"2026-10-20T00:00:00Z"`}},
+				{Clause: "interval.end_time:*", Pos: 53, Path: "interval.end_time"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			got, err := MustParse(tt.filter).Explain(window)
+			if err != nil {
+				t.Fatalf("Explain failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Explain(%q) mismatch (-want +got):\n%s", tt.filter, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokText
+	tokString
+	tokLParen
+	tokRParen
+	tokComma
+	tokMinus
+	tokAnd
+	tokOr
+	tokNot
+	tokComparator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	op   Operator
+	// spaceBefore records whether whitespace preceded the token, which
+	// distinguishes a function call `f(x)` from a sequence `f (x)`.
+	spaceBefore bool
+}
+
+// lex splits a filter string into tokens.
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	space := false
+	for i < len(src) {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			space = true
+			continue
+		}
+
+		tok := token{pos: i, spaceBefore: space}
+		space = false
+		switch {
+		case r == '(':
+			tok.kind, tok.text = tokLParen, "("
+			i++
+		case r == ')':
+			tok.kind, tok.text = tokRParen, ")"
+			i++
+		case r == ',':
+			tok.kind, tok.text = tokComma, ","
+			i++
+		case r == ':':
+			tok.kind, tok.text, tok.op = tokComparator, ":", OpHas
+			i++
+		case r == '=':
+			tok.kind, tok.text, tok.op = tokComparator, "=", OpEquals
+			i++
+		case r == '!':
+			if !strings.HasPrefix(src[i:], "!=") {
+				return nil, errorf(i, "unexpected character %q", r)
+			}
+			tok.kind, tok.text, tok.op = tokComparator, "!=", OpNotEquals
+			i += 2
+		case r == '<' || r == '>':
+			tok.kind = tokComparator
+			orEquals := strings.HasPrefix(src[i+1:], "=")
+			switch {
+			case r == '<' && orEquals:
+				tok.text, tok.op = "<=", OpLessEquals
+			case r == '<':
+				tok.text, tok.op = "<", OpLess
+			case orEquals:
+				tok.text, tok.op = ">=", OpGreaterEquals
+			default:
+				tok.text, tok.op = ">", OpGreater
+			}
+			i += len(tok.text)
+		case r == '"' || r == '\'':
+			s, n, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tok.kind, tok.text = tokString, s
+			i += n
+		case r == '-' && (i+1 >= len(src) || !isDigit(src[i+1])):
+			tok.kind, tok.text = tokMinus, "-"
+			i++
+		default:
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if unicode.IsSpace(r) || strings.ContainsRune(`()",':=!<>`, r) {
+					break
+				}
+				i += size
+			}
+			tok.kind, tok.text = tokText, src[start:i]
+			switch tok.text {
+			case "AND":
+				tok.kind = tokAnd
+			case "OR":
+				tok.kind = tokOr
+			case "NOT":
+				tok.kind = tokNot
+			}
+		}
+		toks = append(toks, tok)
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src), spaceBefore: space}), nil
+}
+
+// lexString reads a quoted string starting at src[start] and returns its
+// unescaped value and the number of bytes consumed.
+func lexString(src string, start int) (string, int, error) {
+	quote := src[start]
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		switch c := src[i]; c {
+		case quote:
+			return b.String(), i - start + 1, nil
+		case '\\':
+			i++
+			if i >= len(src) {
+				return "", 0, errorf(start, "unterminated string")
+			}
+			switch e := src[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errorf(start, "unterminated string")
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
@@ -0,0 +1,265 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import "strings"
+
+// parser is a recursive descent parser for the grammar of AIP-160:
+//
+//	expression  : sequence {AND sequence}
+//	sequence    : factor {factor}
+//	factor      : term {OR term}
+//	term        : [NOT | -] simple
+//	simple      : restriction | '(' expression ')'
+//	restriction : comparable [comparator arg]
+//	arg         : value | '(' expression ')'
+//
+// Inside a composite argument a restriction may omit its comparable, in which
+// case it applies to each value of the enclosing restriction.
+type parser struct {
+	toks []token
+	pos  int
+	// argOps holds the comparators of the enclosing composite arguments.
+	argOps []Operator
+}
+
+func parse(src string) (Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	if p.peek().kind == tokEOF {
+		return nil, nil
+	}
+	e, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, errorf(tok.pos, "unexpected %q", tok.text)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	tok := p.toks[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) inArg() bool { return len(p.argOps) > 0 }
+
+func (p *parser) expression() (Expr, error) {
+	first, err := p.sequence()
+	if err != nil {
+		return nil, err
+	}
+	args := []Expr{first}
+	for p.peek().kind == tokAnd {
+		p.next()
+		s, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, s)
+	}
+	if len(args) == 1 {
+		return first, nil
+	}
+	return &AndExpr{Pos: first.Position(), Args: args}, nil
+}
+
+func (p *parser) sequence() (Expr, error) {
+	first, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	args := []Expr{first}
+	for p.startsTerm() {
+		f, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, f)
+	}
+	if len(args) == 1 {
+		return first, nil
+	}
+	return &AndExpr{Pos: first.Position(), Args: args}, nil
+}
+
+func (p *parser) startsTerm() bool {
+	switch p.peek().kind {
+	case tokText, tokString, tokLParen, tokNot, tokMinus:
+		return true
+	case tokComparator:
+		return p.inArg()
+	default:
+		return false
+	}
+}
+
+func (p *parser) factor() (Expr, error) {
+	first, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	args := []Expr{first}
+	for p.peek().kind == tokOr {
+		p.next()
+		t, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, t)
+	}
+	if len(args) == 1 {
+		return first, nil
+	}
+	return &OrExpr{Pos: first.Position(), Args: args}, nil
+}
+
+func (p *parser) term() (Expr, error) {
+	if tok := p.peek(); tok.kind == tokNot || tok.kind == tokMinus {
+		p.next()
+		e, err := p.simple()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Pos: tok.pos, Arg: e}, nil
+	}
+	return p.simple()
+}
+
+func (p *parser) simple() (Expr, error) {
+	if tok := p.peek(); tok.kind == tokLParen {
+		p.next()
+		e, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, tok); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return p.restriction()
+}
+
+func (p *parser) restriction() (Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokComparator:
+		if !p.inArg() {
+			return nil, errorf(tok.pos, "missing field before %q", tok.text)
+		}
+		arg, err := p.arg(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Restriction{Pos: tok.pos, Op: tok.op, Arg: arg}, nil
+	case tokText:
+		if err := p.rejectCall(tok); err != nil {
+			return nil, err
+		}
+		if op := p.peek(); op.kind == tokComparator {
+			p.next()
+			path, err := splitPath(tok)
+			if err != nil {
+				return nil, err
+			}
+			arg, err := p.arg(op)
+			if err != nil {
+				return nil, err
+			}
+			return &Restriction{Pos: tok.pos, Path: path, Op: op.op, Arg: arg}, nil
+		}
+		return p.bare(&Literal{Pos: tok.pos, Value: tok.text}), nil
+	case tokString:
+		if op := p.peek(); op.kind == tokComparator {
+			return nil, errorf(tok.pos, "expected a field name before %q, got a string", op.text)
+		}
+		return p.bare(&Literal{Pos: tok.pos, Value: tok.text, Quoted: true}), nil
+	case tokEOF:
+		return nil, errorf(tok.pos, "unexpected end of filter")
+	default:
+		return nil, errorf(tok.pos, "unexpected %q", tok.text)
+	}
+}
+
+// bare wraps a comparable that has no comparator. Inside a composite argument
+// it inherits the comparator of the enclosing restriction, so that
+// `a:(x OR y)` is equivalent to `a:x OR a:y`.
+func (p *parser) bare(lit *Literal) Expr {
+	if p.inArg() {
+		return &Restriction{Pos: lit.Pos, Op: p.argOps[len(p.argOps)-1], Arg: lit, Inherited: true}
+	}
+	return &Restriction{Pos: lit.Pos, Op: OpNone, Arg: lit}
+}
+
+func (p *parser) arg(op token) (Arg, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		p.argOps = append(p.argOps, op.op)
+		e, err := p.expression()
+		p.argOps = p.argOps[:len(p.argOps)-1]
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, tok); err != nil {
+			return nil, err
+		}
+		return &Composite{Pos: tok.pos, Expr: e}, nil
+	case tokText:
+		if err := p.rejectCall(tok); err != nil {
+			return nil, err
+		}
+		return &Literal{Pos: tok.pos, Value: tok.text}, nil
+	case tokString:
+		return &Literal{Pos: tok.pos, Value: tok.text, Quoted: true}, nil
+	default:
+		return nil, errorf(tok.pos, "expected a value after %q", op.text)
+	}
+}
+
+func (p *parser) expect(kind tokenKind, open token) error {
+	if tok := p.next(); tok.kind != kind {
+		return errorf(open.pos, "unbalanced %q", open.text)
+	}
+	return nil
+}
+
+func (p *parser) rejectCall(tok token) error {
+	if next := p.peek(); next.kind == tokLParen && !next.spaceBefore {
+		return errorf(tok.pos, "function calls are not supported: %s(...)", tok.text)
+	}
+	return nil
+}
+
+func splitPath(tok token) ([]string, error) {
+	path := strings.Split(tok.text, ".")
+	for _, segment := range path {
+		if segment == "" {
+			return nil, errorf(tok.pos, "invalid field path %q", tok.text)
+		}
+	}
+	return path, nil
+}
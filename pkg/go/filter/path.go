@@ -0,0 +1,192 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"strconv"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// step is a resolved segment of a field path.
+type step struct {
+	field protoreflect.FieldDescriptor
+	// key selects a single entry if field is a map. Without a key, a map
+	// field resolves to its keys.
+	key    protoreflect.MapKey
+	hasKey bool
+}
+
+// fieldPath is a field path resolved against a message descriptor.
+type fieldPath []step
+
+// leaf returns the descriptor that describes the values found at the path.
+// For list fields this is the list field itself, whose kind is the kind of
+// its elements.
+func (p fieldPath) leaf() protoreflect.FieldDescriptor {
+	if len(p) == 0 {
+		return nil
+	}
+	last := p[len(p)-1]
+	switch {
+	case !last.field.IsMap():
+		return last.field
+	case last.hasKey:
+		return last.field.MapValue()
+	default:
+		return last.field.MapKey()
+	}
+}
+
+// resolvePath resolves the segments of a field path, accepting both proto and
+// JSON field names.
+func resolvePath(md protoreflect.MessageDescriptor, path []string, pos int) (fieldPath, error) {
+	var resolved fieldPath
+	for i := 0; i < len(path); i++ {
+		if md == nil {
+			return nil, errorf(pos, "cannot select field %q of a non-message value", path[i])
+		}
+		fd := md.Fields().ByName(protoreflect.Name(path[i]))
+		if fd == nil {
+			fd = md.Fields().ByJSONName(path[i])
+		}
+		if fd == nil {
+			return nil, errorf(pos, "no field %q in %s", path[i], md.FullName())
+		}
+
+		s := step{field: fd}
+		next := fd
+		if fd.IsMap() && i+1 < len(path) {
+			i++
+			key, err := mapKey(fd.MapKey(), path[i])
+			if err != nil {
+				return nil, errorf(pos, "invalid key %q for map field %q: %v", path[i], fd.Name(), err)
+			}
+			s.key, s.hasKey = key, true
+			next = fd.MapValue()
+		}
+		resolved = append(resolved, s)
+
+		md = nil
+		if next.Kind() == protoreflect.MessageKind || next.Kind() == protoreflect.GroupKind {
+			md = next.Message()
+		}
+	}
+	return resolved, nil
+}
+
+func mapKey(fd protoreflect.FieldDescriptor, s string) (protoreflect.MapKey, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s).MapKey(), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(b).MapKey(), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)).MapKey(), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(n).MapKey(), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)).MapKey(), err
+	default:
+		n, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(n).MapKey(), err
+	}
+}
+
+// value is a value found in a message, together with the descriptor that
+// describes it. The descriptor is nil for the root message.
+type value struct {
+	v  protoreflect.Value
+	fd protoreflect.FieldDescriptor
+}
+
+// messageDescriptor returns the descriptor of the value if it is a message,
+// and nil otherwise.
+func (v value) messageDescriptor() protoreflect.MessageDescriptor {
+	switch {
+	case v.fd == nil:
+		return v.v.Message().Descriptor()
+	case v.fd.Kind() == protoreflect.MessageKind || v.fd.Kind() == protoreflect.GroupKind:
+		return v.fd.Message()
+	default:
+		return nil
+	}
+}
+
+// collect returns all values found at the path, expanding repeated fields.
+// Unset message and optional fields contribute no values.
+func collect(m protoreflect.Message, p fieldPath) []value {
+	current := []protoreflect.Message{m}
+	var values []value
+	for i, s := range p {
+		last := i == len(p)-1
+		var next []protoreflect.Message
+		emit := func(v protoreflect.Value, fd protoreflect.FieldDescriptor) {
+			if last {
+				values = append(values, value{v: v, fd: fd})
+			} else {
+				next = append(next, v.Message())
+			}
+		}
+		for _, msg := range current {
+			fd := s.field
+			switch {
+			case fd.IsList():
+				list := msg.Get(fd).List()
+				for j := 0; j < list.Len(); j++ {
+					emit(list.Get(j), fd)
+				}
+			case fd.IsMap():
+				entries := msg.Get(fd).Map()
+				if s.hasKey {
+					if entries.Has(s.key) {
+						emit(entries.Get(s.key), fd.MapValue())
+					}
+					continue
+				}
+				entries.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+					emit(k.Value(), fd.MapKey())
+					return true
+				})
+			default:
+				if fd.HasPresence() && !msg.Has(fd) {
+					continue
+				}
+				emit(msg.Get(fd), fd)
+			}
+		}
+		current = next
+	}
+	return values
+}
+
+// Lookup resolves a field path in the same way as the restrictions of a
+// filter, and returns the fields along the path. The key segment that follows
+// a map field is skipped.
+func Lookup(md protoreflect.MessageDescriptor, path []string) ([]protoreflect.FieldDescriptor, error) {
+	resolved, err := resolvePath(md, path, 0)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]protoreflect.FieldDescriptor, len(resolved))
+	for i, s := range resolved {
+		fields[i] = s.field
+	}
+	return fields, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package frequencyplan computes the frequency and bandwidth limits of contact
+// windows from the spectrum that both a transceiver and a target support.
+//
+// The frequency plan of a target declares the channels in which bearers with
+// the target may be placed. A transceiver supports the band of half the
+// bandwidth on either side of the center frequency of each of its signals. The
+// limits of a contact window are those of the widest band that a channel and a
+// signal have in common: a bearer of the maximum bandwidth fits into the band
+// at any center frequency within the limits.
+package frequencyplan
+
+import (
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"outernetcouncil.org/nmts/v1/proto/ek/physical"
+)
+
+// Channel is a band of a frequency plan in Hz together with the bandwidths
+// that bearers in the band may have.
+type Channel struct {
+	LowHz, HighHz int64
+	// MinBandwidthHz and MaxBandwidthHz limit the bandwidth of bearers. A zero
+	// maximum allows bearers as wide as the band.
+	MinBandwidthHz, MaxBandwidthHz int64
+}
+
+// Plan is the frequency plan of a target. The directions are those of the
+// transceiver: it receives in the Rx channels and transmits in the Tx
+// channels.
+type Plan struct {
+	Rx, Tx []Channel
+}
+
+// Limits are the limits on the center frequency and bandwidth of bearers in
+// one direction of a contact window.
+type Limits struct {
+	MinCenterFrequencyHz, MaxCenterFrequencyHz int64
+	MinBandwidthHz, MaxBandwidthHz             int64
+}
+
+// Common returns the limits of the widest band that one of the channels has
+// in common with one of the signals. If there are no signals, the transceiver
+// is assumed to support any frequency. ok is false if no bearer fits into the
+// common spectrum.
+func Common(channels []Channel, signals []*physical.Signal) (limits Limits, ok bool) {
+	for _, channel := range channels {
+		bands := [][2]int64{{channel.LowHz, channel.HighHz}}
+		if len(signals) > 0 {
+			bands = bands[:0]
+			for _, s := range signals {
+				low := max(channel.LowHz, s.GetCenterFrequencyHz()-s.GetBandwidthHz()/2)
+				high := min(channel.HighHz, s.GetCenterFrequencyHz()+s.GetBandwidthHz()/2)
+				bands = append(bands, [2]int64{low, high})
+			}
+		}
+		for _, band := range bands {
+			low, high := band[0], band[1]
+			bandwidth := high - low
+			if channel.MaxBandwidthHz > 0 {
+				bandwidth = min(bandwidth, channel.MaxBandwidthHz)
+			}
+			minBandwidth := max(channel.MinBandwidthHz, 1)
+			if bandwidth < minBandwidth || (ok && bandwidth <= limits.MaxBandwidthHz) {
+				continue
+			}
+			limits = Limits{
+				MinCenterFrequencyHz: low + bandwidth/2,
+				MaxCenterFrequencyHz: high - bandwidth/2,
+				MinBandwidthHz:       minBandwidth,
+				MaxBandwidthHz:       bandwidth,
+			}
+			ok = true
+		}
+	}
+	return limits, ok
+}
+
+// Limits returns the receive and transmit limits of the contact windows of the
+// transceiver with a target that has the plan. ok is false if the transceiver
+// and the target have no spectrum in common in either direction.
+func (p Plan) Limits(transceiver *pb.Transceiver) (rx, tx Limits, ok bool) {
+	var rxSignals, txSignals []*physical.Signal
+	for _, s := range transceiver.GetReceiveSignalChain().GetReceiver().GetSignals() {
+		rxSignals = append(rxSignals, s.GetSignal())
+	}
+	for _, s := range transceiver.GetTransmitSignalChain().GetTransmitter().GetSignals() {
+		txSignals = append(txSignals, s.GetSignal())
+	}
+	rx, rxOK := Common(p.Rx, rxSignals)
+	tx, txOK := Common(p.Tx, txSignals)
+	return rx, tx, rxOK && txOK
+}
+
+// Apply sets the limits of the contact window.
+func Apply(window *pb.ContactWindow, rx, tx Limits) {
+	window.MinRxCenterFrequencyHz = rx.MinCenterFrequencyHz
+	window.MaxRxCenterFrequencyHz = rx.MaxCenterFrequencyHz
+	window.MinRxBandwidthHz = rx.MinBandwidthHz
+	window.MaxRxBandwidthHz = rx.MaxBandwidthHz
+	window.MinTxCenterFrequencyHz = tx.MinCenterFrequencyHz
+	window.MaxTxCenterFrequencyHz = tx.MaxCenterFrequencyHz
+	window.MinTxBandwidthHz = tx.MinBandwidthHz
+	window.MaxTxBandwidthHz = tx.MaxBandwidthHz
+}
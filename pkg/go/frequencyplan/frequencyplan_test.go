@@ -0,0 +1,135 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frequencyplan
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"google.golang.org/protobuf/testing/protocmp"
+	"outernetcouncil.org/nmts/v1/proto/ek/physical"
+)
+
+var kuBand = Channel{LowHz: 11980000000, HighHz: 18020000000, MinBandwidthHz: 20000000, MaxBandwidthHz: 40000000}
+
+func TestCommon(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []Channel
+		signals  []*physical.Signal
+		want     Limits
+		wantOK   bool
+	}{
+		{
+			name:     "any frequency",
+			channels: []Channel{kuBand},
+			want:     Limits{MinCenterFrequencyHz: 12000000000, MaxCenterFrequencyHz: 18000000000, MinBandwidthHz: 20000000, MaxBandwidthHz: 40000000},
+			wantOK:   true,
+		},
+		{
+			name:     "signal within channel",
+			channels: []Channel{kuBand},
+			signals:  []*physical.Signal{{CenterFrequencyHz: 14000000000, BandwidthHz: 30000000}},
+			want:     Limits{MinCenterFrequencyHz: 14000000000, MaxCenterFrequencyHz: 14000000000, MinBandwidthHz: 20000000, MaxBandwidthHz: 30000000},
+			wantOK:   true,
+		},
+		{
+			name:     "wide signal",
+			channels: []Channel{kuBand},
+			signals:  []*physical.Signal{{CenterFrequencyHz: 14000000000, BandwidthHz: 100000000}},
+			want:     Limits{MinCenterFrequencyHz: 13970000000, MaxCenterFrequencyHz: 14030000000, MinBandwidthHz: 20000000, MaxBandwidthHz: 40000000},
+			wantOK:   true,
+		},
+		{
+			name:     "signal at the edge of the channel",
+			channels: []Channel{kuBand},
+			signals:  []*physical.Signal{{CenterFrequencyHz: 18000000000, BandwidthHz: 100000000}},
+			want:     Limits{MinCenterFrequencyHz: 17970000000, MaxCenterFrequencyHz: 18000000000, MinBandwidthHz: 20000000, MaxBandwidthHz: 40000000},
+			wantOK:   true,
+		},
+		{
+			name:     "widest band",
+			channels: []Channel{{LowHz: 1000, HighHz: 2000}, {LowHz: 3000, HighHz: 6000}},
+			signals:  []*physical.Signal{{CenterFrequencyHz: 1500, BandwidthHz: 1000}, {CenterFrequencyHz: 5000, BandwidthHz: 2000}},
+			want:     Limits{MinCenterFrequencyHz: 5000, MaxCenterFrequencyHz: 5000, MinBandwidthHz: 1, MaxBandwidthHz: 2000},
+			wantOK:   true,
+		},
+		{
+			name:     "signal too narrow",
+			channels: []Channel{kuBand},
+			signals:  []*physical.Signal{{CenterFrequencyHz: 14000000000, BandwidthHz: 10000000}},
+		},
+		{
+			name:     "signal outside of channel",
+			channels: []Channel{kuBand},
+			signals:  []*physical.Signal{{CenterFrequencyHz: 8000000000, BandwidthHz: 30000000}},
+		},
+		{
+			name:    "no channels",
+			signals: []*physical.Signal{{CenterFrequencyHz: 14000000000, BandwidthHz: 30000000}},
+		},
+	}
+	for _, tc := range tests {
+		got, ok := Common(tc.channels, tc.signals)
+		if ok != tc.wantOK {
+			t.Errorf("%s: Common() ok = %v, want %v", tc.name, ok, tc.wantOK)
+		}
+		if diff := cmp.Diff(tc.want, got); ok && diff != "" {
+			t.Errorf("%s: Common() mismatch (-want +got):\n%s", tc.name, diff)
+		}
+	}
+}
+
+func TestPlan_Limits(t *testing.T) {
+	plan := Plan{
+		Rx: []Channel{{LowHz: 10700000000, HighHz: 12700000000, MinBandwidthHz: 10000000, MaxBandwidthHz: 50000000}},
+		Tx: []Channel{{LowHz: 14000000000, HighHz: 14500000000, MinBandwidthHz: 10000000, MaxBandwidthHz: 50000000}},
+	}
+	transceiver := &pb.Transceiver{
+		ReceiveSignalChain: &pb.ReceiveSignalChain{Receiver: &physical.Receiver{Signals: []*physical.ReceiveSignal{
+			{Signal: &physical.Signal{CenterFrequencyHz: 11700000000, BandwidthHz: 500000000}},
+		}}},
+		TransmitSignalChain: &pb.TransmitSignalChain{Transmitter: &physical.Transmitter{Signals: []*physical.TransmitSignal{
+			{Signal: &physical.Signal{CenterFrequencyHz: 14250000000, BandwidthHz: 20000000}},
+		}}},
+	}
+	rx, tx, ok := plan.Limits(transceiver)
+	if !ok {
+		t.Fatalf("Limits() ok = false, want true")
+	}
+	window := &pb.ContactWindow{Name: "contactWindows/a"}
+	Apply(window, rx, tx)
+	want := &pb.ContactWindow{
+		Name:                   "contactWindows/a",
+		MinRxCenterFrequencyHz: 11475000000,
+		MaxRxCenterFrequencyHz: 11925000000,
+		MinRxBandwidthHz:       10000000,
+		MaxRxBandwidthHz:       50000000,
+		MinTxCenterFrequencyHz: 14250000000,
+		MaxTxCenterFrequencyHz: 14250000000,
+		MinTxBandwidthHz:       10000000,
+		MaxTxBandwidthHz:       20000000,
+	}
+	if diff := cmp.Diff(want, window, protocmp.Transform()); diff != "" {
+		t.Errorf("Apply() mismatch (-want +got):\n%s", diff)
+	}
+
+	// Without common spectrum in one direction, there is no contact window.
+	transceiver.TransmitSignalChain.Transmitter.Signals[0].Signal.CenterFrequencyHz = 30000000000
+	if _, _, ok := plan.Limits(transceiver); ok {
+		t.Errorf("Limits() ok = true, want false without common transmit spectrum")
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"github.com/outernetcouncil/federation/pkg/go/filter"
+)
+
+// ApplyFilter returns the items that match the filter of a List request, so
+// that every InterconnectHandler implements the same filter semantics. An
+// empty filter returns all items. The filter is checked against the item type
+// before it is evaluated, and invalid filters are reported as InvalidArgument
+// with google.rpc.BadRequest details.
+func ApplyFilter[T proto.Message](expr string, items []T) ([]T, error) {
+	if expr == "" {
+		return items, nil
+	}
+	var zero T
+	f, err := ParseFilter("filter", expr, zero.ProtoReflect().Descriptor())
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]T, 0, len(items))
+	for _, item := range items {
+		ok, err := f.Matches(item)
+		if err != nil {
+			return nil, FilterError("filter", err)
+		}
+		if ok {
+			matched = append(matched, item)
+		}
+	}
+	return matched, nil
+}
+
+// ParseFilter parses a filter and checks it against the descriptor of the
+// messages it applies to. Errors are reported as InvalidArgument with a
+// google.rpc.BadRequest field violation for the given request field.
+func ParseFilter(field, expr string, md protoreflect.MessageDescriptor) (*filter.Filter, error) {
+	f, err := filter.Parse(expr)
+	if err != nil {
+		return nil, FilterError(field, err)
+	}
+	if err := f.Check(md); err != nil {
+		return nil, FilterError(field, err)
+	}
+	return f, nil
+}
+
+// ValidateCompatibleTransceiverTypes checks that the filters advertised by the
+// handler are valid Transceiver filters. Handlers which do not implement
+// ListCompatibleTransceiverTypes are skipped.
+func ValidateCompatibleTransceiverTypes(ctx context.Context, h InterconnectHandler) error {
+	resp, err := h.ListCompatibleTransceiverTypes(ctx, &pb.ListCompatibleTransceiverTypesRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list compatible transceiver types: %w", err)
+	}
+
+	md := (&pb.Transceiver{}).ProtoReflect().Descriptor()
+	var fieldViolations []*errdetails.BadRequest_FieldViolation
+	for i, compatibleType := range resp.CompatibleTransceiverTypes {
+		field := fmt.Sprintf("compatible_transceiver_types[%d].transceiver_filter", i)
+		f, err := filter.Parse(compatibleType.TransceiverFilter)
+		if err == nil {
+			err = f.Check(md)
+		}
+		if err != nil {
+			fieldViolations = append(fieldViolations, toFieldViolations(field, err)...)
+		}
+	}
+	if len(fieldViolations) > 0 {
+		return badRequest("invalid compatible transceiver types", fieldViolations)
+	}
+	return nil
+}
+
+// FilterError converts an error of the filter package into an InvalidArgument
+// status with one google.rpc.BadRequest field violation per offending clause.
+func FilterError(field string, err error) error {
+	return badRequest(err.Error(), toFieldViolations(field, err))
+}
+
+func toFieldViolations(field string, err error) []*errdetails.BadRequest_FieldViolation {
+	var errs filter.Errors
+	if !errors.As(err, &errs) {
+		var ferr *filter.Error
+		if !errors.As(err, &ferr) {
+			return []*errdetails.BadRequest_FieldViolation{{Field: field, Description: err.Error()}}
+		}
+		errs = filter.Errors{ferr}
+	}
+
+	fieldViolations := make([]*errdetails.BadRequest_FieldViolation, 0, len(errs))
+	for _, e := range errs {
+		fieldViolations = append(fieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: e.Error(),
+		})
+	}
+	return fieldViolations
+}
+
+func badRequest(msg string, fieldViolations []*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(codes.InvalidArgument, msg)
+	if withDetails, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: fieldViolations}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+func TestApplyFilter(t *testing.T) {
+	bearers := []*pb.Bearer{
+		{Name: "bearers/a", Transceiver: "transceivers/gs1", RxBandwidthHz: 20000000},
+		{Name: "bearers/b", Transceiver: "transceivers/gs2", RxBandwidthHz: 40000000},
+	}
+
+	got, err := ApplyFilter(`transceiver = "transceivers/gs1" OR rx_bandwidth_hz > 30000000`, bearers)
+	if err != nil {
+		t.Fatalf("ApplyFilter failed: %v", err)
+	}
+	if diff := cmp.Diff(bearers, got, protocmp.Transform()); diff != "" {
+		t.Errorf("ApplyFilter mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = ApplyFilter("rx_bandwidth_hz < 30000000", bearers)
+	if err != nil {
+		t.Fatalf("ApplyFilter failed: %v", err)
+	}
+	if diff := cmp.Diff(bearers[:1], got, protocmp.Transform()); diff != "" {
+		t.Errorf("ApplyFilter mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestApplyFilter_InvalidFilter(t *testing.T) {
+	tests := []struct {
+		name           string
+		filter         string
+		wantViolations int
+	}{
+		{name: "syntax error", filter: "transceiver =", wantViolations: 1},
+		{name: "unknown fields", filter: "antenna = OPTICAL AND transceiver.name = x", wantViolations: 2},
+		{name: "type mismatch", filter: "rx_bandwidth_hz > wide", wantViolations: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The filter must be rejected even if there is nothing to match.
+			_, err := ApplyFilter(tt.filter, []*pb.Bearer{})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("ApplyFilter(%q) returned %v, want InvalidArgument", tt.filter, err)
+			}
+			badRequest := badRequestDetails(t, err)
+			if len(badRequest.FieldViolations) != tt.wantViolations {
+				t.Fatalf("got %d field violations, want %d: %v", len(badRequest.FieldViolations), tt.wantViolations, badRequest)
+			}
+			for _, v := range badRequest.FieldViolations {
+				if v.Field != "filter" {
+					t.Errorf("field violation for %q, want filter", v.Field)
+				}
+			}
+		})
+	}
+}
+
+type compatibleTypesHandler struct {
+	pb.UnimplementedInterconnectServiceServer
+	filters []string
+}
+
+func (h *compatibleTypesHandler) ListCompatibleTransceiverTypes(context.Context, *pb.ListCompatibleTransceiverTypesRequest) (*pb.ListCompatibleTransceiverTypesResponse, error) {
+	resp := &pb.ListCompatibleTransceiverTypesResponse{}
+	for _, f := range h.filters {
+		resp.CompatibleTransceiverTypes = append(resp.CompatibleTransceiverTypes, &pb.CompatibleTransceiverType{TransceiverFilter: f})
+	}
+	return resp, nil
+}
+
+func TestValidateCompatibleTransceiverTypes(t *testing.T) {
+	ctx := context.Background()
+
+	if err := ValidateCompatibleTransceiverTypes(ctx, &pb.UnimplementedInterconnectServiceServer{}); err != nil {
+		t.Errorf("handlers without compatible transceiver types should be skipped, got %v", err)
+	}
+
+	valid := &compatibleTypesHandler{filters: []string{
+		"transmit_signal_chain.antenna.type = OPTICAL AND receive_signal_chain.antenna.type = OPTICAL",
+		"transmit_signal_chain.transmitter.signals.signal.center_frequency_hz:(>= 8000000000 AND <= 12000000000)",
+	}}
+	if err := ValidateCompatibleTransceiverTypes(ctx, valid); err != nil {
+		t.Errorf("ValidateCompatibleTransceiverTypes failed: %v", err)
+	}
+
+	invalid := &compatibleTypesHandler{filters: []string{
+		"transmit_signal_chain.antenna.type = OPTICAL",
+		"transmit_signal_chain.antenna.type = LASER",
+	}}
+	err := ValidateCompatibleTransceiverTypes(ctx, invalid)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("ValidateCompatibleTransceiverTypes returned %v, want InvalidArgument", err)
+	}
+	badRequest := badRequestDetails(t, err)
+	if len(badRequest.FieldViolations) != 1 || badRequest.FieldViolations[0].Field != "compatible_transceiver_types[1].transceiver_filter" {
+		t.Errorf("unexpected field violations: %v", badRequest)
+	}
+}
+
+func badRequestDetails(t *testing.T, err error) *errdetails.BadRequest {
+	t.Helper()
+
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			return badRequest
+		}
+	}
+	t.Fatalf("error %v has no BadRequest details", err)
+	return nil
+}
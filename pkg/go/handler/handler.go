@@ -16,6 +16,8 @@
 package handler
 
 import (
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+
 	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
 )
 
@@ -23,3 +25,10 @@ import (
 type InterconnectHandler interface {
 	pb.InterconnectServiceServer
 }
+
+// OperationsHandler is implemented by handlers whose RPCs start long-running
+// operations. The server then also serves the google.longrunning.Operations
+// service.
+type OperationsHandler interface {
+	Operations() longrunningpb.OperationsServer
+}
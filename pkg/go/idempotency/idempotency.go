@@ -0,0 +1,209 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package idempotency deduplicates retried requests by their request ID, see
+// https://google.aip.dev/155.
+//
+// A request with a request ID is applied at most once within the
+// deduplication window. A retry with the same request ID and the same payload
+// returns the response of the original request, or waits for it if the
+// original request is still running. A retry with the same request ID but a
+// different payload is rejected with INVALID_ARGUMENT. Only successful
+// responses are remembered, so that a failed request can be retried.
+//
+// The expvar variable idempotency_replays counts the responses returned to
+// retries instead of applying the request again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"expvar"
+	"regexp"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DefaultWindow is the default time for which the response of a request is
+// remembered.
+const DefaultWindow = 10 * time.Minute
+
+// fieldName is the name of the request ID field of a request.
+const fieldName protoreflect.Name = "request_id"
+
+var (
+	replays = expvar.NewInt("idempotency_replays")
+
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// RequestID returns the request ID of a request, or "" if the request has no
+// request ID.
+func RequestID(req proto.Message) string {
+	m := req.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName(fieldName)
+	if fd == nil || fd.Kind() != protoreflect.StringKind || fd.Cardinality() == protoreflect.Repeated {
+		return ""
+	}
+	return m.Get(fd).String()
+}
+
+type key struct {
+	method    string
+	requestID string
+}
+
+type entry struct {
+	fingerprint [sha256.Size]byte
+	// done is closed when the original request completed. The fields below
+	// must not be read before.
+	done       chan struct{}
+	response   any
+	err        error
+	expireTime time.Time
+}
+
+// Cache remembers the responses of requests with a request ID.
+type Cache struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[key]*entry
+}
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithWindow sets the time for which the response of a request is remembered.
+func WithWindow(window time.Duration) Option {
+	return func(c *Cache) {
+		c.window = window
+	}
+}
+
+// WithClock replaces the function that returns the current time.
+func WithClock(now func() time.Time) Option {
+	return func(c *Cache) {
+		c.now = now
+	}
+}
+
+// New returns an empty Cache.
+func New(opts ...Option) *Cache {
+	c := &Cache{
+		window:  DefaultWindow,
+		now:     time.Now,
+		entries: make(map[key]*entry),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// UnaryServerInterceptor returns an interceptor which deduplicates the
+// requests with a request ID. Requests of the same method with the same
+// request ID are duplicates.
+func (c *Cache) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		m, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		requestID := RequestID(m)
+		if requestID == "" {
+			return handler(ctx, req)
+		}
+		if !uuidPattern.MatchString(requestID) {
+			return nil, status.Errorf(codes.InvalidArgument, "request_id %q is not a UUID", requestID)
+		}
+		fingerprint, err := fingerprint(m)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to fingerprint request: %v", err)
+		}
+		return c.do(ctx, key{info.FullMethod, requestID}, fingerprint, func() (any, error) {
+			return handler(ctx, req)
+		})
+	}
+}
+
+func (c *Cache) do(ctx context.Context, k key, fingerprint [sha256.Size]byte, handle func() (any, error)) (any, error) {
+	for {
+		c.mu.Lock()
+		c.expireLocked()
+		e := c.entries[k]
+		if e == nil {
+			e = &entry{fingerprint: fingerprint, done: make(chan struct{})}
+			c.entries[k] = e
+			c.mu.Unlock()
+
+			e.response, e.err = handle()
+
+			c.mu.Lock()
+			if e.err != nil {
+				delete(c.entries, k)
+			} else {
+				e.expireTime = c.now().Add(c.window)
+			}
+			close(e.done)
+			c.mu.Unlock()
+			return e.response, e.err
+		}
+		c.mu.Unlock()
+
+		if e.fingerprint != fingerprint {
+			return nil, status.Errorf(codes.InvalidArgument, "request_id %q was already used for a different request", k.requestID)
+		}
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if e.err != nil {
+			// The original request failed and may be retried.
+			continue
+		}
+		replays.Add(1)
+		if response, ok := e.response.(proto.Message); ok {
+			return proto.Clone(response), nil
+		}
+		return e.response, nil
+	}
+}
+
+// expireLocked forgets the responses whose window ended.
+func (c *Cache) expireLocked() {
+	now := c.now()
+	for k, e := range c.entries {
+		if !e.expireTime.IsZero() && !e.expireTime.After(now) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// fingerprint returns a hash of the payload of a request.
+func fingerprint(m proto.Message) ([sha256.Size]byte, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(b), nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+const (
+	requestID      = "8d3b6c1e-2f4a-4b5c-9d6e-7f8091a2b3c4"
+	otherRequestID = "0f1e2d3c-4b5a-4968-8776-655443322110"
+	method         = "/outernet.federation.interconnect.v1alpha.InterconnectService/CreateBearer"
+)
+
+// fakeHandler counts its calls and returns a bearer named after the call.
+type fakeHandler struct {
+	calls int
+	err   error
+}
+
+func (h *fakeHandler) handle(context.Context, any) (any, error) {
+	h.calls++
+	if h.err != nil {
+		return nil, h.err
+	}
+	return &pb.Bearer{Name: fmt.Sprintf("bearers/%d", h.calls)}, nil
+}
+
+func call(c *Cache, h *fakeHandler, fullMethod string, req *pb.CreateBearerRequest) (any, error) {
+	return c.UnaryServerInterceptor()(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: fullMethod}, h.handle)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name      string
+		first     *pb.CreateBearerRequest
+		retry     *pb.CreateBearerRequest
+		method    string
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{
+			name:      "retry is replayed",
+			first:     &pb.CreateBearerRequest{BearerId: "a", RequestId: requestID},
+			retry:     &pb.CreateBearerRequest{BearerId: "a", RequestId: requestID},
+			wantCalls: 1,
+		},
+		{
+			name:      "requests without request ID are applied",
+			first:     &pb.CreateBearerRequest{BearerId: "a"},
+			retry:     &pb.CreateBearerRequest{BearerId: "a"},
+			wantCalls: 2,
+		},
+		{
+			name:      "different request IDs are applied",
+			first:     &pb.CreateBearerRequest{BearerId: "a", RequestId: requestID},
+			retry:     &pb.CreateBearerRequest{BearerId: "a", RequestId: otherRequestID},
+			wantCalls: 2,
+		},
+		{
+			name:      "request IDs of different methods are independent",
+			first:     &pb.CreateBearerRequest{BearerId: "a", RequestId: requestID},
+			retry:     &pb.CreateBearerRequest{BearerId: "a", RequestId: requestID},
+			method:    "/outernet.federation.interconnect.v1alpha.InterconnectService/ProvisionBearer",
+			wantCalls: 2,
+		},
+		{
+			name:      "different payload is rejected",
+			first:     &pb.CreateBearerRequest{BearerId: "a", RequestId: requestID},
+			retry:     &pb.CreateBearerRequest{BearerId: "b", RequestId: requestID},
+			wantCalls: 1,
+			wantCode:  codes.InvalidArgument,
+		},
+		{
+			name:      "request ID must be a UUID",
+			first:     &pb.CreateBearerRequest{BearerId: "a"},
+			retry:     &pb.CreateBearerRequest{BearerId: "a", RequestId: "retry-1"},
+			wantCalls: 1,
+			wantCode:  codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			h := &fakeHandler{}
+			first, err := call(c, h, method, tt.first)
+			if err != nil {
+				t.Fatalf("first call failed: %v", err)
+			}
+			retryMethod := method
+			if tt.method != "" {
+				retryMethod = tt.method
+			}
+			retry, err := call(c, h, retryMethod, tt.retry)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("retry returned %v, want %v", err, tt.wantCode)
+			}
+			if h.calls != tt.wantCalls {
+				t.Errorf("handler was called %d times, want %d", h.calls, tt.wantCalls)
+			}
+			if err == nil && tt.wantCalls == 1 {
+				if diff := cmp.Diff(first, retry, protocmp.Transform()); diff != "" {
+					t.Errorf("replayed response mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor_ErrorsAreNotRemembered(t *testing.T) {
+	c := New()
+	h := &fakeHandler{err: status.Error(codes.Unavailable, "try again")}
+	req := &pb.CreateBearerRequest{BearerId: "a", RequestId: requestID}
+
+	if _, err := call(c, h, method, req); status.Code(err) != codes.Unavailable {
+		t.Fatalf("first call returned %v, want Unavailable", err)
+	}
+	h.err = nil
+	if _, err := call(c, h, method, req); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if h.calls != 2 {
+		t.Errorf("handler was called %d times, want 2", h.calls)
+	}
+}
+
+func TestUnaryServerInterceptor_Window(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := New(WithWindow(time.Minute), WithClock(func() time.Time { return now }))
+	h := &fakeHandler{}
+	req := &pb.CreateBearerRequest{BearerId: "a", RequestId: requestID}
+
+	for _, advance := range []time.Duration{0, 30 * time.Second, time.Minute} {
+		now = now.Add(advance)
+		if _, err := call(c, h, method, req); err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+	}
+	// The last call is more than a minute after the first one.
+	if h.calls != 2 {
+		t.Errorf("handler was called %d times, want 2", h.calls)
+	}
+}
+
+func TestUnaryServerInterceptor_WaitsForOriginalRequest(t *testing.T) {
+	c := New()
+	req := &pb.CreateBearerRequest{BearerId: "a", RequestId: requestID}
+	started, release := make(chan struct{}), make(chan struct{})
+	calls := 0
+	handler := func(context.Context, any) (any, error) {
+		calls++
+		close(started)
+		<-release
+		return &pb.Bearer{Name: "bearers/a"}, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+
+	done := make(chan any)
+	go func() {
+		resp, _ := c.UnaryServerInterceptor()(context.Background(), req, info, handler)
+		done <- resp
+	}()
+	<-started
+	go func() {
+		resp, _ := c.UnaryServerInterceptor()(context.Background(), req, info, handler)
+		done <- resp
+	}()
+	close(release)
+
+	for range 2 {
+		if resp := <-done; resp.(*pb.Bearer).GetName() != "bearers/a" {
+			t.Errorf("unexpected response %v", resp)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler was called %d times, want 1", calls)
+	}
+}
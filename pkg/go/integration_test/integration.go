@@ -43,7 +43,7 @@ func (h *testHandler) UpdateTransceiver(context.Context, *pb.UpdateTransceiverRe
 	return nil, nil
 }
 
-func (h *testHandler) DeleteTransceiver(context.Context, *pb.DeleteTransceiverRequest) (*emptypb.Empty, error) {
+func (h *testHandler) DeleteTransceiver(context.Context, *pb.DeleteTransceiverRequest) (*pb.DeleteTransceiverResponse, error) {
 	return nil, nil
 }
 
@@ -59,7 +59,7 @@ func (h *testHandler) CreateBearer(context.Context, *pb.CreateBearerRequest) (*p
 	return nil, nil
 }
 
-func (h *testHandler) DeleteBearer(context.Context, *pb.DeleteBearerRequest) (*emptypb.Empty, error) {
+func (h *testHandler) DeleteBearer(context.Context, *pb.DeleteBearerRequest) (*pb.DeleteBearerResponse, error) {
 	return nil, nil
 }
 
@@ -0,0 +1,138 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linkbudget
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"outernetcouncil.org/nmts/v1/proto/ek/physical"
+)
+
+// referenceTemperatureK is the reference temperature of noise figures, which is
+// also assumed as the noise temperature of the antenna.
+const referenceTemperatureK = 290
+
+// ErrNoCommonModulation is returned by Links if a transceiver and a terminal
+// have no known modulation scheme in common.
+var ErrNoCommonModulation = errors.New("linkbudget: no common modulation scheme")
+
+// Terminal is the end of the links with a transceiver on the provider's side,
+// e.g. the payload of a target.
+type Terminal struct {
+	Transmitter Transmitter
+	Receiver    Receiver
+	// ModulationSchemes are the modulation schemes which the terminal can
+	// modulate and demodulate.
+	ModulationSchemes []string
+}
+
+// chainLossDB returns the loss of signal processing chains, which is the
+// negative of their total gain.
+func chainLossDB(chains []*physical.SignalProcessingChain) float64 {
+	var gainDB float64
+	for _, chain := range chains {
+		gainDB += chain.GetGainDb()
+	}
+	return -gainDB
+}
+
+// noiseTemperatureK returns the system noise temperature of a receive signal
+// chain, referred to the antenna terminals. Unless the receiver declares it,
+// it is computed from the noise figures of the signal processing chains with
+// the Friis formula.
+func noiseTemperatureK(chain *pb.ReceiveSignalChain) (float64, error) {
+	if t := chain.GetReceiver().GetSystemNoiseTemperatureK(); t > 0 {
+		return t, nil
+	}
+	temperature, gain := float64(referenceTemperatureK), 1.0
+	var hasNoiseFigure bool
+	for _, c := range chain.GetSignalProcessingChains() {
+		if c.GetNoiseFigureDb() > 0 {
+			hasNoiseFigure = true
+		}
+		temperature += referenceTemperatureK * (math.Pow(10, c.GetNoiseFigureDb()/10) - 1) / gain
+		gain *= math.Pow(10, c.GetGainDb()/10)
+	}
+	if !hasNoiseFigure {
+		return 0, errors.New("linkbudget: receive_signal_chain requires receiver.system_noise_temperature_k or the noise figures of its signal_processing_chains")
+	}
+	return temperature, nil
+}
+
+// Links returns the links from the transceiver to the terminal, one per
+// transmit signal, and the links from the terminal to the transceiver, one per
+// receive signal. Each link uses the most robust modulation scheme which both
+// ends support.
+func Links(transceiver *pb.Transceiver, terminal Terminal) (uplinks, downlinks []Link, err error) {
+	tx, rx := transceiver.GetTransmitSignalChain(), transceiver.GetReceiveSignalChain()
+	if len(tx.GetTransmitter().GetSignals()) == 0 {
+		return nil, nil, errors.New("linkbudget: transmit_signal_chain.transmitter.signals is required")
+	}
+	if len(rx.GetReceiver().GetSignals()) == 0 {
+		return nil, nil, errors.New("linkbudget: receive_signal_chain.receiver.signals is required")
+	}
+	temperature, err := noiseTemperatureK(rx)
+	if err != nil {
+		return nil, nil, err
+	}
+	uplinkModulation, ok := MostRobustModulation(tx.GetModulator().GetModulationSchemes(), terminal.ModulationSchemes)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w for the uplink", ErrNoCommonModulation)
+	}
+	downlinkModulation, ok := MostRobustModulation(terminal.ModulationSchemes, rx.GetDemodulator().GetModulationSchemes())
+	if !ok {
+		return nil, nil, fmt.Errorf("%w for the downlink", ErrNoCommonModulation)
+	}
+
+	for i, signal := range tx.GetTransmitter().GetSignals() {
+		if signal.GetSignal().GetCenterFrequencyHz() <= 0 || signal.GetSignal().GetBandwidthHz() <= 0 {
+			return nil, nil, fmt.Errorf("linkbudget: transmit_signal_chain.transmitter.signals[%d] requires a center frequency and bandwidth", i)
+		}
+		uplinks = append(uplinks, Link{
+			Transmitter: Transmitter{
+				PowerDBW:       signal.GetPowerDbw(),
+				LossDB:         chainLossDB(tx.GetSignalProcessingChains()),
+				AntennaGainDBi: tx.GetAntenna().GetGainDb(),
+			},
+			Receiver:    terminal.Receiver,
+			FrequencyHz: float64(signal.GetSignal().GetCenterFrequencyHz()),
+			BandwidthHz: float64(signal.GetSignal().GetBandwidthHz()),
+			Modulation:  uplinkModulation,
+		})
+	}
+	// The noise temperature is referred to the antenna terminals, so it
+	// already accounts for the signal processing chains. Their gain amplifies
+	// the noise as much as the signal and does not improve G/T.
+	receiver := Receiver{
+		AntennaGainDBi:          rx.GetAntenna().GetGainDb(),
+		SystemNoiseTemperatureK: temperature,
+	}
+	for i, signal := range rx.GetReceiver().GetSignals() {
+		if signal.GetSignal().GetCenterFrequencyHz() <= 0 || signal.GetSignal().GetBandwidthHz() <= 0 {
+			return nil, nil, fmt.Errorf("linkbudget: receive_signal_chain.receiver.signals[%d] requires a center frequency and bandwidth", i)
+		}
+		downlinks = append(downlinks, Link{
+			Transmitter: terminal.Transmitter,
+			Receiver:    receiver,
+			FrequencyHz: float64(signal.GetSignal().GetCenterFrequencyHz()),
+			BandwidthHz: float64(signal.GetSignal().GetBandwidthHz()),
+			Modulation:  downlinkModulation,
+		})
+	}
+	return uplinks, downlinks, nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package linkbudget computes the budget of a link between a transmitter and
+// a receiver: the EIRP of the transmitter, the free-space path loss, the G/T of
+// the receiver, the carrier-to-noise density C/N0, the achievable Eb/N0 and the
+// margin over the Eb/N0 which the modulation requires.
+//
+// The model only accounts for free-space propagation and lumped losses, and
+// treats optical links like radio links with very high antenna gains. It is
+// meant for contact planning, not for the design of a link.
+package linkbudget
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/outernetcouncil/federation/pkg/go/orbit"
+)
+
+const (
+	// SpeedOfLight is the speed of light in vacuum in m/s.
+	SpeedOfLight = 299792458.0
+	// BoltzmannDBW is the Boltzmann constant in dBW/K/Hz.
+	BoltzmannDBW = -228.5991
+)
+
+// DB returns x in decibels.
+func DB(x float64) float64 {
+	return 10 * math.Log10(x)
+}
+
+// FreeSpacePathLossDB returns the free-space path loss over the distance at the
+// frequency.
+func FreeSpacePathLossDB(distanceM, frequencyHz float64) float64 {
+	return 20 * math.Log10(4*math.Pi*distanceM*frequencyHz/SpeedOfLight)
+}
+
+// Transmitter is the transmitting end of a link.
+type Transmitter struct {
+	// PowerDBW is the output power of the transmitter.
+	PowerDBW float64
+	// LossDB is the loss between the transmitter and the antenna.
+	LossDB float64
+	// AntennaGainDBi is the gain of the antenna over an isotropic antenna.
+	AntennaGainDBi float64
+}
+
+// EIRPDBW returns the effective isotropic radiated power.
+func (t Transmitter) EIRPDBW() float64 {
+	return t.PowerDBW - t.LossDB + t.AntennaGainDBi
+}
+
+// Receiver is the receiving end of a link.
+type Receiver struct {
+	// AntennaGainDBi is the gain of the antenna over an isotropic antenna.
+	AntennaGainDBi float64
+	// LossDB is the loss between the antenna and the receiver.
+	LossDB float64
+	// SystemNoiseTemperatureK is the noise temperature of the receiving system
+	// referred to the antenna.
+	SystemNoiseTemperatureK float64
+}
+
+// GOverTDBPerK returns the figure of merit G/T of the receiver.
+func (r Receiver) GOverTDBPerK() float64 {
+	return r.AntennaGainDBi - r.LossDB - DB(r.SystemNoiseTemperatureK)
+}
+
+// Modulation is a modulation scheme.
+type Modulation struct {
+	Name string
+	// BitsPerSymbol is the number of bits which a symbol carries.
+	BitsPerSymbol float64
+	// RequiredEbN0DB is the Eb/N0 required for a bit error rate of 1e-6
+	// without forward error correction.
+	RequiredEbN0DB float64
+}
+
+// Modulations are the modulation schemes with a known required Eb/N0.
+var Modulations = []Modulation{
+	{Name: "BPSK", BitsPerSymbol: 1, RequiredEbN0DB: 10.5},
+	{Name: "QPSK", BitsPerSymbol: 2, RequiredEbN0DB: 10.5},
+	{Name: "OOK", BitsPerSymbol: 1, RequiredEbN0DB: 13.5},
+	{Name: "8PSK", BitsPerSymbol: 3, RequiredEbN0DB: 14},
+	{Name: "16QAM", BitsPerSymbol: 4, RequiredEbN0DB: 14.4},
+	{Name: "16APSK", BitsPerSymbol: 4, RequiredEbN0DB: 14.8},
+	{Name: "32APSK", BitsPerSymbol: 5, RequiredEbN0DB: 17.4},
+	{Name: "64QAM", BitsPerSymbol: 6, RequiredEbN0DB: 18.8},
+}
+
+// LookupModulation returns the modulation scheme with the name, ignoring case
+// and dashes, e.g. "16-QAM".
+func LookupModulation(name string) (Modulation, bool) {
+	name = strings.ReplaceAll(strings.ToUpper(name), "-", "")
+	for _, m := range Modulations {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Modulation{}, false
+}
+
+// MostRobustModulation returns the known modulation scheme which both the
+// modulator and the demodulator support and which has the highest margin in a
+// given bandwidth. ok is false if there is no such scheme.
+func MostRobustModulation(modulatorSchemes, demodulatorSchemes []string) (m Modulation, ok bool) {
+	demodulates := make(map[string]bool, len(demodulatorSchemes))
+	for _, name := range demodulatorSchemes {
+		if d, known := LookupModulation(name); known {
+			demodulates[d.Name] = true
+		}
+	}
+	for _, name := range modulatorSchemes {
+		candidate, known := LookupModulation(name)
+		if !known || !demodulates[candidate.Name] {
+			continue
+		}
+		// The margin in a bandwidth decreases with the data rate and the
+		// required Eb/N0.
+		if !ok || candidate.cost() < m.cost() {
+			m, ok = candidate, true
+		}
+	}
+	return m, ok
+}
+
+func (m Modulation) cost() float64 {
+	return DB(m.BitsPerSymbol) + m.RequiredEbN0DB
+}
+
+// Link is a one-way link from a transmitter to a receiver.
+type Link struct {
+	Transmitter Transmitter
+	Receiver    Receiver
+	FrequencyHz float64
+	// BandwidthHz is the bandwidth of the signal, which is used as the symbol
+	// rate.
+	BandwidthHz float64
+	Modulation  Modulation
+	// OtherLossesDB are the losses besides the free-space path loss, e.g. of
+	// the atmosphere, pointing and polarization.
+	OtherLossesDB float64
+}
+
+// DataRateBps returns the data rate of the link.
+func (l Link) DataRateBps() float64 {
+	return l.BandwidthHz * l.Modulation.BitsPerSymbol
+}
+
+// Budget is the budget of a link at a distance.
+type Budget struct {
+	DistanceM    float64
+	EIRPDBW      float64
+	PathLossDB   float64
+	GOverTDBPerK float64
+	CN0DBHz      float64
+	EbN0DB       float64
+	// MarginDB is the margin of the achievable Eb/N0 over the Eb/N0 which the
+	// modulation requires.
+	MarginDB float64
+}
+
+// At returns the budget of the link over the distance.
+func (l Link) At(distanceM float64) Budget {
+	b := Budget{
+		DistanceM:    distanceM,
+		EIRPDBW:      l.Transmitter.EIRPDBW(),
+		PathLossDB:   FreeSpacePathLossDB(distanceM, l.FrequencyHz),
+		GOverTDBPerK: l.Receiver.GOverTDBPerK(),
+	}
+	b.CN0DBHz = b.EIRPDBW - b.PathLossDB - l.OtherLossesDB + b.GOverTDBPerK - BoltzmannDBW
+	b.EbN0DB = b.CN0DBHz - DB(l.DataRateBps())
+	b.MarginDB = b.EbN0DB - l.Modulation.RequiredEbN0DB
+	return b
+}
+
+// Between returns the budget of the link from the transmitter at a to the
+// receiver at b at t.
+func (l Link) Between(t time.Time, a, b orbit.Propagator) (Budget, error) {
+	pa, err := a.Position(t)
+	if err != nil {
+		return Budget{}, err
+	}
+	pb, err := b.Position(t)
+	if err != nil {
+		return Budget{}, err
+	}
+	return l.At(pa.Sub(pb).Norm()), nil
+}
+
+// MinMarginDB returns the minimum margin of the link between a and b within
+// [start, end], sampled every step and at end.
+func (l Link) MinMarginDB(start, end time.Time, step time.Duration, a, b orbit.Propagator) (float64, error) {
+	if step <= 0 {
+		step = orbit.DefaultStep
+	}
+	margin := math.Inf(1)
+	for t := start; ; t = t.Add(step) {
+		if t.After(end) {
+			t = end
+		}
+		budget, err := l.Between(t, a, b)
+		if err != nil {
+			return 0, err
+		}
+		margin = math.Min(margin, budget.MarginDB)
+		if !t.Before(end) {
+			return margin, nil
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linkbudget
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"github.com/outernetcouncil/federation/pkg/go/orbit"
+	"google.golang.org/protobuf/proto"
+	"outernetcouncil.org/nmts/v1/proto/ek/physical"
+)
+
+func TestFreeSpacePathLossDB(t *testing.T) {
+	for _, tc := range []struct {
+		distanceM, frequencyHz, want float64
+	}{
+		{distanceM: 1e3, frequencyHz: 1e9, want: 92.45},
+		{distanceM: 38000e3, frequencyHz: 12e9, want: 205.63},
+		{distanceM: 1000e3, frequencyHz: 193.4e12, want: 258.18},
+	} {
+		if got := FreeSpacePathLossDB(tc.distanceM, tc.frequencyHz); math.Abs(got-tc.want) > 0.01 {
+			t.Errorf("FreeSpacePathLossDB(%v, %v) = %v, want %v", tc.distanceM, tc.frequencyHz, got, tc.want)
+		}
+	}
+}
+
+func TestLink_At(t *testing.T) {
+	// A geostationary Ku-band downlink.
+	link := Link{
+		Transmitter:   Transmitter{PowerDBW: 20, LossDB: 1, AntennaGainDBi: 31},
+		Receiver:      Receiver{AntennaGainDBi: 45, LossDB: 0.5, SystemNoiseTemperatureK: 150},
+		FrequencyHz:   12e9,
+		BandwidthHz:   36e6,
+		Modulation:    Modulation{Name: "QPSK", BitsPerSymbol: 2, RequiredEbN0DB: 10.5},
+		OtherLossesDB: 2,
+	}
+	want := Budget{
+		DistanceM:    38000e3,
+		EIRPDBW:      50,
+		PathLossDB:   205.63,
+		GOverTDBPerK: 22.74,
+		CN0DBHz:      93.71,
+		EbN0DB:       15.14,
+		MarginDB:     4.64,
+	}
+	if diff := cmp.Diff(want, link.At(38000e3), cmpopts.EquateApprox(0, 0.01)); diff != "" {
+		t.Errorf("At() mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := link.DataRateBps(), 72e6; got != want {
+		t.Errorf("DataRateBps() = %v, want %v", got, want)
+	}
+
+	// Over fixed positions, the margin is constant.
+	a, b := orbit.Fixed{X: 42164e3}, orbit.Fixed{X: 42164e3 - 38000e3}
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	margin, err := link.MinMarginDB(start, start.Add(time.Hour), time.Minute, a, b)
+	if err != nil {
+		t.Fatalf("MinMarginDB failed: %v", err)
+	}
+	if math.Abs(margin-want.MarginDB) > 0.01 {
+		t.Errorf("MinMarginDB() = %v, want %v", margin, want.MarginDB)
+	}
+}
+
+func TestMostRobustModulation(t *testing.T) {
+	for _, tc := range []struct {
+		modulator, demodulator []string
+		want                   string
+		wantOK                 bool
+	}{
+		{modulator: []string{"QPSK", "8PSK"}, demodulator: []string{"8-psk", "qpsk", "bpsk"}, want: "QPSK", wantOK: true},
+		{modulator: []string{"bpsk", "qpsk"}, demodulator: []string{"QPSK", "BPSK"}, want: "BPSK", wantOK: true},
+		{modulator: []string{"64QAM", "unknown"}, demodulator: []string{"unknown", "64-QAM"}, want: "64QAM", wantOK: true},
+		{modulator: []string{"QPSK"}, demodulator: []string{"BPSK"}},
+		{modulator: []string{"unknown"}, demodulator: []string{"unknown"}},
+		{},
+	} {
+		got, ok := MostRobustModulation(tc.modulator, tc.demodulator)
+		if ok != tc.wantOK || got.Name != tc.want {
+			t.Errorf("MostRobustModulation(%q, %q) = %v, %v, want %q, %v", tc.modulator, tc.demodulator, got.Name, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func newTransceiver() *pb.Transceiver {
+	return &pb.Transceiver{
+		TransmitSignalChain: &pb.TransmitSignalChain{
+			Modulator:              &physical.Modulator{ModulationSchemes: []string{"QPSK"}},
+			SignalProcessingChains: []*physical.SignalProcessingChain{{GainDb: -1}},
+			Transmitter: &physical.Transmitter{Signals: []*physical.TransmitSignal{
+				{Signal: &physical.Signal{CenterFrequencyHz: 14e9, BandwidthHz: 10e6}, PowerDbw: 10},
+			}},
+			Antenna: &physical.Antenna{GainDb: 40},
+		},
+		ReceiveSignalChain: &pb.ReceiveSignalChain{
+			Demodulator: &physical.Demodulator{ModulationSchemes: []string{"BPSK", "QPSK"}},
+			SignalProcessingChains: []*physical.SignalProcessingChain{
+				{GainDb: 30, NoiseFigureDb: 2},
+				{GainDb: 10, NoiseFigureDb: 10},
+			},
+			Receiver: &physical.Receiver{Signals: []*physical.ReceiveSignal{
+				{Signal: &physical.Signal{CenterFrequencyHz: 12e9, BandwidthHz: 20e6}},
+				{Signal: &physical.Signal{CenterFrequencyHz: 11e9, BandwidthHz: 40e6}},
+			}},
+			Antenna: &physical.Antenna{GainDb: 38},
+		},
+	}
+}
+
+func TestLinks(t *testing.T) {
+	terminal := Terminal{
+		Transmitter:       Transmitter{PowerDBW: 13, AntennaGainDBi: 30},
+		Receiver:          Receiver{AntennaGainDBi: 30, SystemNoiseTemperatureK: 500},
+		ModulationSchemes: []string{"BPSK", "QPSK", "8PSK"},
+	}
+	uplinks, downlinks, err := Links(newTransceiver(), terminal)
+	if err != nil {
+		t.Fatalf("Links failed: %v", err)
+	}
+	qpsk, _ := LookupModulation("QPSK")
+	bpsk, _ := LookupModulation("BPSK")
+	// The second stage adds 290 K * 9 / 1000 of noise.
+	temperature := 290 + 290*(math.Pow(10, 0.2)-1) + 290*9/1000.0
+	wantUplinks := []Link{{
+		Transmitter: Transmitter{PowerDBW: 10, LossDB: 1, AntennaGainDBi: 40},
+		Receiver:    terminal.Receiver,
+		FrequencyHz: 14e9,
+		BandwidthHz: 10e6,
+		Modulation:  qpsk,
+	}}
+	// The gain of the chains does not improve G/T.
+	receiver := Receiver{AntennaGainDBi: 38, SystemNoiseTemperatureK: temperature}
+	wantDownlinks := []Link{
+		{Transmitter: terminal.Transmitter, Receiver: receiver, FrequencyHz: 12e9, BandwidthHz: 20e6, Modulation: bpsk},
+		{Transmitter: terminal.Transmitter, Receiver: receiver, FrequencyHz: 11e9, BandwidthHz: 40e6, Modulation: bpsk},
+	}
+	if diff := cmp.Diff(wantUplinks, uplinks, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Links() uplinks mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantDownlinks, downlinks, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Links() downlinks mismatch (-want +got):\n%s", diff)
+	}
+
+	// A declared noise temperature takes precedence over the noise figures.
+	transceiver := newTransceiver()
+	transceiver.ReceiveSignalChain.Receiver.SystemNoiseTemperatureK = 200
+	if _, downlinks, _ := Links(transceiver, terminal); downlinks[0].Receiver.SystemNoiseTemperatureK != 200 {
+		t.Errorf("Links() noise temperature = %v, want 200", downlinks[0].Receiver.SystemNoiseTemperatureK)
+	}
+}
+
+func TestLinks_Errors(t *testing.T) {
+	terminal := Terminal{ModulationSchemes: []string{"QPSK"}}
+	for name, tc := range map[string]struct {
+		modify  func(*pb.Transceiver)
+		wantErr error
+	}{
+		"no transmit signals": {modify: func(tr *pb.Transceiver) { tr.TransmitSignalChain.Transmitter = nil }},
+		"no receive signals":  {modify: func(tr *pb.Transceiver) { tr.ReceiveSignalChain.Receiver.Signals = nil }},
+		"no noise temperature": {modify: func(tr *pb.Transceiver) {
+			tr.ReceiveSignalChain.SignalProcessingChains = nil
+		}},
+		"no bandwidth": {modify: func(tr *pb.Transceiver) {
+			tr.TransmitSignalChain.Transmitter.Signals[0].Signal.BandwidthHz = 0
+		}},
+		"no common uplink modulation": {
+			modify:  func(tr *pb.Transceiver) { tr.TransmitSignalChain.Modulator.ModulationSchemes = []string{"BPSK"} },
+			wantErr: ErrNoCommonModulation,
+		},
+		"no common downlink modulation": {
+			modify:  func(tr *pb.Transceiver) { tr.ReceiveSignalChain.Demodulator = nil },
+			wantErr: ErrNoCommonModulation,
+		},
+	} {
+		transceiver := proto.Clone(newTransceiver()).(*pb.Transceiver)
+		tc.modify(transceiver)
+		_, _, err := Links(transceiver, terminal)
+		if err == nil {
+			t.Errorf("Links(%s) succeeded, want error", name)
+		}
+		if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+			t.Errorf("Links(%s) returned %v, want %v", name, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package motion evaluates the nmts geophys.Motion of targets and transceiver
+// platforms, e.g. to compute geometry, pointing and Doppler shifts.
+//
+// Every supported variant of a Motion is turned into a function of time that
+// returns the position and velocity in the Earth-centered, Earth-fixed (ECEF)
+// frame or in an Earth-centered inertial (ECI) frame. The inertial frame of
+// two-line element sets is TEME, which is treated as the same frame as the
+// ECI frame of Keplerian elements and state vector tables; see the orbit
+// package for the limits of this approximation.
+package motion
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/outernetcouncil/federation/pkg/go/orbit"
+	"outernetcouncil.org/nmts/v1/proto/types/geophys"
+)
+
+// ErrOutOfRange is returned when a motion is evaluated outside of the interval
+// covered by its state vector table.
+var ErrOutOfRange = errors.New("motion: time is outside of the state vector table")
+
+// State is a position in meters and a velocity in meters per second.
+type State struct {
+	Position orbit.Vector
+	Velocity orbit.Vector
+}
+
+// Evaluator evaluates a motion at any time.
+type Evaluator struct {
+	// fixed is set if the motion is fixed in the ECEF frame.
+	fixed bool
+	// inertial is set if evaluate returns states in the ECI frame instead of
+	// the ECEF frame.
+	inertial bool
+	evaluate func(t time.Time) (State, error)
+	// start and end bound the times at which the motion can be evaluated. They
+	// are zero if the motion is unbounded.
+	start, end time.Time
+}
+
+// New returns an evaluator of the motion. It fails if the motion is unset, of
+// an unsupported type or invalid.
+func New(motion *geophys.Motion) (*Evaluator, error) {
+	switch m := motion.GetType().(type) {
+	case *geophys.Motion_GeodeticWgs84:
+		g := m.GeodeticWgs84
+		return newFixed(orbit.Geodetic(g.LatitudeDeg, g.LongitudeDeg, g.HeightWgs84M)), nil
+	case *geophys.Motion_EcefFixed:
+		c := m.EcefFixed
+		return newFixed(orbit.Vector{X: c.XM, Y: c.YM, Z: c.ZM}), nil
+	case *geophys.Motion_TwoLineElementSet:
+		return newTwoLineElementSet(m.TwoLineElementSet)
+	case *geophys.Motion_KeplerianElements:
+		return newKeplerian(m.KeplerianElements)
+	case *geophys.Motion_StateVectorTable:
+		return newStateVectorTable(m.StateVectorTable)
+	case nil:
+		return nil, errors.New("motion: motion is required")
+	default:
+		return nil, fmt.Errorf("motion: motion of type %T is not supported", m)
+	}
+}
+
+func newFixed(position orbit.Vector) *Evaluator {
+	return &Evaluator{
+		fixed: true,
+		evaluate: func(time.Time) (State, error) {
+			return State{Position: position}, nil
+		},
+	}
+}
+
+func newTwoLineElementSet(tle *geophys.TwoLineElementSet) (*Evaluator, error) {
+	sgp4, err := orbit.NewSGP4(tle.Line1, tle.Line2)
+	if err != nil {
+		return nil, err
+	}
+	return &Evaluator{
+		inertial: true,
+		evaluate: func(t time.Time) (State, error) {
+			position, velocity, err := sgp4.PropagateTEME(t)
+			return State{Position: position, Velocity: velocity}, err
+		},
+	}, nil
+}
+
+func newKeplerian(elements *geophys.KeplerianElements) (*Evaluator, error) {
+	if elements.Epoch == nil {
+		return nil, errors.New("motion: the epoch of Keplerian elements is required")
+	}
+	k := orbit.Keplerian{
+		SemimajorAxisM:                   elements.SemimajorAxisM,
+		Eccentricity:                     elements.Eccentricity,
+		InclinationDeg:                   elements.InclinationDeg,
+		ArgumentOfPeriapsisDeg:           elements.ArgumentOfPeriapsisDeg,
+		RightAscensionOfAscendingNodeDeg: elements.RightAscensionOfAscendingNodeDeg,
+		TrueAnomalyDeg:                   elements.TrueAnomalyDeg,
+		Epoch:                            elements.Epoch.AsTime(),
+	}
+	if err := k.Validate(); err != nil {
+		return nil, err
+	}
+	return &Evaluator{
+		inertial: true,
+		evaluate: func(t time.Time) (State, error) {
+			position, velocity, err := k.PropagateInertial(t)
+			return State{Position: position, Velocity: velocity}, err
+		},
+	}, nil
+}
+
+// Fixed reports whether the motion is fixed in the ECEF frame, e.g. of a ground
+// terminal.
+func (e *Evaluator) Fixed() bool {
+	return e.fixed
+}
+
+// Span returns the interval in which the motion can be evaluated. ok is false
+// if the motion can be evaluated at any time.
+func (e *Evaluator) Span() (start, end time.Time, ok bool) {
+	return e.start, e.end, !e.start.IsZero()
+}
+
+// ECEF returns the state in the ECEF frame at t.
+func (e *Evaluator) ECEF(t time.Time) (State, error) {
+	s, err := e.evaluate(t)
+	if err != nil || !e.inertial {
+		return s, err
+	}
+	s.Position, s.Velocity = orbit.InertialStateToECEF(s.Position, s.Velocity, t)
+	return s, nil
+}
+
+// ECI returns the state in the ECI frame at t.
+func (e *Evaluator) ECI(t time.Time) (State, error) {
+	s, err := e.evaluate(t)
+	if err != nil || e.inertial {
+		return s, err
+	}
+	s.Position, s.Velocity = orbit.ECEFStateToInertial(s.Position, s.Velocity, t)
+	return s, nil
+}
+
+// Position returns the ECEF position at t, so that the evaluator can be used
+// as an orbit.Propagator.
+func (e *Evaluator) Position(t time.Time) (orbit.Vector, error) {
+	s, err := e.ECEF(t)
+	return s.Position, err
+}
+
+var _ orbit.Propagator = (*Evaluator)(nil)
+
+// sample is a state of a state vector table.
+type sample struct {
+	t time.Time
+	State
+	// hasVelocity is set if the table gives the velocity of the state.
+	hasVelocity bool
+}
+
+func newStateVectorTable(table *geophys.StateVectorTable) (*Evaluator, error) {
+	var inertial bool
+	switch table.ReferenceFrame {
+	case geophys.StateVectorTable_ECEF:
+	case geophys.StateVectorTable_ECI:
+		inertial = true
+	default:
+		return nil, fmt.Errorf("motion: reference frame %v of state vector table is not supported", table.ReferenceFrame)
+	}
+	if len(table.StateVectors) == 0 {
+		return nil, errors.New("motion: state vector table is empty")
+	}
+
+	samples := make([]sample, len(table.StateVectors))
+	for i, sv := range table.StateVectors {
+		if sv.Time == nil || sv.Position == nil {
+			return nil, fmt.Errorf("motion: state vector %d requires a time and a position", i)
+		}
+		samples[i] = sample{
+			t:     sv.Time.AsTime(),
+			State: State{Position: orbit.Vector{X: sv.Position.XM, Y: sv.Position.YM, Z: sv.Position.ZM}},
+		}
+		if v := sv.Velocity; v != nil {
+			samples[i].Velocity = orbit.Vector{X: v.XMps, Y: v.YMps, Z: v.ZMps}
+			samples[i].hasVelocity = true
+		}
+		if i > 0 && !samples[i].t.After(samples[i-1].t) {
+			return nil, fmt.Errorf("motion: state vector %d is not after state vector %d", i, i-1)
+		}
+	}
+	return &Evaluator{
+		inertial: inertial,
+		evaluate: func(t time.Time) (State, error) { return interpolate(samples, t) },
+		start:    samples[0].t,
+		end:      samples[len(samples)-1].t,
+	}, nil
+}
+
+// interpolate returns the state at t between the samples. If both neighbouring
+// samples have a velocity, the state is interpolated with a cubic Hermite
+// spline. Otherwise the position is interpolated linearly.
+func interpolate(samples []sample, t time.Time) (State, error) {
+	if t.Before(samples[0].t) || t.After(samples[len(samples)-1].t) {
+		return State{}, ErrOutOfRange
+	}
+	// i is the first sample at or after t.
+	i := sort.Search(len(samples), func(i int) bool { return !samples[i].t.Before(t) })
+	if samples[i].t.Equal(t) {
+		if samples[i].hasVelocity || len(samples) == 1 {
+			return samples[i].State, nil
+		}
+		// Fall through to compute the velocity from a neighbouring sample.
+		if i == 0 {
+			i = 1
+		}
+	}
+	a, b := samples[i-1], samples[i]
+	h := b.t.Sub(a.t).Seconds()
+	s := t.Sub(a.t).Seconds() / h
+
+	if !a.hasVelocity || !b.hasVelocity {
+		velocity := b.Position.Sub(a.Position).Scale(1 / h)
+		return State{Position: a.Position.Add(velocity.Scale(s * h)), Velocity: velocity}, nil
+	}
+
+	// Hermite basis functions and their derivatives with respect to s.
+	s2, s3 := s*s, s*s*s
+	h00, h10, h01, h11 := 2*s3-3*s2+1, s3-2*s2+s, -2*s3+3*s2, s3-s2
+	d00, d10, d01, d11 := 6*s2-6*s, 3*s2-4*s+1, -6*s2+6*s, 3*s2-2*s
+	position := a.Position.Scale(h00).
+		Add(a.Velocity.Scale(h10 * h)).
+		Add(b.Position.Scale(h01)).
+		Add(b.Velocity.Scale(h11 * h))
+	velocity := a.Position.Scale(d00 / h).
+		Add(a.Velocity.Scale(d10)).
+		Add(b.Position.Scale(d01 / h)).
+		Add(b.Velocity.Scale(d11))
+	return State{Position: position, Velocity: velocity}, nil
+}
@@ -0,0 +1,236 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package motion
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/outernetcouncil/federation/pkg/go/orbit"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"outernetcouncil.org/nmts/v1/proto/types/geophys"
+)
+
+var epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func near(a, b orbit.Vector, tolerance float64) bool {
+	return a.Sub(b).Norm() <= tolerance
+}
+
+func keplerian() *geophys.KeplerianElements {
+	return &geophys.KeplerianElements{
+		SemimajorAxisM: orbit.EarthRadiusM + 550e3,
+		Eccentricity:   0.001,
+		InclinationDeg: 53,
+		Epoch:          timestamppb.New(epoch),
+	}
+}
+
+// stateVectorTable samples the Keplerian orbit every step in the frame.
+func stateVectorTable(t *testing.T, frame geophys.StateVectorTable_ReferenceFrame, step time.Duration, n int, withVelocity bool) *geophys.StateVectorTable {
+	t.Helper()
+	e, err := New(&geophys.Motion{Type: &geophys.Motion_KeplerianElements{KeplerianElements: keplerian()}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	table := &geophys.StateVectorTable{ReferenceFrame: frame}
+	for i := range n {
+		at := epoch.Add(time.Duration(i) * step)
+		var s State
+		if frame == geophys.StateVectorTable_ECI {
+			s, err = e.ECI(at)
+		} else {
+			s, err = e.ECEF(at)
+		}
+		if err != nil {
+			t.Fatalf("evaluation failed: %v", err)
+		}
+		sv := &geophys.StateVector{
+			Time:     timestamppb.New(at),
+			Position: &geophys.Cartesian{XM: s.Position.X, YM: s.Position.Y, ZM: s.Position.Z},
+		}
+		if withVelocity {
+			sv.Velocity = &geophys.CartesianDot{XMps: s.Velocity.X, YMps: s.Velocity.Y, ZMps: s.Velocity.Z}
+		}
+		table.StateVectors = append(table.StateVectors, sv)
+	}
+	return table
+}
+
+func TestNew_Fixed(t *testing.T) {
+	for _, m := range []*geophys.Motion{
+		{Type: &geophys.Motion_GeodeticWgs84{GeodeticWgs84: &geophys.GeodeticWgs84{LatitudeDeg: 0, LongitudeDeg: 90}}},
+		{Type: &geophys.Motion_EcefFixed{EcefFixed: &geophys.Cartesian{YM: orbit.EarthRadiusM}}},
+	} {
+		e, err := New(m)
+		if err != nil {
+			t.Fatalf("New(%v) failed: %v", m, err)
+		}
+		if !e.Fixed() {
+			t.Errorf("New(%v).Fixed() = false, want true", m)
+		}
+		if _, _, ok := e.Span(); ok {
+			t.Errorf("New(%v).Span() is bounded, want unbounded", m)
+		}
+		want := orbit.Vector{Y: orbit.EarthRadiusM}
+		for _, at := range []time.Time{epoch, epoch.Add(6 * time.Hour)} {
+			s, err := e.ECEF(at)
+			if err != nil || !near(s.Position, want, 1e-6) || s.Velocity != (orbit.Vector{}) {
+				t.Errorf("ECEF(%v) = %v, %v, want %v at rest", at, s, err, want)
+			}
+			eci, err := e.ECI(at)
+			if err != nil {
+				t.Fatalf("ECI(%v) failed: %v", at, err)
+			}
+			// A point on the equator moves with the rotation of the Earth.
+			if got, want := eci.Velocity.Norm(), orbit.EarthRotationRate*orbit.EarthRadiusM; math.Abs(got-want) > 1e-6 {
+				t.Errorf("ECI(%v) speed = %v, want %v", at, got, want)
+			}
+			if got := eci.Position.Dot(eci.Velocity); math.Abs(got) > 1e-3 {
+				t.Errorf("ECI(%v) velocity is not perpendicular to the position", at)
+			}
+		}
+	}
+}
+
+func TestNew_TwoLineElementSet(t *testing.T) {
+	tle := &geophys.TwoLineElementSet{
+		Line1: "1 00005U 58002B   00179.78495062  .00000023  00000-0  28098-4 0  4753",
+		Line2: "2 00005  34.2682 348.7242 1859667 331.7664  19.3264 10.82419157413667",
+	}
+	e, err := New(&geophys.Motion{Type: &geophys.Motion_TwoLineElementSet{TwoLineElementSet: tle}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	sgp4, _ := orbit.NewSGP4(tle.Line1, tle.Line2)
+	at := sgp4.Epoch().Add(time.Hour)
+	position, velocity, _ := sgp4.PropagateTEME(at)
+
+	eci, err := e.ECI(at)
+	if err != nil || eci.Position != position || eci.Velocity != velocity {
+		t.Errorf("ECI() = %v, %v, want %v, %v", eci, err, position, velocity)
+	}
+	ecef, err := e.ECEF(at)
+	if err != nil {
+		t.Fatalf("ECEF() failed: %v", err)
+	}
+	if want := orbit.InertialToECEF(position, at); !near(ecef.Position, want, 1e-6) {
+		t.Errorf("ECEF() position = %v, want %v", ecef.Position, want)
+	}
+	if e.Fixed() {
+		t.Errorf("Fixed() = true, want false")
+	}
+}
+
+func TestNew_Keplerian(t *testing.T) {
+	e, err := New(&geophys.Motion{Type: &geophys.Motion_KeplerianElements{KeplerianElements: keplerian()}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	// The ECEF velocity is the derivative of the ECEF position.
+	at := epoch.Add(10 * time.Minute)
+	s, err := e.ECEF(at)
+	if err != nil {
+		t.Fatalf("ECEF() failed: %v", err)
+	}
+	before, _ := e.Position(at.Add(-time.Second / 2))
+	after, _ := e.Position(at.Add(time.Second / 2))
+	if difference := after.Sub(before); !near(s.Velocity, difference, 0.1) {
+		t.Errorf("ECEF() velocity = %v, want %v", s.Velocity, difference)
+	}
+}
+
+func TestNew_StateVectorTable(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		frame        geophys.StateVectorTable_ReferenceFrame
+		withVelocity bool
+		step         time.Duration
+		tolerance    float64
+	}{
+		{name: "ECI Hermite", frame: geophys.StateVectorTable_ECI, withVelocity: true, step: time.Minute, tolerance: 1},
+		{name: "ECEF Hermite", frame: geophys.StateVectorTable_ECEF, withVelocity: true, step: time.Minute, tolerance: 1},
+		{name: "ECEF linear", frame: geophys.StateVectorTable_ECEF, step: 10 * time.Second, tolerance: 100},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reference, _ := New(&geophys.Motion{Type: &geophys.Motion_KeplerianElements{KeplerianElements: keplerian()}})
+			table := stateVectorTable(t, tc.frame, tc.step, 11, tc.withVelocity)
+			e, err := New(&geophys.Motion{Type: &geophys.Motion_StateVectorTable{StateVectorTable: table}})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			start, end, ok := e.Span()
+			if !ok || !start.Equal(epoch) || !end.Equal(epoch.Add(10*tc.step)) {
+				t.Errorf("Span() = %v, %v, %v, want the table", start, end, ok)
+			}
+			for _, at := range []time.Time{epoch, epoch.Add(tc.step / 2), epoch.Add(7*tc.step + tc.step/3), end} {
+				got, err := e.ECEF(at)
+				if err != nil {
+					t.Fatalf("ECEF(%v) failed: %v", at, err)
+				}
+				want, _ := reference.ECEF(at)
+				if !near(got.Position, want.Position, tc.tolerance) {
+					t.Errorf("ECEF(%v) position = %v, want %v", at, got.Position, want.Position)
+				}
+				if !near(got.Velocity, want.Velocity, tc.tolerance) {
+					t.Errorf("ECEF(%v) velocity = %v, want %v", at, got.Velocity, want.Velocity)
+				}
+			}
+			for _, at := range []time.Time{epoch.Add(-time.Second), end.Add(time.Second)} {
+				if _, err := e.ECEF(at); !errors.Is(err, ErrOutOfRange) {
+					t.Errorf("ECEF(%v) returned %v, want ErrOutOfRange", at, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	at := timestamppb.New(epoch)
+	position := &geophys.Cartesian{XM: 1}
+	for name, m := range map[string]*geophys.Motion{
+		"nil":   nil,
+		"unset": {},
+		"invalid TLE": {Type: &geophys.Motion_TwoLineElementSet{TwoLineElementSet: &geophys.TwoLineElementSet{
+			Line1: "1", Line2: "2",
+		}}},
+		"Keplerian without epoch": {Type: &geophys.Motion_KeplerianElements{KeplerianElements: &geophys.KeplerianElements{
+			SemimajorAxisM: 7000e3,
+		}}},
+		"hyperbolic orbit": {Type: &geophys.Motion_KeplerianElements{KeplerianElements: &geophys.KeplerianElements{
+			SemimajorAxisM: 7000e3, Eccentricity: 1.5, Epoch: at,
+		}}},
+		"table without frame": {Type: &geophys.Motion_StateVectorTable{StateVectorTable: &geophys.StateVectorTable{
+			StateVectors: []*geophys.StateVector{{Time: at, Position: position}},
+		}}},
+		"empty table": {Type: &geophys.Motion_StateVectorTable{StateVectorTable: &geophys.StateVectorTable{
+			ReferenceFrame: geophys.StateVectorTable_ECEF,
+		}}},
+		"table without position": {Type: &geophys.Motion_StateVectorTable{StateVectorTable: &geophys.StateVectorTable{
+			ReferenceFrame: geophys.StateVectorTable_ECEF,
+			StateVectors:   []*geophys.StateVector{{Time: at}},
+		}}},
+		"unordered table": {Type: &geophys.Motion_StateVectorTable{StateVectorTable: &geophys.StateVectorTable{
+			ReferenceFrame: geophys.StateVectorTable_ECEF,
+			StateVectors:   []*geophys.StateVector{{Time: at, Position: position}, {Time: at, Position: position}},
+		}}},
+	} {
+		if _, err := New(m); err == nil {
+			t.Errorf("New(%s) succeeded, want error", name)
+		}
+	}
+}
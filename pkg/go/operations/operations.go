@@ -0,0 +1,254 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package operations runs the long-running operations of the Interconnect API
+// and serves them through the google.longrunning.Operations service, see
+// https://google.aip.dev/151.
+package operations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"github.com/outernetcouncil/federation/pkg/go/handler"
+	"github.com/outernetcouncil/federation/pkg/go/pagination"
+)
+
+// Func performs the work of an operation and returns its response. The
+// context is cancelled when the operation is cancelled.
+type Func func(ctx context.Context) (proto.Message, error)
+
+// Manager runs operations in the background and keeps their state in memory.
+// It implements longrunningpb.OperationsServer.
+type Manager struct {
+	longrunningpb.UnimplementedOperationsServer
+
+	mu         sync.Mutex
+	operations map[string]*operation
+	paginator  *pagination.Paginator
+}
+
+type operation struct {
+	// op and metadata are replaced rather than modified, so that they can be
+	// returned without copying.
+	op       *longrunningpb.Operation
+	metadata *pb.OperationMetadata
+	cancel   context.CancelFunc
+	// done is closed when the operation finishes.
+	done chan struct{}
+}
+
+// NewManager returns a Manager without operations.
+func NewManager() *Manager {
+	return &Manager{
+		operations: make(map[string]*operation),
+		paginator:  pagination.NewPaginator(nil),
+	}
+}
+
+// Start starts an operation which runs fn in the background and returns its
+// initial state. The verb is the name of the method that starts the operation
+// and the target is the name of the resource that it provisions.
+func (m *Manager) Start(verb, target string, fn Func) (*longrunningpb.Operation, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate operation name: %v", err)
+	}
+	// The operation outlives the RPC that starts it.
+	ctx, cancel := context.WithCancel(context.Background())
+	o := &operation{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	name := "operations/" + hex.EncodeToString(id)
+
+	m.mu.Lock()
+	m.setLocked(o, &pb.OperationMetadata{
+		CreateTime: timestamppb.Now(),
+		Target:     target,
+		Verb:       verb,
+	}, &longrunningpb.Operation{Name: name})
+	m.operations[name] = o
+	op := o.op
+	m.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		resp, err := fn(ctx)
+		m.finish(o, resp, err, ctx.Err() != nil)
+	}()
+
+	return op, nil
+}
+
+// setLocked replaces the state of the operation with op and the metadata.
+func (m *Manager) setLocked(o *operation, metadata *pb.OperationMetadata, op *longrunningpb.Operation) {
+	var err error
+	if op.Metadata, err = anypb.New(metadata); err != nil {
+		panic(err)
+	}
+	o.op = op
+	o.metadata = metadata
+}
+
+func (m *Manager) finish(o *operation, resp proto.Message, err error, cancelled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer close(o.done)
+
+	metadata := proto.Clone(o.metadata).(*pb.OperationMetadata)
+	metadata.EndTime = timestamppb.Now()
+	op := &longrunningpb.Operation{Name: o.op.Name, Done: true}
+	if err == nil {
+		var response *anypb.Any
+		if response, err = anypb.New(resp); err == nil {
+			op.Result = &longrunningpb.Operation_Response{Response: response}
+		} else {
+			err = status.Errorf(codes.Internal, "failed to marshal operation response: %v", err)
+		}
+	} else if cancelled {
+		err = status.Error(codes.Canceled, "operation was cancelled")
+	}
+	if err != nil {
+		op.Result = &longrunningpb.Operation_Error{Error: status.Convert(err).Proto()}
+	}
+	m.setLocked(o, metadata, op)
+}
+
+func (m *Manager) get(name string) (*operation, error) {
+	o, ok := m.operations[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "operation %q was not found", name)
+	}
+	return o, nil
+}
+
+// GetOperation returns the latest state of an operation.
+func (m *Manager) GetOperation(_ context.Context, request *longrunningpb.GetOperationRequest) (*longrunningpb.Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	o, err := m.get(request.Name)
+	if err != nil {
+		return nil, err
+	}
+	return o.op, nil
+}
+
+// ListOperations lists operations that match the filter, ordered by name.
+func (m *Manager) ListOperations(_ context.Context, request *longrunningpb.ListOperationsRequest) (*longrunningpb.ListOperationsResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	operations := make([]*longrunningpb.Operation, 0, len(m.operations))
+	for _, o := range m.operations {
+		operations = append(operations, o.op)
+	}
+	operations, err := handler.ApplyFilter(request.Filter, operations)
+	if err != nil {
+		return nil, err
+	}
+	operations, nextPageToken, err := pagination.Paginate(m.paginator, pagination.Request{
+		PageSize:  request.PageSize,
+		PageToken: request.PageToken,
+		Query:     []string{request.Filter},
+	}, operations, (*longrunningpb.Operation).GetName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &longrunningpb.ListOperationsResponse{
+		Operations:    operations,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+// CancelOperation requests cancellation of an operation. Cancellation is
+// asynchronous; the operation finishes with a CANCELLED error unless it
+// completes first.
+func (m *Manager) CancelOperation(_ context.Context, request *longrunningpb.CancelOperationRequest) (*emptypb.Empty, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	o, err := m.get(request.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !o.op.Done {
+		metadata := proto.Clone(o.metadata).(*pb.OperationMetadata)
+		metadata.RequestedCancellation = true
+		m.setLocked(o, metadata, &longrunningpb.Operation{Name: request.Name})
+		o.cancel()
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// DeleteOperation forgets an operation. It does not cancel the operation.
+func (m *Manager) DeleteOperation(_ context.Context, request *longrunningpb.DeleteOperationRequest) (*emptypb.Empty, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.get(request.Name); err != nil {
+		return nil, err
+	}
+	delete(m.operations, request.Name)
+	return &emptypb.Empty{}, nil
+}
+
+// WaitOperation waits until an operation is done or the timeout has elapsed,
+// and returns its latest state. Without a timeout, it waits until the
+// deadline of the RPC.
+func (m *Manager) WaitOperation(ctx context.Context, request *longrunningpb.WaitOperationRequest) (*longrunningpb.Operation, error) {
+	var timeout <-chan time.Time
+	if request.Timeout != nil {
+		if err := request.Timeout.CheckValid(); err != nil || request.Timeout.AsDuration() < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "timeout must be a non-negative duration")
+		}
+		timer := time.NewTimer(request.Timeout.AsDuration())
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	m.mu.Lock()
+	o, err := m.get(request.Name)
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-o.done:
+	case <-timeout:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return o.op, nil
+}
+
+var _ longrunningpb.OperationsServer = (*Manager)(nil)
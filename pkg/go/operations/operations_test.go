@@ -0,0 +1,191 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operations
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+func wait(t *testing.T, m *Manager, name string) *longrunningpb.Operation {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	op, err := m.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: name})
+	if err != nil {
+		t.Fatalf("WaitOperation failed: %v", err)
+	}
+	if !op.Done {
+		t.Fatalf("operation %s is not done", name)
+	}
+	return op
+}
+
+func metadata(t *testing.T, op *longrunningpb.Operation) *pb.OperationMetadata {
+	t.Helper()
+	metadata := &pb.OperationMetadata{}
+	if err := op.Metadata.UnmarshalTo(metadata); err != nil {
+		t.Fatalf("failed to unmarshal metadata: %v", err)
+	}
+	return metadata
+}
+
+func TestStart(t *testing.T) {
+	m := NewManager()
+	bearer := &pb.Bearer{Name: "bearers/a"}
+	op, err := m.Start("ProvisionBearer", "bearers/a", func(context.Context) (proto.Message, error) {
+		return bearer, nil
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	op = wait(t, m, op.Name)
+	got := &pb.Bearer{}
+	if err := op.GetResponse().UnmarshalTo(got); err != nil {
+		t.Fatalf("failed to unmarshal response of %v: %v", op, err)
+	}
+	if diff := cmp.Diff(bearer, got, protocmp.Transform()); diff != "" {
+		t.Errorf("response mismatch (-want +got):\n%s", diff)
+	}
+	md := metadata(t, op)
+	if md.Verb != "ProvisionBearer" || md.Target != "bearers/a" || md.CreateTime == nil || md.EndTime == nil {
+		t.Errorf("unexpected metadata %v", md)
+	}
+}
+
+func TestStart_Error(t *testing.T) {
+	m := NewManager()
+	op, err := m.Start("ProvisionBearer", "bearers/a", func(context.Context) (proto.Message, error) {
+		return nil, status.Error(codes.FailedPrecondition, "no contact window")
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	op = wait(t, m, op.Name)
+	if got := codes.Code(op.GetError().GetCode()); got != codes.FailedPrecondition {
+		t.Errorf("operation failed with %v, want FailedPrecondition", got)
+	}
+}
+
+func TestCancelOperation(t *testing.T) {
+	m := NewManager()
+	ctx := context.Background()
+	op, err := m.Start("ProvisionBearer", "bearers/a", func(ctx context.Context) (proto.Message, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if _, err := m.CancelOperation(ctx, &longrunningpb.CancelOperationRequest{Name: op.Name}); err != nil {
+		t.Fatalf("CancelOperation failed: %v", err)
+	}
+	op = wait(t, m, op.Name)
+	if got := codes.Code(op.GetError().GetCode()); got != codes.Canceled {
+		t.Errorf("operation failed with %v, want Canceled", got)
+	}
+	if !metadata(t, op).RequestedCancellation {
+		t.Errorf("requested_cancellation is not set")
+	}
+}
+
+func TestWaitOperation_Timeout(t *testing.T) {
+	m := NewManager()
+	release := make(chan struct{})
+	defer close(release)
+	op, err := m.Start("ProvisionBearer", "bearers/a", func(context.Context) (proto.Message, error) {
+		<-release
+		return &pb.Bearer{}, nil
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	op, err = m.WaitOperation(context.Background(), &longrunningpb.WaitOperationRequest{
+		Name:    op.Name,
+		Timeout: durationpb.New(10 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatalf("WaitOperation failed: %v", err)
+	}
+	if op.Done {
+		t.Errorf("operation is done before it was released")
+	}
+}
+
+func TestListOperations(t *testing.T) {
+	m := NewManager()
+	ctx := context.Background()
+	release := make(chan struct{})
+	defer close(release)
+
+	running, err := m.Start("ProvisionBearer", "bearers/a", func(context.Context) (proto.Message, error) {
+		<-release
+		return &pb.Bearer{}, nil
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	done, err := m.Start("ProvisionBearer", "bearers/b", func(context.Context) (proto.Message, error) {
+		return &pb.Bearer{}, nil
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	wait(t, m, done.Name)
+
+	for filter, want := range map[string][]string{
+		"":             {running.Name, done.Name},
+		"done = true":  {done.Name},
+		"done = false": {running.Name},
+	} {
+		resp, err := m.ListOperations(ctx, &longrunningpb.ListOperationsRequest{Name: "operations", Filter: filter})
+		if err != nil {
+			t.Fatalf("ListOperations(%q) failed: %v", filter, err)
+		}
+		var got []string
+		for _, op := range resp.Operations {
+			got = append(got, op.Name)
+		}
+		// Operations are ordered by name.
+		slices.Sort(want)
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("ListOperations(%q) mismatch (-want +got):\n%s", filter, diff)
+		}
+	}
+
+	if _, err := m.DeleteOperation(ctx, &longrunningpb.DeleteOperationRequest{Name: done.Name}); err != nil {
+		t.Fatalf("DeleteOperation failed: %v", err)
+	}
+	if _, err := m.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: done.Name}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOperation of deleted operation returned %v, want NotFound", err)
+	}
+}
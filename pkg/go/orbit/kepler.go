@@ -0,0 +1,102 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orbit
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Keplerian is an unperturbed two-body orbit around the Earth. The elements
+// are given in an Earth-centered inertial frame at the epoch.
+type Keplerian struct {
+	SemimajorAxisM                   float64
+	Eccentricity                     float64
+	InclinationDeg                   float64
+	ArgumentOfPeriapsisDeg           float64
+	RightAscensionOfAscendingNodeDeg float64
+	TrueAnomalyDeg                   float64
+	Epoch                            time.Time
+}
+
+// Validate reports whether the elements describe a closed orbit.
+func (k Keplerian) Validate() error {
+	if k.SemimajorAxisM <= 0 {
+		return errors.New("orbit: semimajor axis must be positive")
+	}
+	if k.Eccentricity < 0 || k.Eccentricity >= 1 {
+		return errors.New("orbit: eccentricity must be in [0, 1)")
+	}
+	return nil
+}
+
+// PropagateInertial returns the inertial position in meters and velocity in
+// meters per second at t.
+func (k Keplerian) PropagateInertial(t time.Time) (position, velocity Vector, err error) {
+	if err := k.Validate(); err != nil {
+		return Vector{}, Vector{}, err
+	}
+	a, e := k.SemimajorAxisM, k.Eccentricity
+	n := math.Sqrt(EarthMu / (a * a * a))
+
+	// Advance the mean anomaly from the epoch and solve Kepler's equation for
+	// the eccentric anomaly.
+	nu0 := k.TrueAnomalyDeg * math.Pi / 180
+	e0 := 2 * math.Atan2(math.Sqrt(1-e)*math.Sin(nu0/2), math.Sqrt(1+e)*math.Cos(nu0/2))
+	m := math.Mod(e0-e*math.Sin(e0)+n*t.Sub(k.Epoch).Seconds(), 2*math.Pi)
+	ea := m
+	if e > 0.8 {
+		ea = math.Pi
+	}
+	for range 50 {
+		d := (ea - e*math.Sin(ea) - m) / (1 - e*math.Cos(ea))
+		ea -= d
+		if math.Abs(d) < 1e-12 {
+			break
+		}
+	}
+
+	// Position and velocity in the perifocal frame.
+	sinE, cosE := math.Sincos(ea)
+	r := a * (1 - e*cosE)
+	b := a * math.Sqrt(1-e*e)
+	p := Vector{a * (cosE - e), b * sinE, 0}
+	v := Vector{-a * sinE, b * cosE, 0}.Scale(n * a / r)
+
+	return k.rotate(p), k.rotate(v), nil
+}
+
+// rotate rotates a vector from the perifocal to the inertial frame.
+func (k Keplerian) rotate(v Vector) Vector {
+	const deg2rad = math.Pi / 180
+	sinO, cosO := math.Sincos(k.RightAscensionOfAscendingNodeDeg * deg2rad)
+	sinI, cosI := math.Sincos(k.InclinationDeg * deg2rad)
+	sinW, cosW := math.Sincos(k.ArgumentOfPeriapsisDeg * deg2rad)
+	return Vector{
+		X: (cosO*cosW-sinO*sinW*cosI)*v.X + (-cosO*sinW-sinO*cosW*cosI)*v.Y,
+		Y: (sinO*cosW+cosO*sinW*cosI)*v.X + (-sinO*sinW+cosO*cosW*cosI)*v.Y,
+		Z: sinW*sinI*v.X + cosW*sinI*v.Y,
+	}
+}
+
+// Position returns the ECEF position at t.
+func (k Keplerian) Position(t time.Time) (Vector, error) {
+	position, _, err := k.PropagateInertial(t)
+	if err != nil {
+		return Vector{}, err
+	}
+	return InertialToECEF(position, t), nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package orbit propagates the positions of satellites and ground terminals
+// without any external data.
+//
+// Satellites are propagated with SGP4 from two-line element sets, or as
+// two-body Keplerian orbits. Positions are converted from the inertial frames
+// of the propagators to the Earth-centered, Earth-fixed (ECEF) frame by a
+// rotation through the Greenwich mean sidereal time. Precession, nutation and
+// polar motion are ignored, which is adequate for contact planning but not for
+// precise pointing.
+package orbit
+
+import (
+	"math"
+	"time"
+)
+
+// WGS84 ellipsoid and gravitational parameter.
+const (
+	// EarthRadiusM is the equatorial radius of the Earth in meters.
+	EarthRadiusM = 6378137.0
+	// EarthFlattening is the flattening of the WGS84 ellipsoid.
+	EarthFlattening = 1 / 298.257223563
+	// EarthMu is the gravitational parameter of the Earth in m³/s².
+	EarthMu = 3.986004418e14
+	// EarthRotationRate is the angular velocity of the Earth in rad/s.
+	EarthRotationRate = 7.2921158553e-5
+)
+
+// earthPolarRadiusM is the polar radius of the WGS84 ellipsoid in meters.
+const earthPolarRadiusM = EarthRadiusM * (1 - EarthFlattening)
+
+// earthEccentricitySq is the squared first eccentricity of the WGS84
+// ellipsoid.
+const earthEccentricitySq = EarthFlattening * (2 - EarthFlattening)
+
+// Vector is a position in meters or a velocity in meters per second.
+type Vector struct {
+	X, Y, Z float64
+}
+
+// Add returns v + w.
+func (v Vector) Add(w Vector) Vector {
+	return Vector{v.X + w.X, v.Y + w.Y, v.Z + w.Z}
+}
+
+// Sub returns v - w.
+func (v Vector) Sub(w Vector) Vector {
+	return Vector{v.X - w.X, v.Y - w.Y, v.Z - w.Z}
+}
+
+// Scale returns s * v.
+func (v Vector) Scale(s float64) Vector {
+	return Vector{s * v.X, s * v.Y, s * v.Z}
+}
+
+// Dot returns the dot product of v and w.
+func (v Vector) Dot(w Vector) float64 {
+	return v.X*w.X + v.Y*w.Y + v.Z*w.Z
+}
+
+// Norm returns the length of v.
+func (v Vector) Norm() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// Propagator returns the position of an object in the ECEF frame.
+type Propagator interface {
+	// Position returns the ECEF position at the given time in meters.
+	Position(t time.Time) (Vector, error)
+}
+
+// Fixed is a position which is fixed in the ECEF frame, e.g. of a ground
+// terminal.
+type Fixed Vector
+
+// Position returns the fixed position.
+func (f Fixed) Position(time.Time) (Vector, error) {
+	return Vector(f), nil
+}
+
+// Geodetic returns the ECEF position of a point given by its WGS84 latitude
+// and longitude in degrees and its height above the ellipsoid in meters.
+func Geodetic(latitudeDeg, longitudeDeg, heightM float64) Vector {
+	lat, lon := latitudeDeg*math.Pi/180, longitudeDeg*math.Pi/180
+	sinLat := math.Sin(lat)
+	n := EarthRadiusM / math.Sqrt(1-earthEccentricitySq*sinLat*sinLat)
+	return Vector{
+		X: (n + heightM) * math.Cos(lat) * math.Cos(lon),
+		Y: (n + heightM) * math.Cos(lat) * math.Sin(lon),
+		Z: (n*(1-earthEccentricitySq) + heightM) * sinLat,
+	}
+}
+
+// ToGeodetic returns the WGS84 latitude and longitude in degrees and the
+// height above the ellipsoid in meters of an ECEF position.
+func ToGeodetic(v Vector) (latitudeDeg, longitudeDeg, heightM float64) {
+	lon := math.Atan2(v.Y, v.X)
+	p := math.Hypot(v.X, v.Y)
+	// Bowring's iteration converges to below a millimeter in a few steps.
+	lat := math.Atan2(v.Z, p*(1-earthEccentricitySq))
+	var n float64
+	for range 5 {
+		sinLat := math.Sin(lat)
+		n = EarthRadiusM / math.Sqrt(1-earthEccentricitySq*sinLat*sinLat)
+		lat = math.Atan2(v.Z+earthEccentricitySq*n*sinLat, p)
+	}
+	if cosLat := math.Cos(lat); math.Abs(cosLat) > 1e-10 {
+		heightM = p/cosLat - n
+	} else {
+		heightM = math.Abs(v.Z) - earthPolarRadiusM
+	}
+	return lat * 180 / math.Pi, lon * 180 / math.Pi, heightM
+}
+
+// Up returns the unit normal of the WGS84 ellipsoid at an ECEF position,
+// which points to the zenith of an observer at that position.
+func Up(v Vector) Vector {
+	latDeg, lonDeg, _ := ToGeodetic(v)
+	lat, lon := latDeg*math.Pi/180, lonDeg*math.Pi/180
+	return Vector{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+}
+
+// ElevationDeg returns the elevation in degrees of the target above the local
+// horizon of an observer, both given by their ECEF positions.
+func ElevationDeg(observer, target Vector) float64 {
+	los := target.Sub(observer)
+	d := los.Norm()
+	if d == 0 {
+		return 90
+	}
+	sin := Up(observer).Dot(los) / d
+	return math.Asin(math.Max(-1, math.Min(1, sin))) * 180 / math.Pi
+}
+
+// Occluded reports whether the line of sight between two ECEF positions
+// passes through the Earth, which is modelled as the WGS84 ellipsoid.
+// Positions on the surface do not occlude themselves.
+func Occluded(a, b Vector) bool {
+	// The ellipsoid becomes a sphere with the equatorial radius when the z axis
+	// is stretched.
+	stretch := EarthRadiusM / earthPolarRadiusM
+	a.Z *= stretch
+	b.Z *= stretch
+	d := b.Sub(a)
+	dd := d.Dot(d)
+	if dd == 0 {
+		return false
+	}
+	t := -a.Dot(d) / dd
+	if t <= 0 || t >= 1 {
+		// The closest point to the center is one of the endpoints.
+		return false
+	}
+	return a.Add(d.Scale(t)).Norm() < EarthRadiusM
+}
+
+// julianDate returns the Julian date of t in UTC.
+func julianDate(t time.Time) float64 {
+	return float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+}
+
+// GMST returns the Greenwich mean sidereal time at t in radians, following the
+// IAU-82 model used with SGP4. UT1 is approximated by UTC.
+func GMST(t time.Time) float64 {
+	tut1 := (julianDate(t) - 2451545.0) / 36525
+	seconds := -6.2e-6*tut1*tut1*tut1 + 0.093104*tut1*tut1 + (876600*3600+8640184.812866)*tut1 + 67310.54841
+	gmst := math.Mod(seconds*math.Pi/180/240, 2*math.Pi)
+	if gmst < 0 {
+		gmst += 2 * math.Pi
+	}
+	return gmst
+}
+
+// InertialToECEF rotates a position from an Earth-centered inertial frame,
+// e.g. the TEME frame of SGP4, to the ECEF frame at t.
+func InertialToECEF(v Vector, t time.Time) Vector {
+	g := GMST(t)
+	sin, cos := math.Sincos(g)
+	return Vector{
+		X: cos*v.X + sin*v.Y,
+		Y: -sin*v.X + cos*v.Y,
+		Z: v.Z,
+	}
+}
+
+// ECEFToInertial rotates a position from the ECEF frame at t to an
+// Earth-centered inertial frame. It is the inverse of InertialToECEF.
+func ECEFToInertial(v Vector, t time.Time) Vector {
+	g := GMST(t)
+	sin, cos := math.Sincos(g)
+	return Vector{
+		X: cos*v.X - sin*v.Y,
+		Y: sin*v.X + cos*v.Y,
+		Z: v.Z,
+	}
+}
+
+// earthRotation returns the velocity of a point at v in the ECEF frame due to
+// the rotation of the Earth, as seen from an inertial frame.
+func earthRotation(v Vector) Vector {
+	return Vector{-EarthRotationRate * v.Y, EarthRotationRate * v.X, 0}
+}
+
+// InertialStateToECEF converts a position and velocity from an Earth-centered
+// inertial frame to the ECEF frame at t, accounting for the rotation of the
+// Earth.
+func InertialStateToECEF(position, velocity Vector, t time.Time) (Vector, Vector) {
+	ecef := InertialToECEF(position, t)
+	return ecef, InertialToECEF(velocity, t).Sub(earthRotation(ecef))
+}
+
+// ECEFStateToInertial converts a position and velocity from the ECEF frame at
+// t to an Earth-centered inertial frame. It is the inverse of
+// InertialStateToECEF.
+func ECEFStateToInertial(position, velocity Vector, t time.Time) (Vector, Vector) {
+	return ECEFToInertial(position, t), ECEFToInertial(velocity.Add(earthRotation(position)), t)
+}
@@ -0,0 +1,266 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orbit
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// The verification element set of Vallado et al., "Revisiting Spacetrack
+// Report #3".
+const (
+	valladoLine1 = "1 00005U 58002B   00179.78495062  .00000023  00000-0  28098-4 0  4753"
+	valladoLine2 = "2 00005  34.2682 348.7242 1859667 331.7664  19.3264 10.82419157413667"
+)
+
+func near(a, b Vector, tolerance float64) bool {
+	return a.Sub(b).Norm() <= tolerance
+}
+
+func TestSGP4(t *testing.T) {
+	s, err := NewSGP4(valladoLine1, valladoLine2)
+	if err != nil {
+		t.Fatalf("NewSGP4 failed: %v", err)
+	}
+	wantEpoch := time.Date(2000, time.June, 27, 18, 50, 19, 733568000, time.UTC)
+	if d := s.Epoch().Sub(wantEpoch); d.Abs() > time.Millisecond {
+		t.Errorf("Epoch() = %v, want %v", s.Epoch(), wantEpoch)
+	}
+
+	tests := []struct {
+		minutes      float64
+		wantPosition Vector
+		wantVelocity Vector
+	}{
+		{
+			minutes:      0,
+			wantPosition: Vector{7022465.29266, -1400082.96755, 39.95155},
+			wantVelocity: Vector{1893.841015, 6405.893759, 4534.807250},
+		},
+		{
+			minutes:      360,
+			wantPosition: Vector{-7154031.20202, -3783176.82504, -3536194.12294},
+			wantVelocity: Vector{4741.887409, -4151.817765, -2093.935425},
+		},
+	}
+	for _, tc := range tests {
+		at := s.Epoch().Add(time.Duration(tc.minutes * float64(time.Minute)))
+		position, velocity, err := s.PropagateTEME(at)
+		if err != nil {
+			t.Fatalf("PropagateTEME(+%vmin) failed: %v", tc.minutes, err)
+		}
+		if !near(position, tc.wantPosition, 1) {
+			t.Errorf("PropagateTEME(+%vmin) position = %v, want %v", tc.minutes, position, tc.wantPosition)
+		}
+		if !near(velocity, tc.wantVelocity, 1e-3) {
+			t.Errorf("PropagateTEME(+%vmin) velocity = %v, want %v", tc.minutes, velocity, tc.wantVelocity)
+		}
+	}
+}
+
+func TestSGP4_Errors(t *testing.T) {
+	// A geostationary satellite.
+	_, err := NewSGP4(
+		"1 19548U 88091B   24001.00000000 -.00000100  00000-0  00000+0 0  9990",
+		"2 19548  13.5000  12.3000 0004000 250.0000 110.0000  1.00270000 00000",
+	)
+	if !errors.Is(err, ErrDeepSpace) {
+		t.Errorf("NewSGP4(geostationary) returned %v, want ErrDeepSpace", err)
+	}
+
+	for _, lines := range [][2]string{
+		{"", ""},
+		{valladoLine2, valladoLine1},
+		{valladoLine1, "2 00005  34.2682"},
+		{valladoLine1, "2 00005  34.2682 348.7242 18596x7 331.7664  19.3264 10.82419157413667"},
+	} {
+		if _, err := NewSGP4(lines[0], lines[1]); err == nil {
+			t.Errorf("NewSGP4(%q, %q) succeeded, want error", lines[0], lines[1])
+		}
+	}
+}
+
+func TestKeplerian(t *testing.T) {
+	epoch := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	k := Keplerian{
+		SemimajorAxisM: 7000e3,
+		Eccentricity:   0,
+		InclinationDeg: 90,
+		Epoch:          epoch,
+	}
+	period := time.Duration(2 * math.Pi * math.Sqrt(7000e3*7000e3*7000e3/EarthMu) * float64(time.Second))
+
+	tests := []struct {
+		at   time.Time
+		want Vector
+	}{
+		{at: epoch, want: Vector{7000e3, 0, 0}},
+		{at: epoch.Add(period / 4), want: Vector{0, 0, 7000e3}},
+		{at: epoch.Add(period / 2), want: Vector{-7000e3, 0, 0}},
+		{at: epoch.Add(period), want: Vector{7000e3, 0, 0}},
+	}
+	for _, tc := range tests {
+		got, velocity, err := k.PropagateInertial(tc.at)
+		if err != nil {
+			t.Fatalf("PropagateInertial(%v) failed: %v", tc.at, err)
+		}
+		if !near(got, tc.want, 1) {
+			t.Errorf("PropagateInertial(%v) = %v, want %v", tc.at, got, tc.want)
+		}
+		if speed, want := velocity.Norm(), math.Sqrt(EarthMu/7000e3); math.Abs(speed-want) > 1e-3 {
+			t.Errorf("PropagateInertial(%v) speed = %v, want %v", tc.at, speed, want)
+		}
+	}
+
+	if _, err := (Keplerian{SemimajorAxisM: 7000e3, Eccentricity: 1}).Position(epoch); err == nil {
+		t.Errorf("Position() of an open orbit succeeded, want error")
+	}
+}
+
+func TestGeodetic(t *testing.T) {
+	for _, p := range [][3]float64{
+		{0, 0, 0},
+		{51.5, -0.1, 45},
+		{-33.9, 151.2, 1200},
+		{89.9, 10, 500e3},
+	} {
+		lat, lon, h := ToGeodetic(Geodetic(p[0], p[1], p[2]))
+		if math.Abs(lat-p[0]) > 1e-9 || math.Abs(lon-p[1]) > 1e-9 || math.Abs(h-p[2]) > 1e-3 {
+			t.Errorf("ToGeodetic(Geodetic(%v)) = (%v, %v, %v)", p, lat, lon, h)
+		}
+	}
+	if got, want := Geodetic(0, 90, 0), (Vector{0, EarthRadiusM, 0}); !near(got, want, 1e-6) {
+		t.Errorf("Geodetic(0, 90, 0) = %v, want %v", got, want)
+	}
+}
+
+func TestElevationAndOcclusion(t *testing.T) {
+	ground := Geodetic(0, 0, 0)
+	tests := []struct {
+		name          string
+		target        Vector
+		wantElevation float64
+		wantOccluded  bool
+	}{
+		{name: "zenith", target: Geodetic(0, 0, 500e3), wantElevation: 90},
+		{name: "horizon", target: Vector{EarthRadiusM, 1000e3, 0}, wantElevation: 0},
+		{name: "antipode", target: Geodetic(0, 180, 500e3), wantElevation: -90, wantOccluded: true},
+		{name: "below horizon", target: Geodetic(0, 30, 500e3), wantElevation: -6.99, wantOccluded: true},
+	}
+	for _, tc := range tests {
+		if got := ElevationDeg(ground, tc.target); math.Abs(got-tc.wantElevation) > 0.01 {
+			t.Errorf("%s: ElevationDeg() = %v, want %v", tc.name, got, tc.wantElevation)
+		}
+		if got := Occluded(ground, tc.target); got != tc.wantOccluded {
+			t.Errorf("%s: Occluded() = %v, want %v", tc.name, got, tc.wantOccluded)
+		}
+	}
+	// Two satellites on opposite sides of the Earth.
+	if !Occluded(Geodetic(0, 0, 500e3), Geodetic(0, 180, 500e3)) {
+		t.Errorf("Occluded() of opposite satellites = false, want true")
+	}
+	// Two geostationary satellites 90 degrees apart.
+	if Occluded(Geodetic(0, 0, 35786e3), Geodetic(0, 90, 35786e3)) {
+		t.Errorf("Occluded() of geostationary satellites = true, want false")
+	}
+}
+
+func TestInertialToECEF(t *testing.T) {
+	at := time.Date(2024, time.March, 20, 12, 0, 0, 0, time.UTC)
+	v := Vector{1, 2, 3}
+	if got := ECEFToInertial(InertialToECEF(v, at), at); !near(got, v, 1e-12) {
+		t.Errorf("ECEFToInertial(InertialToECEF(%v)) = %v", v, got)
+	}
+	position, velocity := Geodetic(0, 0, 0), Vector{}
+	inertialPosition, inertialVelocity := ECEFStateToInertial(position, velocity, at)
+	gotPosition, gotVelocity := InertialStateToECEF(inertialPosition, inertialVelocity, at)
+	if !near(gotPosition, position, 1e-6) || !near(gotVelocity, velocity, 1e-9) {
+		t.Errorf("InertialStateToECEF(ECEFStateToInertial(%v, %v)) = %v, %v", position, velocity, gotPosition, gotVelocity)
+	}
+	// A point on the equator moves eastwards at 465 m/s in the inertial frame.
+	if _, v := ECEFStateToInertial(position, velocity, at); math.Abs(v.Norm()-465.1) > 0.1 {
+		t.Errorf("ECEFStateToInertial() velocity = %v, want 465.1 m/s", v.Norm())
+	}
+	// The GMST at the J2000 epoch is 280.46 degrees.
+	j2000 := time.Date(2000, time.January, 1, 12, 0, 0, 0, time.UTC)
+	if got, want := GMST(j2000)*180/math.Pi, 280.46061837; math.Abs(got-want) > 1e-6 {
+		t.Errorf("GMST(J2000) = %v, want %v", got, want)
+	}
+}
+
+func TestVisibility_Windows(t *testing.T) {
+	epoch := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	// A circular polar orbit which passes over the ground terminal at the
+	// epoch. The inertial frame is aligned with the ECEF frame at the epoch.
+	gmst := GMST(epoch) * 180 / math.Pi
+	satellite := Keplerian{
+		SemimajorAxisM:                   EarthRadiusM + 550e3,
+		InclinationDeg:                   90,
+		RightAscensionOfAscendingNodeDeg: gmst,
+		Epoch:                            epoch,
+	}
+	v := &Visibility{
+		A: Endpoint{Propagator: Fixed(Geodetic(0, 0, 0)), ElevationMask: true, MinElevationDeg: 10},
+		B: Endpoint{Propagator: satellite},
+	}
+
+	windows, err := v.Windows(epoch.Add(-time.Hour), epoch.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Windows() failed: %v", err)
+	}
+	if len(windows) != 1 {
+		t.Fatalf("Windows() = %v, want a single pass", windows)
+	}
+	w := windows[0]
+	if !w.Start.Before(epoch) || !w.End.After(epoch) {
+		t.Errorf("Windows() = %v, want a pass around %v", w, epoch)
+	}
+	if d := w.End.Sub(w.Start); d < 5*time.Minute || d > 10*time.Minute {
+		t.Errorf("pass lasts %v, want 5-10 minutes", d)
+	}
+	// The elevation at the boundaries is the mask.
+	for _, at := range []time.Time{w.Start, w.End} {
+		p, _ := satellite.Position(at)
+		if got := ElevationDeg(Geodetic(0, 0, 0), p); math.Abs(got-10) > 0.1 {
+			t.Errorf("elevation at %v = %v, want 10", at, got)
+		}
+	}
+
+	// Without the mask, the pass is longer.
+	v.A.ElevationMask = false
+	unmasked, err := v.Windows(epoch.Add(-time.Hour), epoch.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Windows() failed: %v", err)
+	}
+	if len(unmasked) != 1 || !unmasked[0].Start.Before(w.Start) || !unmasked[0].End.After(w.End) {
+		t.Errorf("Windows() without mask = %v, want a pass containing %v", unmasked, w)
+	}
+
+	// Windows are clipped to the requested interval.
+	clipped, err := v.Windows(epoch, epoch.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Windows() failed: %v", err)
+	}
+	if len(clipped) != 1 || clipped[0] != (Interval{Start: epoch, End: epoch.Add(time.Minute)}) {
+		t.Errorf("Windows() = %v, want the whole interval", clipped)
+	}
+
+	if _, err := v.Windows(epoch, epoch); err == nil {
+		t.Errorf("Windows() of an empty interval succeeded, want error")
+	}
+}
@@ -0,0 +1,412 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orbit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WGS72 constants, which two-line element sets are fitted with.
+const (
+	wgs72RadiusKm = 6378.135
+	wgs72Mu       = 398600.8
+	j2            = 0.001082616
+	j3            = -0.00000253881
+	j4            = -0.00000165597
+	j3oj2         = j3 / j2
+)
+
+// xke is the square root of the gravitational parameter in Earth radii³/min².
+var xke = 60 / math.Sqrt(wgs72RadiusKm*wgs72RadiusKm*wgs72RadiusKm/wgs72Mu)
+
+// ErrDeepSpace is returned for two-line element sets of orbits with a period of
+// 225 minutes or more, which require the deep-space perturbations of SDP4.
+var ErrDeepSpace = errors.New("orbit: deep-space orbits are not supported by SGP4, use Keplerian elements instead")
+
+// ErrDecayed is returned when the propagated orbit intersects the Earth.
+var ErrDecayed = errors.New("orbit: satellite has decayed")
+
+// SGP4 propagates a near-Earth orbit given by a two-line element set, following
+// the revised SGP4 of Vallado et al., "Revisiting Spacetrack Report #3"
+// (AIAA 2006-6753).
+type SGP4 struct {
+	epoch time.Time
+
+	// Mean elements at epoch.
+	bstar, ecco, argpo, inclo, mo, nodeo, no float64
+
+	isimp                                          bool
+	aycof, con41, cc1, cc4, cc5, d2, d3, d4, delmo float64
+	eta, argpdot, omgcof, sinmao, t2cof, t3cof     float64
+	t4cof, t5cof, x1mth2, x7thm1, mdot, nodedot    float64
+	xlcof, xmcof, nodecf                           float64
+}
+
+// NewSGP4 parses a two-line element set and initializes the propagator.
+func NewSGP4(line1, line2 string) (*SGP4, error) {
+	s := &SGP4{}
+	if err := s.parse(line1, line2); err != nil {
+		return nil, err
+	}
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Epoch returns the epoch of the element set.
+func (s *SGP4) Epoch() time.Time {
+	return s.epoch
+}
+
+// field returns the columns [from, to] of a line, counting from one as in the
+// TLE format.
+func field(line string, from, to int) (string, error) {
+	if len(line) < to {
+		return "", fmt.Errorf("orbit: line %q is too short", line)
+	}
+	return strings.TrimSpace(line[from-1 : to]), nil
+}
+
+// parseFloat parses the columns [from, to] of a line as a float.
+func parseFloat(line string, from, to int) (float64, error) {
+	f, err := field(line, from, to)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseFloat(f, 64)
+	if err != nil {
+		return 0, fmt.Errorf("orbit: invalid field %q in columns %d-%d: %w", f, from, to, err)
+	}
+	return v, nil
+}
+
+// parseExponent parses the columns [from, to] of a line in the assumed decimal
+// point notation of the TLE format, e.g. " 28098-4" for 0.28098e-4.
+func parseExponent(line string, from, to int) (float64, error) {
+	f, err := field(line, from, to)
+	if err != nil {
+		return 0, err
+	}
+	if len(f) < 2 {
+		return 0, fmt.Errorf("orbit: invalid field %q in columns %d-%d", f, from, to)
+	}
+	mantissa, exponent := f[:len(f)-2], f[len(f)-2:]
+	sign := ""
+	if strings.HasPrefix(mantissa, "-") || strings.HasPrefix(mantissa, "+") {
+		sign, mantissa = mantissa[:1], mantissa[1:]
+	}
+	v, err := strconv.ParseFloat(sign+"0."+mantissa+"e"+exponent, 64)
+	if err != nil {
+		return 0, fmt.Errorf("orbit: invalid field %q in columns %d-%d: %w", f, from, to, err)
+	}
+	return v, nil
+}
+
+func (s *SGP4) parse(line1, line2 string) error {
+	line1, line2 = strings.TrimRight(line1, " \r\n"), strings.TrimRight(line2, " \r\n")
+	if !strings.HasPrefix(line1, "1 ") || !strings.HasPrefix(line2, "2 ") {
+		return errors.New("orbit: two-line element set must consist of line 1 and line 2")
+	}
+
+	year, err := parseFloat(line1, 19, 20)
+	if err != nil {
+		return err
+	}
+	days, err := parseFloat(line1, 21, 32)
+	if err != nil {
+		return err
+	}
+	if year < 57 {
+		year += 2000
+	} else {
+		year += 1900
+	}
+	s.epoch = time.Date(int(year), time.January, 1, 0, 0, 0, 0, time.UTC).
+		Add(time.Duration((days - 1) * float64(24*time.Hour)))
+	if s.bstar, err = parseExponent(line1, 54, 61); err != nil {
+		return err
+	}
+
+	if s.inclo, err = parseFloat(line2, 9, 16); err != nil {
+		return err
+	}
+	if s.nodeo, err = parseFloat(line2, 18, 25); err != nil {
+		return err
+	}
+	ecco, err := field(line2, 27, 33)
+	if err != nil {
+		return err
+	}
+	if s.ecco, err = strconv.ParseFloat("0."+ecco, 64); err != nil {
+		return fmt.Errorf("orbit: invalid eccentricity %q: %w", ecco, err)
+	}
+	if s.argpo, err = parseFloat(line2, 35, 42); err != nil {
+		return err
+	}
+	if s.mo, err = parseFloat(line2, 44, 51); err != nil {
+		return err
+	}
+	if s.no, err = parseFloat(line2, 53, 63); err != nil {
+		return err
+	}
+
+	const deg2rad = math.Pi / 180
+	s.inclo *= deg2rad
+	s.nodeo *= deg2rad
+	s.argpo *= deg2rad
+	s.mo *= deg2rad
+	// The mean motion is given in revolutions per day.
+	s.no *= 2 * math.Pi / 1440
+	return nil
+}
+
+// init computes the coefficients of the propagator, see sgp4init.
+func (s *SGP4) init() error {
+	const x2o3 = 2.0 / 3.0
+	ss := 78/wgs72RadiusKm + 1
+	qzms2t := math.Pow((120-78)/wgs72RadiusKm, 4)
+
+	// Recover the original mean motion and semimajor axis from the Kozai mean
+	// motion of the element set.
+	eccsq := s.ecco * s.ecco
+	omeosq := 1 - eccsq
+	rteosq := math.Sqrt(omeosq)
+	cosio := math.Cos(s.inclo)
+	cosio2 := cosio * cosio
+	ak := math.Pow(xke/s.no, x2o3)
+	d1 := 0.75 * j2 * (3*cosio2 - 1) / (rteosq * omeosq)
+	del := d1 / (ak * ak)
+	adel := ak * (1 - del*del - del*(1.0/3.0+134*del*del/81))
+	del = d1 / (adel * adel)
+	s.no /= 1 + del
+	if s.no <= 0 || omeosq <= 0 {
+		return errors.New("orbit: invalid mean motion or eccentricity")
+	}
+	if 2*math.Pi/s.no >= 225 {
+		return ErrDeepSpace
+	}
+
+	ao := math.Pow(xke/s.no, x2o3)
+	sinio := math.Sin(s.inclo)
+	po := ao * omeosq
+	con42 := 1 - 5*cosio2
+	s.con41 = -con42 - cosio2 - cosio2
+	posq := po * po
+	rp := ao * (1 - s.ecco)
+
+	// Perigees below 220 km use a simplified drag model.
+	s.isimp = rp < 220/wgs72RadiusKm+1
+	sfour := ss
+	qzms24 := qzms2t
+	if perige := (rp - 1) * wgs72RadiusKm; perige < 156 {
+		sfour = perige - 78
+		if perige < 98 {
+			sfour = 20
+		}
+		qzms24 = math.Pow((120-sfour)/wgs72RadiusKm, 4)
+		sfour = sfour/wgs72RadiusKm + 1
+	}
+	pinvsq := 1 / posq
+	tsi := 1 / (ao - sfour)
+	s.eta = ao * s.ecco * tsi
+	etasq := s.eta * s.eta
+	eeta := s.ecco * s.eta
+	psisq := math.Abs(1 - etasq)
+	coef := qzms24 * math.Pow(tsi, 4)
+	coef1 := coef / math.Pow(psisq, 3.5)
+	cc2 := coef1 * s.no * (ao*(1+1.5*etasq+eeta*(4+etasq)) +
+		0.375*j2*tsi/psisq*s.con41*(8+3*etasq*(8+etasq)))
+	s.cc1 = s.bstar * cc2
+	cc3 := 0.0
+	if s.ecco > 1e-4 {
+		cc3 = -2 * coef * tsi * j3oj2 * s.no * sinio / s.ecco
+	}
+	s.x1mth2 = 1 - cosio2
+	s.cc4 = 2 * s.no * coef1 * ao * omeosq * (s.eta*(2+0.5*etasq) + s.ecco*(0.5+2*etasq) -
+		j2*tsi/(ao*psisq)*(-3*s.con41*(1-2*eeta+etasq*(1.5-0.5*eeta))+
+			0.75*s.x1mth2*(2*etasq-eeta*(1+etasq))*math.Cos(2*s.argpo)))
+	s.cc5 = 2 * coef1 * ao * omeosq * (1 + 2.75*(etasq+eeta) + eeta*etasq)
+
+	cosio4 := cosio2 * cosio2
+	temp1 := 1.5 * j2 * pinvsq * s.no
+	temp2 := 0.5 * temp1 * j2 * pinvsq
+	temp3 := -0.46875 * j4 * pinvsq * pinvsq * s.no
+	s.mdot = s.no + 0.5*temp1*rteosq*s.con41 + 0.0625*temp2*rteosq*(13-78*cosio2+137*cosio4)
+	s.argpdot = -0.5*temp1*con42 + 0.0625*temp2*(7-114*cosio2+395*cosio4) + temp3*(3-36*cosio2+49*cosio4)
+	xhdot1 := -temp1 * cosio
+	s.nodedot = xhdot1 + (0.5*temp2*(4-19*cosio2)+2*temp3*(3-7*cosio2))*cosio
+	s.omgcof = s.bstar * cc3 * math.Cos(s.argpo)
+	if s.ecco > 1e-4 {
+		s.xmcof = -x2o3 * coef * s.bstar / eeta
+	}
+	s.nodecf = 3.5 * omeosq * xhdot1 * s.cc1
+	s.t2cof = 1.5 * s.cc1
+	if math.Abs(cosio+1) > 1.5e-12 {
+		s.xlcof = -0.25 * j3oj2 * sinio * (3 + 5*cosio) / (1 + cosio)
+	} else {
+		s.xlcof = -0.25 * j3oj2 * sinio * (3 + 5*cosio) / 1.5e-12
+	}
+	s.aycof = -0.5 * j3oj2 * sinio
+	s.delmo = math.Pow(1+s.eta*math.Cos(s.mo), 3)
+	s.sinmao = math.Sin(s.mo)
+	s.x7thm1 = 7*cosio2 - 1
+
+	if !s.isimp {
+		cc1sq := s.cc1 * s.cc1
+		s.d2 = 4 * ao * tsi * cc1sq
+		temp := s.d2 * tsi * s.cc1 / 3
+		s.d3 = (17*ao + sfour) * temp
+		s.d4 = 0.5 * temp * ao * tsi * (221*ao + 31*sfour) * s.cc1
+		s.t3cof = s.d2 + 2*cc1sq
+		s.t4cof = 0.25 * (3*s.d3 + s.cc1*(12*s.d2+10*cc1sq))
+		s.t5cof = 0.2 * (3*s.d4 + 12*s.cc1*s.d3 + 6*s.d2*s.d2 + 15*cc1sq*(2*s.d2+cc1sq))
+	}
+	return nil
+}
+
+// PropagateTEME returns the position in meters and the velocity in meters per
+// second in the true equator, mean equinox (TEME) frame at t.
+func (s *SGP4) PropagateTEME(t time.Time) (position, velocity Vector, err error) {
+	return s.propagate(t.Sub(s.epoch).Minutes())
+}
+
+// Position returns the ECEF position at t.
+func (s *SGP4) Position(t time.Time) (Vector, error) {
+	position, _, err := s.PropagateTEME(t)
+	if err != nil {
+		return Vector{}, err
+	}
+	return InertialToECEF(position, t), nil
+}
+
+// propagate returns the TEME position and velocity tsince minutes after the
+// epoch, see sgp4.
+func (s *SGP4) propagate(tsince float64) (position, velocity Vector, err error) {
+	const x2o3 = 2.0 / 3.0
+	vkmpersec := wgs72RadiusKm * xke / 60
+
+	// Secular gravity and atmospheric drag.
+	xmdf := s.mo + s.mdot*tsince
+	argpdf := s.argpo + s.argpdot*tsince
+	nodedf := s.nodeo + s.nodedot*tsince
+	argpm := argpdf
+	mm := xmdf
+	t2 := tsince * tsince
+	nodem := nodedf + s.nodecf*t2
+	tempa := 1 - s.cc1*tsince
+	tempe := s.bstar * s.cc4 * tsince
+	templ := s.t2cof * t2
+	if !s.isimp {
+		delomg := s.omgcof * tsince
+		delm := s.xmcof * (math.Pow(1+s.eta*math.Cos(xmdf), 3) - s.delmo)
+		temp := delomg + delm
+		mm = xmdf + temp
+		argpm = argpdf - temp
+		t3 := t2 * tsince
+		t4 := t3 * tsince
+		tempa = tempa - s.d2*t2 - s.d3*t3 - s.d4*t4
+		tempe += s.bstar * s.cc5 * (math.Sin(mm) - s.sinmao)
+		templ += s.t3cof*t3 + t4*(s.t4cof+tsince*s.t5cof)
+	}
+
+	am := math.Pow(xke/s.no, x2o3) * tempa * tempa
+	nm := xke / math.Pow(am, 1.5)
+	em := s.ecco - tempe
+	if em >= 1 || em < -0.001 || am < 0.95 {
+		return Vector{}, Vector{}, ErrDecayed
+	}
+	if em < 1e-6 {
+		em = 1e-6
+	}
+	mm += s.no * templ
+	xlm := mm + argpm + nodem
+	nodem = math.Mod(nodem, 2*math.Pi)
+	argpm = math.Mod(argpm, 2*math.Pi)
+	xlm = math.Mod(xlm, 2*math.Pi)
+	mm = math.Mod(xlm-argpm-nodem, 2*math.Pi)
+	sinip, cosip := math.Sincos(s.inclo)
+
+	// Long-period periodics.
+	axnl := em * math.Cos(argpm)
+	temp := 1 / (am * (1 - em*em))
+	aynl := em*math.Sin(argpm) + temp*s.aycof
+	xl := mm + argpm + nodem + temp*s.xlcof*axnl
+
+	// Solve Kepler's equation.
+	u := math.Mod(xl-nodem, 2*math.Pi)
+	eo1 := u
+	var sineo1, coseo1 float64
+	for range 10 {
+		sineo1, coseo1 = math.Sincos(eo1)
+		tem5 := (u - aynl*coseo1 + axnl*sineo1 - eo1) / (1 - coseo1*axnl - sineo1*aynl)
+		tem5 = math.Max(-0.95, math.Min(0.95, tem5))
+		eo1 += tem5
+		if math.Abs(tem5) < 1e-12 {
+			break
+		}
+	}
+	sineo1, coseo1 = math.Sincos(eo1)
+
+	// Short-period periodics.
+	ecose := axnl*coseo1 + aynl*sineo1
+	esine := axnl*sineo1 - aynl*coseo1
+	el2 := axnl*axnl + aynl*aynl
+	pl := am * (1 - el2)
+	if pl < 0 {
+		return Vector{}, Vector{}, errors.New("orbit: semi-latus rectum is negative")
+	}
+	rl := am * (1 - ecose)
+	rdotl := math.Sqrt(am) * esine / rl
+	rvdotl := math.Sqrt(pl) / rl
+	betal := math.Sqrt(1 - el2)
+	temp = esine / (1 + betal)
+	sinu := am / rl * (sineo1 - aynl - axnl*temp)
+	cosu := am / rl * (coseo1 - axnl + aynl*temp)
+	su := math.Atan2(sinu, cosu)
+	sin2u := (cosu + cosu) * sinu
+	cos2u := 1 - 2*sinu*sinu
+	temp = 1 / pl
+	temp1 := 0.5 * j2 * temp
+	temp2 := temp1 * temp
+
+	mrt := rl*(1-1.5*temp2*betal*s.con41) + 0.5*temp1*s.x1mth2*cos2u
+	su -= 0.25 * temp2 * s.x7thm1 * sin2u
+	xnode := nodem + 1.5*temp2*cosip*sin2u
+	xinc := s.inclo + 1.5*temp2*cosip*sinip*cos2u
+	mvt := rdotl - nm*temp1*s.x1mth2*sin2u/xke
+	rvdot := rvdotl + nm*temp1*(s.x1mth2*cos2u+1.5*s.con41)/xke
+	if mrt < 1 {
+		return Vector{}, Vector{}, ErrDecayed
+	}
+
+	// Orientation vectors.
+	sinsu, cossu := math.Sincos(su)
+	snod, cnod := math.Sincos(xnode)
+	sini, cosi := math.Sincos(xinc)
+	xmx := -snod * cosi
+	xmy := cnod * cosi
+	ux := Vector{xmx*sinsu + cnod*cossu, xmy*sinsu + snod*cossu, sini * sinsu}
+	vx := Vector{xmx*cossu - cnod*sinsu, xmy*cossu - snod*sinsu, sini * cossu}
+
+	position = ux.Scale(mrt * wgs72RadiusKm * 1000)
+	velocity = ux.Scale(mvt).Add(vx.Scale(rvdot)).Scale(vkmpersec * 1000)
+	return position, velocity, nil
+}
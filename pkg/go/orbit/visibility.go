@@ -0,0 +1,157 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orbit
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultStep is the default sampling interval of Windows. Passes of low
+	// Earth orbit satellites last several minutes, so none are missed.
+	DefaultStep = 30 * time.Second
+	// DefaultTolerance is the default precision of the window boundaries.
+	DefaultTolerance = time.Second
+)
+
+// Endpoint is one end of a link.
+type Endpoint struct {
+	Propagator
+	// MinElevationDeg is the elevation mask of the endpoint: the other
+	// endpoint is only visible when it is at least this high above the local
+	// horizon. It only applies if ElevationMask is set, which is typically
+	// the case for ground terminals.
+	MinElevationDeg float64
+	ElevationMask   bool
+}
+
+// Interval is a half-open time interval [Start, End).
+type Interval struct {
+	Start, End time.Time
+}
+
+// Visibility computes the intervals in which two endpoints have a line of
+// sight.
+type Visibility struct {
+	A, B Endpoint
+	// Step is the sampling interval. Windows shorter than the step may be
+	// missed. Defaults to DefaultStep.
+	Step time.Duration
+	// Tolerance is the precision to which the boundaries of the windows are
+	// refined. Defaults to DefaultTolerance.
+	Tolerance time.Duration
+}
+
+// Visible reports whether the endpoints have a line of sight at t: the line
+// between them does not pass through the Earth and each endpoint's elevation
+// mask is satisfied.
+func (v *Visibility) Visible(t time.Time) (bool, error) {
+	a, err := v.A.Position(t)
+	if err != nil {
+		return false, err
+	}
+	b, err := v.B.Position(t)
+	if err != nil {
+		return false, err
+	}
+	if Occluded(a, b) {
+		return false, nil
+	}
+	if v.A.ElevationMask && ElevationDeg(a, b) < v.A.MinElevationDeg {
+		return false, nil
+	}
+	if v.B.ElevationMask && ElevationDeg(b, a) < v.B.MinElevationDeg {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Windows returns the intervals within [start, end) in which the endpoints
+// are visible, in chronological order.
+func (v *Visibility) Windows(start, end time.Time) ([]Interval, error) {
+	return Intervals(start, end, v.Step, v.Tolerance, v.Visible)
+}
+
+// Intervals returns the intervals within [start, end) in which f holds, in
+// chronological order. f is sampled every step and the boundaries are refined
+// by bisection to within the tolerance. A zero step or tolerance defaults to
+// DefaultStep or DefaultTolerance.
+func Intervals(start, end time.Time, step, tolerance time.Duration, f func(time.Time) (bool, error)) ([]Interval, error) {
+	if step <= 0 {
+		step = DefaultStep
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if !start.Before(end) {
+		return nil, errors.New("orbit: start must be before end")
+	}
+
+	var intervals []Interval
+	holds, err := f(start)
+	if err != nil {
+		return nil, err
+	}
+	var open time.Time
+	if holds {
+		open = start
+	}
+	for prev := start; prev.Before(end); {
+		next := prev.Add(step)
+		if next.After(end) {
+			next = end
+		}
+		nextHolds, err := f(next)
+		if err != nil {
+			return nil, err
+		}
+		if nextHolds != holds {
+			transition, err := bisect(prev, next, holds, tolerance, f)
+			if err != nil {
+				return nil, err
+			}
+			if nextHolds {
+				open = transition
+			} else {
+				intervals = append(intervals, Interval{Start: open, End: transition})
+			}
+			holds = nextHolds
+		}
+		prev = next
+	}
+	if holds {
+		intervals = append(intervals, Interval{Start: open, End: end})
+	}
+	return intervals, nil
+}
+
+// bisect returns the first time in (lo, hi] at which f no longer returns
+// holdsAtLo, to within the tolerance.
+func bisect(lo, hi time.Time, holdsAtLo bool, tolerance time.Duration, f func(time.Time) (bool, error)) (time.Time, error) {
+	for hi.Sub(lo) > tolerance {
+		mid := lo.Add(hi.Sub(lo) / 2)
+		holds, err := f(mid)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if holds == holdsAtLo {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi, nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pagination implements https://google.aip.dev/158 pagination for the
+// List RPCs of the Interconnect API.
+//
+// Resources are ordered by name and pages are addressed by the name of the
+// last resource of the previous page, so that a page token stays valid when
+// resources are inserted or deleted concurrently. Page tokens are signed to
+// prevent tampering and are bound to the other parameters of the request,
+// e.g. the filter.
+package pagination
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"iter"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultPageSize is the page size used if a request does not set one.
+	DefaultPageSize = 50
+	// MaxPageSize is the largest page size. Larger page sizes are coerced.
+	MaxPageSize = 1000
+)
+
+// Paginator issues and verifies page tokens.
+type Paginator struct {
+	key []byte
+}
+
+// NewPaginator returns a Paginator which signs page tokens with the key. If
+// the key is empty, a random key is generated, and page tokens do not survive
+// a restart of the server.
+func NewPaginator(key []byte) *Paginator {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	return &Paginator{key: key}
+}
+
+// Request holds the pagination parameters of a List request.
+type Request struct {
+	PageSize  int32
+	PageToken string
+	// Query holds the other parameters of the request, e.g. the filter. A page
+	// token is only accepted together with the same query.
+	Query []string
+}
+
+// token is the content of a page token.
+type token struct {
+	// After is the name of the last resource of the previous page.
+	After string `json:"a"`
+	// Query is a hash of the query of the request.
+	Query []byte `json:"q"`
+}
+
+// Paginate returns the page of items selected by the request and the token of
+// the next page, which is empty on the last page. Items are ordered by the key
+// returned by name, which must be unique. The items slice is not modified.
+func Paginate[T any](p *Paginator, req Request, items []T, name func(T) string) ([]T, string, error) {
+	if req.PageSize < 0 {
+		return nil, "", status.Errorf(codes.InvalidArgument, "page_size must not be negative")
+	}
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	pageSize = min(pageSize, MaxPageSize)
+
+	query := hashQuery(req.Query)
+	start := 0
+	sorted := slices.SortedFunc(slices.Values(items), func(a, b T) int {
+		return cmp.Compare(name(a), name(b))
+	})
+	if req.PageToken != "" {
+		t, err := p.decode(req.PageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		if !hmac.Equal(t.Query, query) {
+			return nil, "", status.Errorf(codes.InvalidArgument, "page_token does not match the other parameters of the request")
+		}
+		start, _ = slices.BinarySearchFunc(sorted, t.After, func(item T, after string) int {
+			// Position after the last item of the previous page, even if it
+			// has been deleted since.
+			if name(item) <= after {
+				return -1
+			}
+			return 1
+		})
+	}
+
+	end := min(start+pageSize, len(sorted))
+	page := sorted[start:end]
+	if end == len(sorted) {
+		return page, "", nil
+	}
+	return page, p.encode(token{After: name(page[len(page)-1]), Query: query}), nil
+}
+
+func hashQuery(query []string) []byte {
+	h := sha256.New()
+	for _, q := range query {
+		// Length-prefix the parameters so that their boundaries are unambiguous.
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(q))))
+		h.Write([]byte(q))
+	}
+	return h.Sum(nil)[:16]
+}
+
+func (p *Paginator) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func (p *Paginator) encode(t token) string {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(p.sign(payload))
+}
+
+func (p *Paginator) decode(s string) (token, error) {
+	invalid := status.Errorf(codes.InvalidArgument, "invalid page_token")
+	encodedPayload, encodedSignature, ok := strings.Cut(s, ".")
+	if !ok {
+		return token{}, invalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return token{}, invalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, p.sign(payload)) {
+		return token{}, invalid
+	}
+	var t token
+	if err := json.Unmarshal(payload, &t); err != nil {
+		return token{}, invalid
+	}
+	return t, nil
+}
+
+// FetchFunc fetches the page with the given token and returns its items and
+// the token of the next page.
+type FetchFunc[T any] func(ctx context.Context, pageToken string) ([]T, string, error)
+
+// All iterates over the items of all pages of a List RPC, fetching pages as
+// needed. Iteration stops after the first error. For example:
+//
+//	for bearer, err := range pagination.All(ctx, func(ctx context.Context, pageToken string) ([]*pb.Bearer, string, error) {
+//		resp, err := client.ListBearers(ctx, &pb.ListBearersRequest{PageToken: pageToken})
+//		return resp.GetBearers(), resp.GetNextPageToken(), err
+//	}) {
+//		...
+//	}
+func All[T any](ctx context.Context, fetch FetchFunc[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		pageToken := ""
+		for {
+			items, next, err := fetch(ctx, pageToken)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if next == "" {
+				return
+			}
+			pageToken = next
+		}
+	}
+}
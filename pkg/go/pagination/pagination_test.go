@@ -0,0 +1,158 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pagination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func identity(s string) string { return s }
+
+func names(n int) []string {
+	items := make([]string, n)
+	for i := range items {
+		// Insert in reverse order to check that pages are sorted.
+		items[i] = fmt.Sprintf("bearers/%03d", n-i)
+	}
+	return items
+}
+
+func TestPaginate(t *testing.T) {
+	p := NewPaginator(nil)
+	items := names(5)
+
+	page, next, err := Paginate(p, Request{PageSize: 2}, items, identity)
+	if err != nil {
+		t.Fatalf("Paginate failed: %v", err)
+	}
+	if diff := cmp.Diff([]string{"bearers/001", "bearers/002"}, page); diff != "" {
+		t.Errorf("first page mismatch (-want +got):\n%s", diff)
+	}
+
+	// Concurrent inserts and deletes do not invalidate the token.
+	items = append(items, "bearers/000", "bearers/0025")
+	items = items[1:] // deletes bearers/005
+
+	page, next, err = Paginate(p, Request{PageSize: 2, PageToken: next}, items, identity)
+	if err != nil {
+		t.Fatalf("Paginate failed: %v", err)
+	}
+	if diff := cmp.Diff([]string{"bearers/0025", "bearers/003"}, page); diff != "" {
+		t.Errorf("second page mismatch (-want +got):\n%s", diff)
+	}
+
+	page, next, err = Paginate(p, Request{PageSize: 2, PageToken: next}, items, identity)
+	if err != nil {
+		t.Fatalf("Paginate failed: %v", err)
+	}
+	if diff := cmp.Diff([]string{"bearers/004"}, page); diff != "" {
+		t.Errorf("last page mismatch (-want +got):\n%s", diff)
+	}
+	if next != "" {
+		t.Errorf("last page has next page token %q", next)
+	}
+}
+
+func TestPaginate_PageSize(t *testing.T) {
+	p := NewPaginator(nil)
+	items := names(MaxPageSize + 1)
+
+	tests := []struct {
+		pageSize int32
+		wantLen  int
+	}{
+		{pageSize: 0, wantLen: DefaultPageSize},
+		{pageSize: 10, wantLen: 10},
+		{pageSize: MaxPageSize + 500, wantLen: MaxPageSize},
+	}
+	for _, tt := range tests {
+		page, next, err := Paginate(p, Request{PageSize: tt.pageSize}, items, identity)
+		if err != nil {
+			t.Fatalf("Paginate failed: %v", err)
+		}
+		if len(page) != tt.wantLen || next == "" {
+			t.Errorf("page_size %d returned %d items and next page token %q, want %d items", tt.pageSize, len(page), next, tt.wantLen)
+		}
+	}
+
+	if _, _, err := Paginate(p, Request{PageSize: -1}, items, identity); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("negative page_size returned %v, want InvalidArgument", err)
+	}
+}
+
+func TestPaginate_InvalidToken(t *testing.T) {
+	p := NewPaginator([]byte("key"))
+	items := names(5)
+	_, next, err := Paginate(p, Request{PageSize: 2, Query: []string{"mac = MAC_ETH"}}, items, identity)
+	if err != nil {
+		t.Fatalf("Paginate failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		p    *Paginator
+		req  Request
+	}{
+		{name: "garbage", p: p, req: Request{PageToken: "garbage", Query: []string{"mac = MAC_ETH"}}},
+		{name: "tampered", p: p, req: Request{PageToken: "x" + next, Query: []string{"mac = MAC_ETH"}}},
+		{name: "different key", p: NewPaginator([]byte("other")), req: Request{PageToken: next, Query: []string{"mac = MAC_ETH"}}},
+		{name: "different query", p: p, req: Request{PageToken: next, Query: []string{"mac = MAC_DVB_S2"}}},
+		{name: "ambiguous query", p: p, req: Request{PageToken: next, Query: []string{"mac = ", "MAC_ETH"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Paginate(tt.p, tt.req, items, identity); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("Paginate returned %v, want InvalidArgument", err)
+			}
+		})
+	}
+}
+
+func TestAll(t *testing.T) {
+	p := NewPaginator(nil)
+	items := names(7)
+	calls := 0
+	fetch := func(_ context.Context, pageToken string) ([]string, string, error) {
+		calls++
+		return Paginate(p, Request{PageSize: 3, PageToken: pageToken}, items, identity)
+	}
+
+	var got []string
+	for item, err := range All(context.Background(), fetch) {
+		if err != nil {
+			t.Fatalf("All failed: %v", err)
+		}
+		got = append(got, item)
+	}
+	if len(got) != len(items) || calls != 3 {
+		t.Errorf("All returned %d items in %d calls, want %d items in 3 calls", len(got), calls, len(items))
+	}
+
+	wantErr := errors.New("unavailable")
+	for _, err := range All(context.Background(), func(context.Context, string) ([]string, string, error) {
+		return nil, "", wantErr
+	}) {
+		if !errors.Is(err, wantErr) {
+			t.Errorf("All returned %v, want %v", err, wantErr)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reaper periodically removes attachment circuits, bearers and contact
+// windows whose interval ended more than a retention period ago, so that a
+// long-running provider does not grow without bound.
+//
+// The expvar variables reaper_runs and reaper_reaped count the runs and the
+// removed resources by collection.
+package reaper
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/outernetcouncil/federation/pkg/go/server"
+)
+
+// DefaultInterval is the default time between two runs of a Reaper.
+const DefaultInterval = time.Minute
+
+var (
+	runs = expvar.NewInt("reaper_runs")
+	// reaped counts the removed resources by their collection.
+	reaped = expvar.NewMap("reaper_reaped")
+)
+
+// Store holds the resources that are reaped. Each method removes the resources
+// whose interval ended before the cutoff, except those that remaining
+// resources still depend on, and returns how many were removed.
+type Store interface {
+	ReapAttachmentCircuits(cutoff time.Time) int
+	ReapBearers(cutoff time.Time) int
+	ReapContactWindows(cutoff time.Time) int
+}
+
+// Counts are the numbers of resources removed by a run.
+type Counts struct {
+	AttachmentCircuits int
+	Bearers            int
+	ContactWindows     int
+}
+
+// Reaper removes expired resources from a Store. It implements server.Server.
+type Reaper struct {
+	store     Store
+	retention time.Duration
+	interval  time.Duration
+	now       func() time.Time
+	logger    zerolog.Logger
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// Option configures a Reaper.
+type Option func(*Reaper)
+
+// WithInterval sets the time between two runs.
+func WithInterval(interval time.Duration) Option {
+	return func(r *Reaper) {
+		r.interval = interval
+	}
+}
+
+// WithClock replaces the function that returns the current time.
+func WithClock(now func() time.Time) Option {
+	return func(r *Reaper) {
+		r.now = now
+	}
+}
+
+// New returns a Reaper that removes the resources of the store whose interval
+// ended more than retention ago.
+func New(store Store, retention time.Duration, logger zerolog.Logger, opts ...Option) *Reaper {
+	r := &Reaper{
+		store:     store,
+		retention: retention,
+		interval:  DefaultInterval,
+		now:       time.Now,
+		logger:    logger,
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// RunOnce removes the expired resources once. Attachment circuits are removed
+// before bearers and bearers before contact windows, so that a resource is
+// removed in the same run as the resources that depend on it.
+func (r *Reaper) RunOnce() Counts {
+	cutoff := r.now().Add(-r.retention)
+	counts := Counts{
+		AttachmentCircuits: r.store.ReapAttachmentCircuits(cutoff),
+	}
+	counts.Bearers = r.store.ReapBearers(cutoff)
+	counts.ContactWindows = r.store.ReapContactWindows(cutoff)
+
+	runs.Add(1)
+	reaped.Add("attachment_circuits", int64(counts.AttachmentCircuits))
+	reaped.Add("bearers", int64(counts.Bearers))
+	reaped.Add("contact_windows", int64(counts.ContactWindows))
+	r.logger.Debug().
+		Time("cutoff", cutoff).
+		Int("attachment_circuits", counts.AttachmentCircuits).
+		Int("bearers", counts.Bearers).
+		Int("contact_windows", counts.ContactWindows).
+		Msg("Reaped expired resources")
+	return counts
+}
+
+// Start runs the reaper every interval and blocks until the context is done or
+// the reaper is shut down.
+func (r *Reaper) Start(ctx context.Context) error {
+	r.logger.Info().Msgf("Starting reaper with a retention of %v", r.retention)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.stop:
+			return nil
+		case <-ticker.C:
+			r.RunOnce()
+		}
+	}
+}
+
+// Shutdown stops the reaper.
+func (r *Reaper) Shutdown(context.Context) error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	return nil
+}
+
+var _ server.Server = (*Reaper)(nil)
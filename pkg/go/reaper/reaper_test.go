@@ -0,0 +1,94 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rs/zerolog"
+)
+
+// fakeStore records the calls of the reaper.
+type fakeStore struct {
+	calls   []string
+	cutoffs []time.Time
+}
+
+func (s *fakeStore) reap(collection string, cutoff time.Time) int {
+	s.calls = append(s.calls, collection)
+	s.cutoffs = append(s.cutoffs, cutoff)
+	return len(s.calls)
+}
+
+func (s *fakeStore) ReapAttachmentCircuits(cutoff time.Time) int {
+	return s.reap("attachmentCircuits", cutoff)
+}
+
+func (s *fakeStore) ReapBearers(cutoff time.Time) int {
+	return s.reap("bearers", cutoff)
+}
+
+func (s *fakeStore) ReapContactWindows(cutoff time.Time) int {
+	return s.reap("contactWindows", cutoff)
+}
+
+func TestRunOnce(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := &fakeStore{}
+	r := New(store, time.Hour, zerolog.Nop(), WithClock(func() time.Time { return now }))
+	runsBefore := runs.Value()
+
+	counts := r.RunOnce()
+	if diff := cmp.Diff(Counts{AttachmentCircuits: 1, Bearers: 2, ContactWindows: 3}, counts); diff != "" {
+		t.Errorf("counts mismatch (-want +got):\n%s", diff)
+	}
+	// Dependent resources are reaped first.
+	if diff := cmp.Diff([]string{"attachmentCircuits", "bearers", "contactWindows"}, store.calls); diff != "" {
+		t.Errorf("calls mismatch (-want +got):\n%s", diff)
+	}
+	for _, cutoff := range store.cutoffs {
+		if want := now.Add(-time.Hour); !cutoff.Equal(want) {
+			t.Errorf("reaped with cutoff %v, want %v", cutoff, want)
+		}
+	}
+	if got := runs.Value() - runsBefore; got != 1 {
+		t.Errorf("reaper_runs increased by %d, want 1", got)
+	}
+}
+
+func TestStart(t *testing.T) {
+	store := &fakeStore{}
+	r := New(store, time.Hour, zerolog.Nop(), WithInterval(time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- r.Start(ctx)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := r.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Start returned %v", err)
+	}
+	if len(store.calls) == 0 {
+		t.Errorf("reaper did not run")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resourcename parses, validates and formats the names of the
+// interconnect resources, see https://google.aip.dev/122.
+//
+// The patterns are read from the `google.api.resource` annotations of the
+// resource messages, so that names always match the declared patterns. All
+// interconnect resources are top-level, i.e. their pattern is
+// `{collection}/{id}`.
+package resourcename
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+// idPattern matches the resource IDs allowed by AIP-122, which follow RFC 1034:
+// lowercase letters, digits and hyphens, starting with a letter and ending with
+// a letter or digit, and at most 63 characters long.
+var idPattern = regexp.MustCompile(`^[a-z]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Pattern is the pattern of the names of a resource type.
+type Pattern struct {
+	// Type is the resource type, e.g. `interconnect.outernetcouncil.org/Bearer`.
+	Type string
+	// Collection is the collection ID, e.g. `bearers`.
+	Collection string
+}
+
+// The patterns of the interconnect resources.
+var (
+	Transceiver       = mustPattern(&pb.Transceiver{})
+	ContactWindow     = mustPattern(&pb.ContactWindow{})
+	Bearer            = mustPattern(&pb.Bearer{})
+	AttachmentCircuit = mustPattern(&pb.AttachmentCircuit{})
+	Target            = mustPattern(&pb.Target{})
+	Reservation       = mustPattern(&pb.Reservation{})
+)
+
+// mustPattern returns the pattern of a resource message. It panics if the
+// message has no `google.api.resource` annotation with a top-level pattern.
+func mustPattern(m proto.Message) Pattern {
+	md := m.ProtoReflect().Descriptor()
+	resource, _ := proto.GetExtension(md.Options(), annotations.E_Resource).(*annotations.ResourceDescriptor)
+	if len(resource.GetPattern()) != 1 {
+		panic(fmt.Sprintf("resourcename: %s has no single resource pattern", md.FullName()))
+	}
+	collection, variable, ok := strings.Cut(resource.GetPattern()[0], "/")
+	if !ok || strings.Contains(variable, "/") || !strings.HasPrefix(variable, "{") || !strings.HasSuffix(variable, "}") {
+		panic(fmt.Sprintf("resourcename: pattern %q of %s is not top-level", resource.GetPattern()[0], md.FullName()))
+	}
+	return Pattern{Type: resource.GetType(), Collection: collection}
+}
+
+// Format returns the name of the resource with the given ID.
+func (p Pattern) Format(id string) string {
+	return p.Collection + "/" + id
+}
+
+// Parse returns the ID of the resource with the given name. Malformed names are
+// reported as InvalidArgument for the given request field.
+func (p Pattern) Parse(field, name string) (string, error) {
+	id, ok := strings.CutPrefix(name, p.Collection+"/")
+	if !ok {
+		return "", invalidArgument(field, fmt.Sprintf("%q does not match the pattern %s/{id} of %s", name, p.Collection, p.Type))
+	}
+	if err := ValidateID(field, id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ID returns the ID of a name which is known to match the pattern, e.g. a name
+// returned by Format. It returns the empty string for names of other
+// collections.
+func (p Pattern) ID(name string) string {
+	id, ok := strings.CutPrefix(name, p.Collection+"/")
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// ValidateID checks that an ID chosen by a client, e.g. `bearer_id`, follows
+// the rules of AIP-122. Invalid IDs are reported as InvalidArgument with a
+// google.rpc.BadRequest field violation for the given request field.
+func ValidateID(field, id string) error {
+	if !idPattern.MatchString(id) {
+		return invalidArgument(field, fmt.Sprintf("%q is not a valid resource ID, it must consist of at most 63 lowercase letters, digits and hyphens, start with a letter and end with a letter or digit", id))
+	}
+	return nil
+}
+
+func invalidArgument(field, description string) error {
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("invalid %s", field))
+	if withDetails, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: description}},
+	}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
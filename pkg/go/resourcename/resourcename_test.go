@@ -0,0 +1,108 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resourcename
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPatterns(t *testing.T) {
+	got := map[string]string{}
+	for _, p := range []Pattern{Transceiver, ContactWindow, Bearer, AttachmentCircuit, Target, Reservation} {
+		got[p.Type] = p.Format("a")
+	}
+	want := map[string]string{
+		"interconnect.outernetcouncil.org/Transceiver":       "transceivers/a",
+		"interconnect.outernetcouncil.org/ContactWindow":     "contactWindows/a",
+		"interconnect.outernetcouncil.org/Bearer":            "bearers/a",
+		"interconnect.outernetcouncil.org/AttachmentCircuit": "attachmentCircuits/a",
+		"interconnect.outernetcouncil.org/Target":            "targets/a",
+		"interconnect.outernetcouncil.org/Reservation":       "reservations/a",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("patterns mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidateID(t *testing.T) {
+	for id, valid := range map[string]bool{
+		"a":                     true,
+		"my-bearer-1":           true,
+		strings.Repeat("a", 63): true,
+		"":                      false,
+		"1bearer":               false,
+		"bearer-":               false,
+		"my_bearer":             false,
+		"myBearer":              false,
+		"a/b":                   false,
+		strings.Repeat("a", 64): false,
+	} {
+		err := ValidateID("bearer_id", id)
+		if valid && err != nil {
+			t.Errorf("ValidateID(%q) failed: %v", id, err)
+		}
+		if !valid && status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ValidateID(%q) returned %v, want InvalidArgument", id, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantID  string
+		wantErr bool
+	}{
+		{name: "bearers/a", wantID: "a"},
+		{name: "bearers/my-bearer", wantID: "my-bearer"},
+		{name: "bearer/a", wantErr: true},
+		{name: "bearers/", wantErr: true},
+		{name: "bearers/a/b", wantErr: true},
+		{name: "transceivers/a", wantErr: true},
+		{name: "a", wantErr: true},
+	}
+	for _, tt := range tests {
+		id, err := Bearer.Parse("name", tt.name)
+		if tt.wantErr {
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("Parse(%q) returned %q, %v, want InvalidArgument", tt.name, id, err)
+				continue
+			}
+			details := status.Convert(err).Details()
+			if len(details) != 1 || details[0].(*errdetails.BadRequest).FieldViolations[0].Field != "name" {
+				t.Errorf("Parse(%q) returned details %v, want a violation of name", tt.name, details)
+			}
+			continue
+		}
+		if err != nil || id != tt.wantID {
+			t.Errorf("Parse(%q) returned %q, %v, want %q", tt.name, id, err, tt.wantID)
+		}
+	}
+}
+
+func TestID(t *testing.T) {
+	if got := Target.ID("targets/mysat"); got != "mysat" {
+		t.Errorf("ID(targets/mysat) = %q, want mysat", got)
+	}
+	if got := Target.ID("target/mysat"); got != "" {
+		t.Errorf("ID(target/mysat) = %q, want the empty string", got)
+	}
+}
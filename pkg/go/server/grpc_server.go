@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -32,16 +33,19 @@ type GrpcServer struct {
 	port    int
 	handler handler.InterconnectHandler
 	logger  zerolog.Logger
+	opts    []grpc.ServerOption
 	srv     *grpc.Server
 	lis     net.Listener
 }
 
 // NewGrpcServer creates a new GrpcServer with the given port, handler, and logger.
-func NewGrpcServer(port int, handler handler.InterconnectHandler, logger zerolog.Logger) *GrpcServer {
+// The options, e.g. interceptors, are passed to the underlying grpc.Server.
+func NewGrpcServer(port int, handler handler.InterconnectHandler, logger zerolog.Logger, opts ...grpc.ServerOption) *GrpcServer {
 	return &GrpcServer{
 		port:    port,
 		handler: handler,
 		logger:  logger,
+		opts:    opts,
 	}
 }
 
@@ -51,6 +55,11 @@ func (g *GrpcServer) Start(ctx context.Context) error {
 		return fmt.Errorf("server already running")
 	}
 
+	if err := handler.ValidateCompatibleTransceiverTypes(ctx, g.handler); err != nil {
+		g.logger.Error().Err(err).Msg("Handler advertises invalid compatible transceiver types")
+		return err
+	}
+
 	addr := fmt.Sprintf(":%d", g.port)
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -59,8 +68,11 @@ func (g *GrpcServer) Start(ctx context.Context) error {
 	}
 	g.lis = lis
 
-	g.srv = grpc.NewServer()
+	g.srv = grpc.NewServer(g.opts...)
 	pb.RegisterInterconnectServiceServer(g.srv, g.handler)
+	if h, ok := g.handler.(handler.OperationsHandler); ok {
+		longrunningpb.RegisterOperationsServer(g.srv, h.Operations())
+	}
 	reflection.Register(g.srv)
 
 	g.logger.Info().Msgf("Starting gRPC server on port %d", g.port)
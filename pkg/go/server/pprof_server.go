@@ -15,15 +15,17 @@ package server
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"net"
 	"net/http"
-	_ "net/http/pprof"
+	"net/http/pprof"
 
 	"github.com/rs/zerolog"
 )
 
-// PprofServer implements the Server interface for pprof HTTP endpoints.
+// PprofServer implements the Server interface for pprof HTTP endpoints. It also
+// serves the variables published with expvar on /debug/vars.
 type PprofServer struct {
 	address string
 	srv     *http.Server
@@ -59,8 +61,15 @@ func (p *PprofServer) Start(ctx context.Context) error {
 	p.lis = lis
 
 	// Create HTTP server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
 	p.srv = &http.Server{
-		Handler: http.DefaultServeMux,
+		Handler: mux,
 	}
 
 	p.logger.Info().Msgf("Starting pprof server on %s", p.address)
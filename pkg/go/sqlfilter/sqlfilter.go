@@ -0,0 +1,481 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlfilter compiles Interconnect API filters into parameterised SQL
+// predicates, so that providers backed by a relational database can evaluate
+// List filters in the database instead of in memory.
+//
+// Field paths are mapped to columns, or to paths inside JSON columns, by a
+// Mapping. Filters which cannot be expressed with the mapping, e.g. because
+// they traverse repeated fields or reference unmapped fields, are rejected
+// with an error wrapping ErrUnsupported, and callers may fall back to
+// evaluating them with the filter package.
+//
+// A compiled filter selects the same rows as the filter package selects
+// messages, provided that columns store NULL exactly where the filter package
+// finds no value:
+//   - If no field on the path of a column tracks presence, NULL and the
+//     default value are equivalent, so that `x = 0` matches unset fields and
+//     `x:*` matches neither.
+//   - If a message or optional field on the path is unset, the column must be
+//     NULL, and otherwise it must store the value even if it is the default.
+//     Like the filter package, only `!=` matches NULL in such columns.
+//
+// Wildcards are case-sensitive in both dialects. Timestamps are compared by
+// the database, so columns must store them in a format that orders
+// chronologically, e.g. as timestamptz, or as text in UTC with a fixed
+// precision in SQLite. Enums stored by value name only support equality.
+package sqlfilter
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/outernetcouncil/federation/pkg/go/filter"
+)
+
+// ErrUnsupported is wrapped by the errors of filters that cannot be compiled
+// to SQL.
+var ErrUnsupported = errors.New("filter cannot be pushed down to SQL")
+
+// Dialect describes the SQL syntax of a database.
+type Dialect struct {
+	// Placeholder returns the placeholder of the n-th argument, starting at 1.
+	Placeholder func(n int) string
+	// JSONField returns an expression that extracts the value at path from a
+	// JSON column, converted to a type that compares like the proto kind.
+	JSONField func(column string, path []string, kind protoreflect.Kind) string
+	// Match returns a case-sensitive predicate which holds if the string
+	// expression starts with prefix and ends with suffix, with no '/' in
+	// between. arg passes a value as an argument and returns its placeholder.
+	Match func(expr, prefix, suffix string, arg func(any) string) string
+}
+
+// Postgres is the dialect of PostgreSQL, with jsonb columns.
+var Postgres = Dialect{
+	Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+	JSONField: func(column string, path []string, kind protoreflect.Kind) string {
+		field := fmt.Sprintf("(%s #>> '{%s}')", column, strings.Join(path, ","))
+		switch kind {
+		case protoreflect.BoolKind:
+			return field + "::boolean"
+		case protoreflect.FloatKind, protoreflect.DoubleKind:
+			return field + "::double precision"
+		case protoreflect.StringKind, protoreflect.EnumKind, protoreflect.BytesKind:
+			return field
+		case protoreflect.MessageKind:
+			return field + "::timestamptz"
+		default:
+			return field + "::numeric"
+		}
+	},
+	Match: func(expr, prefix, suffix string, arg func(any) string) string {
+		prefix, suffix = escapeLike(prefix), escapeLike(suffix)
+		return fmt.Sprintf(`(%s LIKE %s ESCAPE '\' AND %s NOT LIKE %s ESCAPE '\')`,
+			expr, arg(prefix+"%"+suffix), expr, arg(prefix+"%/%"+suffix))
+	},
+}
+
+// SQLite is the dialect of SQLite, with JSON stored as text.
+var SQLite = Dialect{
+	Placeholder: func(int) string { return "?" },
+	JSONField: func(column string, path []string, _ protoreflect.Kind) string {
+		return fmt.Sprintf("json_extract(%s, '$.%s')", column, strings.Join(path, "."))
+	},
+	// LIKE ignores the case of ASCII letters in SQLite, GLOB does not.
+	Match: func(expr, prefix, suffix string, arg func(any) string) string {
+		prefix, suffix = escapeGlob(prefix), escapeGlob(suffix)
+		return fmt.Sprintf("(%s GLOB %s AND %s NOT GLOB %s)",
+			expr, arg(prefix+"*"+suffix), expr, arg(prefix+"*/*"+suffix))
+	},
+}
+
+// Column is the storage location of a field.
+type Column struct {
+	// Name is the column, optionally qualified with a table name.
+	Name string
+	// JSONPath is the path of the field inside Name if it is a JSON column.
+	JSONPath []string
+	// EnumAsNumber stores enum values as numbers instead of value names.
+	EnumAsNumber bool
+}
+
+// Mapping maps the fields of a message to columns.
+type Mapping struct {
+	// Message is the message the filters apply to.
+	Message protoreflect.MessageDescriptor
+	// Columns maps field paths, using proto field names, to columns. Paths
+	// must not traverse repeated or map fields.
+	Columns map[string]Column
+	Dialect Dialect
+}
+
+// Query is a compiled WHERE clause together with its arguments.
+type Query struct {
+	// Where is the predicate without the WHERE keyword. It is empty for the
+	// empty filter.
+	Where string
+	Args  []any
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Compile compiles the filter to a SQL predicate. Literal values are always
+// passed as arguments.
+func (m *Mapping) Compile(f *filter.Filter) (*Query, error) {
+	if f.Expr() == nil {
+		return &Query{}, nil
+	}
+	if err := f.Check(m.Message); err != nil {
+		return nil, err
+	}
+	c := &compiler{m: m}
+	where, err := c.expr(f.Expr(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{Where: where, Args: c.args}, nil
+}
+
+type compiler struct {
+	m    *Mapping
+	args []any
+}
+
+func unsupported(pos int, format string, args ...any) error {
+	return fmt.Errorf("%w: at position %d: %s", ErrUnsupported, pos, fmt.Sprintf(format, args...))
+}
+
+func (c *compiler) arg(v any) string {
+	c.args = append(c.args, v)
+	return c.m.Dialect.Placeholder(len(c.args))
+}
+
+// expr compiles e. Inside a composite argument, base is the path of the
+// enclosing restriction.
+func (c *compiler) expr(e filter.Expr, base []string) (string, error) {
+	switch e := e.(type) {
+	case *filter.AndExpr:
+		return c.join(e.Args, base, " AND ")
+	case *filter.OrExpr:
+		return c.join(e.Args, base, " OR ")
+	case *filter.NotExpr:
+		arg, err := c.expr(e.Arg, base)
+		if err != nil {
+			return "", err
+		}
+		// A comparison with NULL is unknown, whereas the negation of a
+		// restriction on an unset field is satisfied.
+		return fmt.Sprintf("NOT COALESCE(%s, FALSE)", arg), nil
+	case *filter.Restriction:
+		return c.restriction(e, base)
+	default:
+		return "", unsupported(e.Position(), "unsupported expression %s", e)
+	}
+}
+
+func (c *compiler) join(args []filter.Expr, base []string, sep string) (string, error) {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		part, err := c.expr(arg, base)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return "(" + strings.Join(parts, sep) + ")", nil
+}
+
+func (c *compiler) restriction(r *filter.Restriction, base []string) (string, error) {
+	path := append(append([]string{}, base...), r.Path...)
+	fields, err := filter.Lookup(c.m.Message, path)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, len(fields))
+	for i, fd := range fields {
+		if fd.IsList() || fd.IsMap() {
+			return "", unsupported(r.Pos, "%s is a repeated field", fd.Name())
+		}
+		names[i] = string(fd.Name())
+	}
+
+	if composite, ok := r.Arg.(*filter.Composite); ok {
+		// A composite over a singular field is equivalent to its restrictions
+		// with the path prepended.
+		return c.expr(composite.Expr, names)
+	}
+	lit, ok := r.Arg.(*filter.Literal)
+	if !ok {
+		return "", unsupported(r.Pos, "unsupported argument %s", r.Arg)
+	}
+
+	key := strings.Join(names, ".")
+	column, ok := c.m.Columns[key]
+	if !ok {
+		return "", unsupported(r.Pos, "field %s is not mapped to a column", key)
+	}
+	leaf := fields[len(fields)-1]
+	field, err := c.field(column, leaf)
+	if err != nil {
+		return "", err
+	}
+	nullIsDefault := !slices.ContainsFunc(fields, protoreflect.FieldDescriptor.HasPresence)
+
+	if r.Op == filter.OpHas && lit.Value == "*" && !lit.Quoted {
+		if leaf.Kind() == protoreflect.MessageKind {
+			return field + " IS NOT NULL", nil
+		}
+		// As in the filter package, a scalar with its default value is not
+		// present.
+		return fmt.Sprintf("(%s IS NOT NULL AND %s <> %s)", field, field, c.arg(defaultValue(leaf, column))), nil
+	}
+	if leaf.Kind() == protoreflect.StringKind && strings.Contains(lit.Value, "*") &&
+		(r.Op == filter.OpEquals || r.Op == filter.OpHas || r.Op == filter.OpNotEquals) {
+		return c.match(field, lit, r.Op == filter.OpNotEquals, nullIsDefault)
+	}
+
+	v, err := literal(leaf, column, lit)
+	if err != nil {
+		return "", err
+	}
+	var comparison string
+	switch r.Op {
+	case filter.OpEquals, filter.OpHas:
+		comparison = fmt.Sprintf("%s = %s", field, c.arg(v))
+	case filter.OpNotEquals:
+		comparison = fmt.Sprintf("%s <> %s", field, c.arg(v))
+	case filter.OpLess, filter.OpLessEquals, filter.OpGreater, filter.OpGreaterEquals:
+		if leaf.Kind() == protoreflect.BoolKind {
+			return "", unsupported(r.Pos, "%s is a bool and does not support %q", leaf.Name(), r.Op)
+		}
+		if leaf.Kind() == protoreflect.EnumKind && !column.EnumAsNumber {
+			return "", unsupported(r.Pos, "%s is stored by value name, which does not order like the enum", leaf.Name())
+		}
+		comparison = fmt.Sprintf("%s %s %s", field, r.Op, c.arg(v))
+	default:
+		return "", unsupported(r.Pos, "unsupported comparator %q", r.Op)
+	}
+	// NULL compares as the default value, or as the absence of a value, which
+	// only satisfies `!=`.
+	nullMatches := r.Op == filter.OpNotEquals
+	if nullIsDefault {
+		nullMatches = satisfiedByDefault(r.Op, defaultValue(leaf, column), v)
+	}
+	if nullMatches {
+		return fmt.Sprintf("(%s IS NULL OR %s)", field, comparison), nil
+	}
+	return comparison, nil
+}
+
+// satisfiedByDefault reports whether the default value of a field compares to
+// the literal value v with op, both as returned by defaultValue and literal.
+func satisfiedByDefault(op filter.Operator, def, v any) bool {
+	var c int
+	switch v := v.(type) {
+	case int64:
+		c = cmp.Compare(0, v)
+	case uint64:
+		c = cmp.Compare(0, v)
+	case float64:
+		c = cmp.Compare(0, v)
+	case int32:
+		c = cmp.Compare(def.(int32), v)
+	case bool:
+		c = cmp.Compare(0, btoi(v))
+	case string:
+		c = strings.Compare(def.(string), v)
+	case []byte:
+		c = bytes.Compare(def.([]byte), v)
+	case time.Time:
+		c = def.(time.Time).Compare(v)
+	}
+	switch op {
+	case filter.OpEquals, filter.OpHas:
+		return c == 0
+	case filter.OpNotEquals:
+		return c != 0
+	case filter.OpLess:
+		return c < 0
+	case filter.OpLessEquals:
+		return c <= 0
+	case filter.OpGreater:
+		return c > 0
+	case filter.OpGreaterEquals:
+		return c >= 0
+	default:
+		return false
+	}
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (c *compiler) field(column Column, leaf protoreflect.FieldDescriptor) (string, error) {
+	if !identifier.MatchString(column.Name) {
+		return "", fmt.Errorf("invalid column name %q", column.Name)
+	}
+	if len(column.JSONPath) == 0 {
+		return column.Name, nil
+	}
+	for _, segment := range column.JSONPath {
+		if !identifier.MatchString(segment) || strings.Contains(segment, ".") {
+			return "", fmt.Errorf("invalid JSON path segment %q", segment)
+		}
+	}
+	return c.m.Dialect.JSONField(column.Name, column.JSONPath, leaf.Kind()), nil
+}
+
+// match compiles a wildcard match, or its negation. As in the filter package,
+// '*' does not match '/', so that it stands for a single resource ID, and an
+// unset field is the empty string, which only the pattern "*" matches.
+func (c *compiler) match(field string, lit *filter.Literal, negate, nullIsDefault bool) (string, error) {
+	parts := strings.Split(lit.Value, "*")
+	if len(parts) != 2 {
+		return "", unsupported(lit.Pos, "only a single wildcard is supported, got %s", lit)
+	}
+	match := c.m.Dialect.Match(field, parts[0], parts[1], c.arg)
+	if negate {
+		match = "NOT " + match
+	}
+	// See restriction for the meaning of NULL.
+	nullMatches := negate
+	if nullIsDefault {
+		nullMatches = (parts[0] == "" && parts[1] == "") != negate
+	}
+	if nullMatches {
+		return fmt.Sprintf("(%s IS NULL OR %s)", field, match), nil
+	}
+	return match, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func escapeGlob(s string) string {
+	return strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]").Replace(s)
+}
+
+// defaultValue returns the value that is stored for a field with its default
+// value.
+func defaultValue(fd protoreflect.FieldDescriptor, column Column) any {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return false
+	case protoreflect.EnumKind:
+		if column.EnumAsNumber {
+			return int32(fd.Default().Enum())
+		}
+		return string(fd.Enum().Values().ByNumber(fd.Default().Enum()).Name())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return int64(0)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return uint64(0)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return float64(0)
+	case protoreflect.StringKind:
+		return ""
+	case protoreflect.BytesKind:
+		return []byte{}
+	default:
+		// A Timestamp, whose fields are zero if it is set but empty.
+		return time.Unix(0, 0).UTC()
+	}
+}
+
+// literal converts a literal to the Go value that is stored for the field.
+func literal(fd protoreflect.FieldDescriptor, column Column, lit *filter.Literal) (any, error) {
+	invalid := func() error {
+		return fmt.Errorf("invalid value %s for field %s", lit, fd.Name())
+	}
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(lit.Value)
+		if err != nil {
+			return nil, invalid()
+		}
+		return b, nil
+	case protoreflect.EnumKind:
+		ev := fd.Enum().Values().ByName(protoreflect.Name(lit.Value))
+		if ev == nil {
+			n, err := strconv.ParseInt(lit.Value, 10, 32)
+			if err != nil {
+				return nil, invalid()
+			}
+			ev = fd.Enum().Values().ByNumber(protoreflect.EnumNumber(n))
+			if ev == nil {
+				return nil, invalid()
+			}
+		}
+		if column.EnumAsNumber {
+			return int32(ev.Number()), nil
+		}
+		return string(ev.Name()), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if n, err := strconv.ParseInt(lit.Value, 10, 64); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(lit.Value, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		return f, nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if n, err := strconv.ParseUint(lit.Value, 10, 64); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(lit.Value, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		return f, nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(lit.Value, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		return f, nil
+	case protoreflect.StringKind:
+		return lit.Value, nil
+	case protoreflect.BytesKind:
+		return []byte(lit.Value), nil
+	case protoreflect.MessageKind:
+		if fd.Message().FullName() == "google.protobuf.Timestamp" {
+			t, err := time.Parse(time.RFC3339Nano, lit.Value)
+			if err != nil {
+				return nil, invalid()
+			}
+			return t, nil
+		}
+		return nil, unsupported(lit.Pos, "field %s of type %s cannot be compared in SQL", fd.Name(), fd.Message().FullName())
+	default:
+		return nil, unsupported(lit.Pos, "field %s has unsupported kind %s", fd.Name(), fd.Kind())
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlfilter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "modernc.org/sqlite"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"github.com/outernetcouncil/federation/pkg/go/filter"
+)
+
+var contactWindows = &Mapping{
+	Message: (&pb.ContactWindow{}).ProtoReflect().Descriptor(),
+	Columns: map[string]Column{
+		"name":                       {Name: "name"},
+		"transceiver":                {Name: "transceiver"},
+		"target":                     {Name: "target"},
+		"interval.start_time":        {Name: "start_time"},
+		"interval.end_time":          {Name: "end_time"},
+		"min_rx_center_frequency_hz": {Name: "min_rx_center_frequency_hz"},
+		"max_rx_center_frequency_hz": {Name: "max_rx_center_frequency_hz"},
+	},
+	Dialect: Postgres,
+}
+
+var transceivers = &Mapping{
+	Message: (&pb.Transceiver{}).ProtoReflect().Descriptor(),
+	Columns: map[string]Column{
+		"name":                                  {Name: "t.name"},
+		"transmit_signal_chain.antenna.type":    {Name: "spec", JSONPath: []string{"tx", "antenna", "type"}},
+		"receive_signal_chain.antenna.type":     {Name: "rx_antenna_type", EnumAsNumber: true},
+		"transmit_signal_chain.antenna.gain_db": {Name: "spec", JSONPath: []string{"tx", "antenna", "gain_db"}},
+	},
+	Dialect: SQLite,
+}
+
+func TestCompile(t *testing.T) {
+	start := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		mapping   *Mapping
+		filter    string
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name:      "empty filter",
+			mapping:   contactWindows,
+			filter:    "",
+			wantWhere: "",
+		},
+		{
+			name:      "resource name and timestamp",
+			mapping:   contactWindows,
+			filter:    `transceiver = "transceivers/gs1" AND interval.start_time > "2026-10-20T00:00:00Z"`,
+			wantWhere: "(transceiver = $1 AND start_time > $2)",
+			wantArgs:  []any{"transceivers/gs1", start},
+		},
+		{
+			name:      "range over a scalar field",
+			mapping:   contactWindows,
+			filter:    "min_rx_center_frequency_hz:(>= 8e9 AND <= 12000000000)",
+			wantWhere: "(min_rx_center_frequency_hz >= $1 AND (min_rx_center_frequency_hz IS NULL OR min_rx_center_frequency_hz <= $2))",
+			wantArgs:  []any{8e9, int64(12000000000)},
+		},
+		{
+			name:      "composite over a singular message",
+			mapping:   contactWindows,
+			filter:    `interval:(start_time >= "2026-10-20T00:00:00Z" OR end_time:*)`,
+			wantWhere: "(start_time >= $1 OR end_time IS NOT NULL)",
+			wantArgs:  []any{start},
+		},
+		{
+			name:      "negation and inequality",
+			mapping:   contactWindows,
+			filter:    `NOT target = "targets/a" OR target != "targets/b"`,
+			wantWhere: "(NOT COALESCE(target = $1, FALSE) OR (target IS NULL OR target <> $2))",
+			wantArgs:  []any{"targets/a", "targets/b"},
+		},
+		{
+			name:      "presence of a scalar",
+			mapping:   contactWindows,
+			filter:    "min_rx_center_frequency_hz:* AND NOT max_rx_center_frequency_hz = 0",
+			wantWhere: "((min_rx_center_frequency_hz IS NOT NULL AND min_rx_center_frequency_hz <> $1) AND NOT COALESCE((max_rx_center_frequency_hz IS NULL OR max_rx_center_frequency_hz = $2), FALSE))",
+			wantArgs:  []any{int64(0), int64(0)},
+		},
+		{
+			name:      "wildcard in a resource name",
+			mapping:   contactWindows,
+			filter:    `transceiver = "transceivers/gs_*"`,
+			wantWhere: `(transceiver LIKE $1 ESCAPE '\' AND transceiver NOT LIKE $2 ESCAPE '\')`,
+			wantArgs:  []any{`transceivers/gs\_%`, `transceivers/gs\_%/%`},
+		},
+		{
+			name:      "enum in a JSON column and as number",
+			mapping:   transceivers,
+			filter:    "transmitSignalChain.antenna.type = OPTICAL AND receive_signal_chain.antenna.type = OPTICAL",
+			wantWhere: "(json_extract(spec, '$.tx.antenna.type') = ? AND rx_antenna_type = ?)",
+			wantArgs:  []any{"OPTICAL", int32(2)},
+		},
+		{
+			name:      "qualified column",
+			mapping:   transceivers,
+			filter:    `name = "transceivers/gs1"`,
+			wantWhere: "t.name = ?",
+			wantArgs:  []any{"transceivers/gs1"},
+		},
+		{
+			name:      "case-sensitive wildcard",
+			mapping:   transceivers,
+			filter:    `name = "transceivers/GS*"`,
+			wantWhere: "(t.name GLOB ? AND t.name NOT GLOB ?)",
+			wantArgs:  []any{"transceivers/GS*", "transceivers/GS*/*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.mapping.Compile(filter.MustParse(tt.filter))
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.filter, err)
+			}
+			if got.Where != tt.wantWhere {
+				t.Errorf("Compile(%q) = %q, want %q", tt.filter, got.Where, tt.wantWhere)
+			}
+			if diff := cmp.Diff(tt.wantArgs, got.Args); diff != "" {
+				t.Errorf("Compile(%q) args mismatch (-want +got):\n%s", tt.filter, diff)
+			}
+		})
+	}
+}
+
+func TestPostgresJSONField(t *testing.T) {
+	m := &Mapping{
+		Message: transceivers.Message,
+		Columns: transceivers.Columns,
+		Dialect: Postgres,
+	}
+	got, err := m.Compile(filter.MustParse("transmit_signal_chain.antenna.gain_db > 3.5"))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if want := "(spec #>> '{tx,antenna,gain_db}')::double precision > $1"; got.Where != want {
+		t.Errorf("Compile() = %q, want %q", got.Where, want)
+	}
+}
+
+func TestCompile_Unsupported(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping *Mapping
+		filter  string
+	}{
+		{name: "unmapped field", mapping: contactWindows, filter: "max_tx_bandwidth_hz > 3"},
+		{name: "repeated field", mapping: transceivers, filter: "transmit_signal_chain.transmitter.signals.power_dbw > 3"},
+		{name: "multiple wildcards", mapping: contactWindows, filter: `name = "contactWindows/*/*"`},
+		{name: "ordering of an enum stored by name", mapping: transceivers, filter: "transmit_signal_chain.antenna.type > RF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.mapping.Compile(filter.MustParse(tt.filter))
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("Compile(%q) returned %v, want ErrUnsupported", tt.filter, err)
+			}
+		})
+	}
+}
+
+func TestCompile_InvalidFilter(t *testing.T) {
+	_, err := contactWindows.Compile(filter.MustParse("transceiver.name = x"))
+	if err == nil || errors.Is(err, ErrUnsupported) {
+		t.Errorf("Compile returned %v, want a filter error", err)
+	}
+}
+
+// TestCompile_MatchesFilter runs filters through SQLite and through the filter
+// package and checks that both select the same messages. Every message is
+// stored twice, once with unset fields as NULL where possible and once with
+// default values where possible.
+func TestCompile_MatchesFilter(t *testing.T) {
+	windows := &Mapping{
+		Message: contactWindows.Message,
+		Columns: map[string]Column{
+			"name":                       {Name: "name"},
+			"transceiver":                {Name: "transceiver"},
+			"target":                     {Name: "target"},
+			"interval.start_time":        {Name: "start_time"},
+			"interval.end_time":          {Name: "end_time"},
+			"min_rx_center_frequency_hz": {Name: "min_rx_center_frequency_hz"},
+			"predicted_link_margin_db":   {Name: "predicted_link_margin_db"},
+		},
+		Dialect: SQLite,
+	}
+	antennas := &Mapping{
+		Message: transceivers.Message,
+		Columns: map[string]Column{
+			"name":                               {Name: "name"},
+			"transmit_signal_chain.antenna.type": {Name: "tx_antenna_type"},
+			"receive_signal_chain.antenna.type":  {Name: "rx_antenna_type", EnumAsNumber: true},
+		},
+		Dialect: SQLite,
+	}
+
+	tests := []struct {
+		name     string
+		mapping  *Mapping
+		messages []string
+		filters  []string
+	}{
+		{
+			name:    "contact windows",
+			mapping: windows,
+			messages: []string{
+				`name: "contactWindows/a"`,
+				`name: "contactWindows/b" transceiver: "transceivers/gs1" target: "targets/sat1"
+				 min_rx_center_frequency_hz: 10000000000 predicted_link_margin_db: 0
+				 interval { start_time { seconds: 1792454400 } end_time { seconds: 1792458000 } }`,
+				`name: "contactWindows/c" transceiver: "transceivers/GS2" target: "targets/sat1/x"
+				 min_rx_center_frequency_hz: 3 predicted_link_margin_db: -1.5
+				 interval { start_time { seconds: 1792454400 } }`,
+			},
+			filters: []string{
+				"min_rx_center_frequency_hz:*",
+				"min_rx_center_frequency_hz = 0",
+				"min_rx_center_frequency_hz != 0",
+				"min_rx_center_frequency_hz < 5",
+				"min_rx_center_frequency_hz >= 8e9",
+				"NOT min_rx_center_frequency_hz > 0",
+				"min_rx_center_frequency_hz:(>= 0 AND <= 12000000000)",
+				"predicted_link_margin_db:*",
+				"predicted_link_margin_db <= 0",
+				"target:*",
+				`target = ""`,
+				`target != ""`,
+				`NOT target = "targets/sat1"`,
+				`transceiver = "transceivers/*"`,
+				`transceiver = "transceivers/gs*"`,
+				`transceiver != "transceivers/gs*"`,
+				`target = "*"`,
+				`target != "*"`,
+				`target = "targets/*"`,
+				"interval.end_time:*",
+				`interval.start_time < "2026-10-20T00:00:00Z"`,
+				`interval.start_time >= "1970-01-01T00:00:00Z"`,
+				`interval.end_time > "2026-01-01T00:00:00Z" OR NOT name = "contactWindows/a"`,
+			},
+		},
+		{
+			name:    "enums",
+			mapping: antennas,
+			messages: []string{
+				`name: "transceivers/a"`,
+				`name: "transceivers/b" transmit_signal_chain { antenna { type: RF } } receive_signal_chain { antenna { type: OPTICAL } }`,
+				`name: "transceivers/c" transmit_signal_chain { antenna { type: OPTICAL } } receive_signal_chain { antenna { type: RF } }`,
+			},
+			filters: []string{
+				"transmit_signal_chain.antenna.type = UNSPECIFIED",
+				"transmit_signal_chain.antenna.type = OPTICAL",
+				"transmit_signal_chain.antenna.type != RF",
+				"transmit_signal_chain.antenna.type:*",
+				"receive_signal_chain.antenna.type = 0",
+				"receive_signal_chain.antenna.type:*",
+				"receive_signal_chain.antenna.type < OPTICAL",
+				"receive_signal_chain.antenna.type >= RF",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, tt.mapping)
+			for i, text := range tt.messages {
+				m := dynamicpb.NewMessage(tt.mapping.Message)
+				if err := prototext.Unmarshal([]byte(text), m); err != nil {
+					t.Fatalf("invalid message %d: %v", i, err)
+				}
+				insertRow(t, db, tt.mapping, i, m)
+			}
+
+			for _, src := range tt.filters {
+				f := filter.MustParse(src)
+				var want []int
+				for i, text := range tt.messages {
+					m := dynamicpb.NewMessage(tt.mapping.Message)
+					prototext.Unmarshal([]byte(text), m)
+					ok, err := f.Matches(m)
+					if err != nil {
+						t.Fatalf("Matches(%q) failed: %v", src, err)
+					}
+					if ok {
+						want = append(want, i)
+					}
+				}
+
+				got, err := tt.mapping.Compile(f)
+				if err != nil {
+					t.Fatalf("Compile(%q) failed: %v", src, err)
+				}
+				for _, defaults := range []bool{false, true} {
+					rows, err := db.Query("SELECT msg FROM t WHERE defaults = ? AND "+got.Where, append([]any{defaults}, got.Args...)...)
+					if err != nil {
+						t.Fatalf("query of %q failed: %v", got.Where, err)
+					}
+					var selected []int
+					for rows.Next() {
+						var i int
+						if err := rows.Scan(&i); err != nil {
+							t.Fatal(err)
+						}
+						selected = append(selected, i)
+					}
+					rows.Close()
+					slices.Sort(selected)
+					if diff := cmp.Diff(want, selected); diff != "" {
+						t.Errorf("%q with defaults stored = %v selects other messages than the filter (-want +got):\n%s", src, defaults, diff)
+					}
+				}
+			}
+		})
+	}
+}
+
+// newTestDB creates an in-memory table t with the columns of the mapping, and
+// the message index msg and whether defaults are stored.
+func newTestDB(t *testing.T, m *Mapping) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	columns := []string{"msg", "defaults"}
+	for _, path := range slices.Sorted(maps.Keys(m.Columns)) {
+		columns = append(columns, m.Columns[path].Name)
+	}
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE t (%s)", strings.Join(columns, ", "))); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// insertRow stores the message twice, see columnValue.
+func insertRow(t *testing.T, db *sql.DB, m *Mapping, i int, msg protoreflect.ProtoMessage) {
+	t.Helper()
+	paths := slices.Sorted(maps.Keys(m.Columns))
+	for _, defaults := range []bool{false, true} {
+		columns := []string{"msg", "defaults"}
+		args := []any{i, defaults}
+		for _, path := range paths {
+			column := m.Columns[path]
+			columns = append(columns, column.Name)
+			args = append(args, columnValue(t, m, path, column, msg.ProtoReflect(), defaults))
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+		query := fmt.Sprintf("INSERT INTO t (%s) VALUES (%s)", strings.Join(columns, ", "), placeholders)
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// columnValue returns the value stored for the field at path. Unset fields
+// are NULL, except for fields below a message or optional field, which store
+// their default values, and fields which store them if defaults is set.
+func columnValue(t *testing.T, m *Mapping, path string, column Column, msg protoreflect.Message, defaults bool) any {
+	t.Helper()
+	fields, err := filter.Lookup(m.Message, strings.Split(path, "."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fd := range fields {
+		if fd.HasPresence() {
+			if !msg.Has(fd) {
+				return nil
+			}
+			defaults = true
+		} else if !msg.Has(fd) && !defaults {
+			return nil
+		}
+		if fd.Kind() != protoreflect.MessageKind {
+			v := msg.Get(fd)
+			if fd.Kind() == protoreflect.EnumKind {
+				if column.EnumAsNumber {
+					return int32(v.Enum())
+				}
+				return string(fd.Enum().Values().ByNumber(v.Enum()).Name())
+			}
+			return v.Interface()
+		}
+		msg = msg.Get(fd).Message()
+	}
+	// A Timestamp.
+	ts := msg.Descriptor().Fields()
+	seconds, nanos := msg.Get(ts.ByName("seconds")).Int(), msg.Get(ts.ByName("nanos")).Int()
+	return time.Unix(seconds, nanos).UTC()
+}
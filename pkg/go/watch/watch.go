@@ -0,0 +1,255 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package watch implements the snapshot-then-delta semantics of the Watch
+// RPCs of the Interconnect API.
+//
+// A Hub keeps a bounded log of the changes of one resource type. Handlers
+// publish every change while holding the lock that protects their state, and
+// subscribe while holding the same lock when they take the snapshot, so that
+// no change is lost or delivered twice. Resume tokens address a position in
+// the log and are only valid for the lifetime of the Hub.
+package watch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+	"github.com/outernetcouncil/federation/pkg/go/handler"
+)
+
+// DefaultCapacity is the number of events a Hub keeps for resumption if no
+// capacity is given.
+const DefaultCapacity = 1024
+
+// Event is a change of a resource. Old is unset for ADDED events and New is
+// unset for DELETED events.
+type Event[T proto.Message] struct {
+	Type     pb.WatchEventType
+	Old, New T
+}
+
+// Hub distributes the changes of one resource type to subscribers.
+type Hub[T proto.Message] struct {
+	mu       sync.Mutex
+	epoch    string
+	capacity int
+	// first is the sequence number of events[0].
+	first  uint64
+	events []Event[T]
+	// notify is closed and replaced whenever an event is published.
+	notify chan struct{}
+}
+
+// NewHub returns a Hub which keeps the last capacity events.
+func NewHub[T proto.Message](capacity int) *Hub[T] {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	epoch := make([]byte, 8)
+	if _, err := rand.Read(epoch); err != nil {
+		panic(err)
+	}
+	return &Hub[T]{
+		epoch:    hex.EncodeToString(epoch),
+		capacity: capacity,
+		first:    1,
+		notify:   make(chan struct{}),
+	}
+}
+
+// Publish records a change. Use Added, Modified and Deleted for brevity.
+func (h *Hub[T]) Publish(typ pb.WatchEventType, oldItem, newItem T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.events) == h.capacity {
+		h.events = h.events[1:]
+		h.first++
+	}
+	h.events = append(h.events, Event[T]{
+		Type: typ,
+		Old:  oldItem,
+		New:  newItem,
+	})
+	close(h.notify)
+	h.notify = make(chan struct{})
+}
+
+// Added publishes the creation of a resource.
+func (h *Hub[T]) Added(item T) {
+	var zero T
+	h.Publish(pb.WatchEventType_WATCH_EVENT_TYPE_ADDED, zero, item)
+}
+
+// Modified publishes a change of a resource.
+func (h *Hub[T]) Modified(oldItem, newItem T) {
+	h.Publish(pb.WatchEventType_WATCH_EVENT_TYPE_MODIFIED, oldItem, newItem)
+}
+
+// Deleted publishes the deletion of a resource.
+func (h *Hub[T]) Deleted(item T) {
+	var zero T
+	h.Publish(pb.WatchEventType_WATCH_EVENT_TYPE_DELETED, item, zero)
+}
+
+// Subscription reads the events of a Hub from a position in its log.
+type Subscription[T proto.Message] struct {
+	hub *Hub[T]
+	// next is the sequence number of the next event to read.
+	next uint64
+}
+
+// Subscribe returns a subscription starting after the event of the resume
+// token, and reports whether it could be resumed. Otherwise, the subscription
+// starts at the current end of the log, and the caller must send a snapshot
+// taken while holding the same lock that serializes calls to Publish.
+func (h *Hub[T]) Subscribe(resumeToken string) (*Subscription[T], bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	end := h.first + uint64(len(h.events))
+	if seq, ok := h.parseToken(resumeToken); ok && seq+1 >= h.first && seq < end {
+		return &Subscription[T]{hub: h, next: seq + 1}, true
+	}
+	return &Subscription[T]{hub: h, next: end}, false
+}
+
+func (h *Hub[T]) token(seq uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+func (h *Hub[T]) parseToken(token string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(token, "-")
+	if !ok || epoch != h.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
+
+// Token returns the resume token of the last event that was read.
+func (s *Subscription[T]) Token() string {
+	return s.hub.token(s.next - 1)
+}
+
+// Next blocks until the next event is available or the context is done. It
+// fails with ABORTED if the subscriber fell so far behind that the event was
+// dropped from the log.
+func (s *Subscription[T]) Next(ctx context.Context) (Event[T], error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return Event[T]{}, status.FromContextError(err).Err()
+		}
+		s.hub.mu.Lock()
+		if s.next < s.hub.first {
+			s.hub.mu.Unlock()
+			return Event[T]{}, status.Errorf(codes.Aborted, "watch fell behind by more than %d events, restart it without a resume token", s.hub.capacity)
+		}
+		if i := s.next - s.hub.first; i < uint64(len(s.hub.events)) {
+			ev := s.hub.events[i]
+			s.hub.mu.Unlock()
+			s.next++
+			return ev, nil
+		}
+		notify := s.hub.notify
+		s.hub.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// SendFunc sends one response of a Watch stream. The item is unset for
+// SNAPSHOT_COMPLETE events.
+type SendFunc[T proto.Message] func(typ pb.WatchEventType, item T, resumeToken string) error
+
+// Serve runs a Watch stream. If snapshot is not nil, it sends the snapshot
+// first, and then it sends the events of the subscription until the context
+// is done. Events are translated with respect to the filter, so that e.g. a
+// modification that makes a resource match the filter is sent as ADDED.
+func Serve[T proto.Message](ctx context.Context, sub *Subscription[T], snapshot []T, filterExpr string, send SendFunc[T]) error {
+	var zero T
+	f, err := handler.ParseFilter("filter", filterExpr, zero.ProtoReflect().Descriptor())
+	if err != nil {
+		return err
+	}
+	matches := func(item T) (bool, error) {
+		if !item.ProtoReflect().IsValid() {
+			return false, nil
+		}
+		ok, err := f.Matches(item)
+		if err != nil {
+			return false, handler.FilterError("filter", err)
+		}
+		return ok, nil
+	}
+
+	if snapshot != nil {
+		token := sub.Token()
+		for _, item := range snapshot {
+			ok, err := matches(item)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if err := send(pb.WatchEventType_WATCH_EVENT_TYPE_SNAPSHOT, item, token); err != nil {
+				return err
+			}
+		}
+		if err := send(pb.WatchEventType_WATCH_EVENT_TYPE_SNAPSHOT_COMPLETE, zero, token); err != nil {
+			return err
+		}
+	}
+
+	for {
+		ev, err := sub.Next(ctx)
+		if err != nil {
+			return err
+		}
+		oldMatch, err := matches(ev.Old)
+		if err != nil {
+			return err
+		}
+		newMatch, err := matches(ev.New)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case oldMatch && newMatch:
+			err = send(pb.WatchEventType_WATCH_EVENT_TYPE_MODIFIED, ev.New, sub.Token())
+		case newMatch:
+			err = send(pb.WatchEventType_WATCH_EVENT_TYPE_ADDED, ev.New, sub.Token())
+		case oldMatch:
+			err = send(pb.WatchEventType_WATCH_EVENT_TYPE_DELETED, ev.Old, sub.Token())
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
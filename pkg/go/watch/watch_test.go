@@ -0,0 +1,178 @@
+// Copyright 2024 Outernet Council Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	pb "github.com/outernetcouncil/federation/gen/go/federation/interconnect/v1alpha"
+)
+
+type event struct {
+	Type  pb.WatchEventType
+	Name  string
+	Token string
+}
+
+// collect serves the subscription until n events have been sent.
+func collect(t *testing.T, sub *Subscription[*pb.Bearer], snapshot []*pb.Bearer, filter string, n int) []event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var events []event
+	err := Serve(ctx, sub, snapshot, filter, func(typ pb.WatchEventType, bearer *pb.Bearer, token string) error {
+		events = append(events, event{Type: typ, Name: bearer.GetName(), Token: token})
+		if len(events) == n {
+			cancel()
+		}
+		return nil
+	})
+	if len(events) != n {
+		t.Fatalf("Serve sent %d events before returning %v, want %d", len(events), err, n)
+	}
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("Serve returned %v, want Canceled", err)
+	}
+	return events
+}
+
+func bearer(name, target string) *pb.Bearer {
+	return &pb.Bearer{Name: name, Target: target}
+}
+
+func TestServe(t *testing.T) {
+	hub := NewHub[*pb.Bearer](0)
+	a := bearer("bearers/a", "targets/x")
+	sub, resumed := hub.Subscribe("")
+	if resumed {
+		t.Errorf("Subscribe(\"\") resumed")
+	}
+
+	b := bearer("bearers/b", "targets/x")
+	hub.Added(b)
+	hub.Modified(b, bearer("bearers/b", "targets/y"))
+	hub.Modified(a, bearer("bearers/a", "targets/y"))
+	hub.Deleted(bearer("bearers/a", "targets/y"))
+	hub.Added(bearer("bearers/c", "targets/x"))
+
+	got := collect(t, sub, []*pb.Bearer{a, bearer("bearers/z", "targets/y")}, `target = "targets/x"`, 5)
+	want := []pb.WatchEventType{
+		pb.WatchEventType_WATCH_EVENT_TYPE_SNAPSHOT,
+		pb.WatchEventType_WATCH_EVENT_TYPE_SNAPSHOT_COMPLETE,
+		pb.WatchEventType_WATCH_EVENT_TYPE_ADDED,
+		// b no longer matches the filter.
+		pb.WatchEventType_WATCH_EVENT_TYPE_DELETED,
+		// a no longer matches the filter, and its deletion is not sent.
+		pb.WatchEventType_WATCH_EVENT_TYPE_DELETED,
+	}
+	var types []pb.WatchEventType
+	for _, ev := range got {
+		types = append(types, ev.Type)
+	}
+	if diff := cmp.Diff(want, types); diff != "" {
+		t.Errorf("event types mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"bearers/a", "", "bearers/b", "bearers/b", "bearers/a"}, []string{got[0].Name, got[1].Name, got[2].Name, got[3].Name, got[4].Name}); diff != "" {
+		t.Errorf("event names mismatch (-want +got):\n%s", diff)
+	}
+
+	// Resuming after the deletion of b continues with the modification of a.
+	sub, resumed = hub.Subscribe(got[3].Token)
+	if !resumed {
+		t.Fatalf("Subscribe(%q) did not resume", got[3].Token)
+	}
+	got = collect(t, sub, nil, "", 3)
+	if diff := cmp.Diff([]event{
+		{Type: pb.WatchEventType_WATCH_EVENT_TYPE_MODIFIED, Name: "bearers/a", Token: got[0].Token},
+		{Type: pb.WatchEventType_WATCH_EVENT_TYPE_DELETED, Name: "bearers/a", Token: got[1].Token},
+		{Type: pb.WatchEventType_WATCH_EVENT_TYPE_ADDED, Name: "bearers/c", Token: got[2].Token},
+	}, got); diff != "" {
+		t.Errorf("resumed events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestServe_LiveEvents(t *testing.T) {
+	hub := NewHub[*pb.Bearer](0)
+	sub, _ := hub.Subscribe("")
+	b := bearer("bearers/b", "targets/x")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sent := make(chan *pb.Bearer)
+	done := make(chan error)
+	go func() {
+		done <- Serve(ctx, sub, nil, "", func(_ pb.WatchEventType, bearer *pb.Bearer, _ string) error {
+			sent <- bearer
+			return nil
+		})
+	}()
+
+	hub.Added(b)
+	if diff := cmp.Diff(b, <-sent, protocmp.Transform()); diff != "" {
+		t.Errorf("sent bearer mismatch (-want +got):\n%s", diff)
+	}
+	cancel()
+	if err := <-done; status.Code(err) != codes.Canceled {
+		t.Errorf("Serve returned %v, want Canceled", err)
+	}
+}
+
+func TestSubscribe_ExpiredToken(t *testing.T) {
+	hub := NewHub[*pb.Bearer](2)
+	sub, _ := hub.Subscribe("")
+	hub.Added(bearer("bearers/a", ""))
+	got := collect(t, sub, nil, "", 1)
+
+	hub.Added(bearer("bearers/b", ""))
+	hub.Added(bearer("bearers/c", ""))
+	hub.Added(bearer("bearers/d", ""))
+	for _, token := range []string{got[0].Token, "garbage", NewHub[*pb.Bearer](2).token(1)} {
+		if _, resumed := hub.Subscribe(token); resumed {
+			t.Errorf("Subscribe(%q) resumed, want a new snapshot", token)
+		}
+	}
+}
+
+func TestSubscription_FellBehind(t *testing.T) {
+	hub := NewHub[*pb.Bearer](2)
+	sub, _ := hub.Subscribe("")
+	for _, name := range []string{"bearers/a", "bearers/b", "bearers/c"} {
+		hub.Added(bearer(name, ""))
+	}
+
+	if _, err := sub.Next(context.Background()); status.Code(err) != codes.Aborted {
+		t.Errorf("Next returned %v, want Aborted", err)
+	}
+}
+
+func TestServe_InvalidFilter(t *testing.T) {
+	hub := NewHub[*pb.Bearer](0)
+	sub, _ := hub.Subscribe("")
+	err := Serve(context.Background(), sub, nil, "no_such_field = 1", func(pb.WatchEventType, *pb.Bearer, string) error {
+		t.Error("Serve sent an event")
+		return nil
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Serve returned %v, want InvalidArgument", err)
+	}
+}